@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestAbandonmentKeepsResidentialZoning(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 0, 4, 20, 4)
+	build(g, 5, 5, Residential).AbandonPhase = 1
+	build(g, 8, 5, Industrial).AbandonPhase = 1
+	g.allocateLaborAndSupplies(newBuildingChangeSet())
+
+	home, factory := g.Tiles[5][5], g.Tiles[5][8]
+	if home.Building != nil || home.Zone == nil || home.Zone.Type != Residential {
+		t.Fatalf("abandoned home left building %+v, zone %+v; want the zone only", home.Building, home.Zone)
+	}
+	if factory.Building != nil || factory.Zone != nil {
+		t.Fatalf("abandoned factory left building %+v, zone %+v; want neither", factory.Building, factory.Zone)
+	}
+
+	g.progressBuildings(newBuildingChangeSet())
+	if b := home.Building; b == nil || b.Type != Residential || b.Stage != 1 {
+		t.Fatalf("kept residential zone has building %+v, want construction restarted", b)
+	}
+	if factory.Building != nil {
+		t.Fatal("cleared industrial lot started building again")
+	}
+}
+
+func TestIdleBuildingIsAtRiskBeforeAbandoning(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 0, 4, 20, 4)
+	b := build(g, 8, 5, Industrial) // no homes, so never any workers
+	g.Tick = 100
+	atRisk, abandoned := -1, -1
+	for i := 0; i < 20 && abandoned < 0; i++ {
+		changes := newBuildingChangeSet()
+		g.allocateLaborAndSupplies(changes)
+		changes.add(8, 5)
+		u := changes.snapshot(g)[0]
+		if u.AtRisk && atRisk < 0 {
+			atRisk = i
+		}
+		if b.AbandonPhase > 0 {
+			abandoned = i
+			if u.AtRisk || u.Decay <= 0 || u.Decay > 1 {
+				t.Fatalf("abandoning update %+v, want decay progress and no at-risk flag", u)
+			}
+		}
+	}
+	if atRisk < 0 || abandoned <= atRisk {
+		t.Fatalf("at risk from tick %d, abandoning from tick %d", atRisk, abandoned)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdminActions(t *testing.T) {
+	setAdminToken(t, "secret")
+	dir := t.TempDir()
+	t.Setenv("CITYSIM_RECORD_DIR", dir)
+	r := testRoom(t)
+	if err := r.startJournal(roomConfig{Seed: 1, Speed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	g := r.game
+	join(r, "a", 100000)
+	join(r, "b", 100000)
+	act(t, r, "b", ActionPlaceZone, PlaceZonePayload{X: 3, Y: 3, Zone: Residential})
+	c := probe(r, "a")
+
+	for _, token := range []string{"", "wrong"} {
+		if reason := request(t, c, ActionAdminClearRect, AdminClearRectPayload{Token: token, X0: 0, Y0: 0, X1: 5, Y1: 5}); reason != ReasonUnauthorized {
+			t.Fatalf("admin_clear_rect with token %q: %q, want %q", token, reason, ReasonUnauthorized)
+		}
+		if reason := request(t, c, ActionAdminReset, AdminResetPayload{Token: token}); reason != ReasonUnauthorized {
+			t.Fatalf("admin_reset with token %q: %q, want %q", token, reason, ReasonUnauthorized)
+		}
+	}
+	if g.Tiles[3][3].Zone == nil || r.game != g {
+		t.Fatal("an unauthorized admin action changed the map")
+	}
+	if reason := request(t, c, ActionAdminClearRect, AdminClearRectPayload{Token: "secret", X0: 5, Y0: 5, X1: 0, Y1: 0}); reason != "" {
+		t.Fatalf("admin_clear_rect: %s", reason)
+	}
+	if g.Tiles[3][3].Zone != nil {
+		t.Fatal("admin_clear_rect left another player's zone")
+	}
+
+	act(t, r, "b", ActionPlaceZone, PlaceZonePayload{X: 8, Y: 8, Zone: Residential})
+	g.Tick = 50
+	payload, _ := json.Marshal(AdminResetPayload{Token: "secret"})
+	msg, _ := json.Marshal(Envelope{Type: ActionAdminReset, Payload: payload})
+	c.handleMessage(msg)
+	var state GameState
+	if err := json.Unmarshal(nextEvent(t, c, EventFullState), &state); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range state.Tiles {
+		for _, tile := range row {
+			if tile.Zone != nil {
+				t.Fatalf("zone at (%d,%d) survived the reset", tile.X, tile.Y)
+			}
+		}
+	}
+	if r.game == g || r.game.Tiles[8][8].Zone != nil || state.Tick != 50 || len(state.Players) != 2 || state.Players["b"].Money != startMoney {
+		t.Fatalf("after reset: tick %d, players %+v", state.Tick, state.Players)
+	}
+
+	r.stopJournal()
+	journal, err := os.ReadFile(filepath.Join(dir, "test.replay.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(journal), ActionAdminReset) || strings.Contains(string(journal), "secret") {
+		t.Fatalf("journal should record the admin actions without the token:\n%s", journal)
+	}
+}
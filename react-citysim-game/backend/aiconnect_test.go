@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestBotJoinsRoadClusters(t *testing.T) {
+	r := newRoomFrom("bridge", roomConfig{Seed: 3, Speed: 1, Bots: []string{"balanced"}, BotMoney: botMoney})
+	go r.hub.run()
+	defer r.hub.stop()
+	g := r.game
+	roadLine(g, 10, 30, 20, 30)
+	roadLine(g, 26, 30, 36, 30)
+	if n := len(g.roadComponents()); n != 2 {
+		t.Fatalf("%d road networks to start with, want 2", n)
+	}
+	for i := 0; i < 200 && len(g.roadPath([2]int{10, 30}, [2]int{36, 30}, 400)) == 0; i++ {
+		g.Tick += g.Config.AIActionInterval
+		g.aiTick()
+	}
+	if len(g.roadPath([2]int{10, 30}, [2]int{36, 30}, 400)) == 0 {
+		t.Fatal("bot never joined the two road clusters")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// airportRun runs a city of 1000 with one shop on a road from the map edge, with or without an
+// airport, and returns the owner's earnings, the shop's customers summed over the run and the
+// tourism booked.
+func airportRun(t *testing.T, airport bool) (earned, customers, tourism int) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	roadLine(g, 0, 10, 20, 10)
+	shop := build(g, 15, 11, Commercial)
+	if airport {
+		if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 5, Y: 11, Kind: "airport"}); reason != "" {
+			t.Fatalf("airport rejected: %s", reason)
+		}
+	}
+	g.Population = 1000
+	money := g.Players["p"].Money
+	for i := 0; i < 30; i++ {
+		g.economicTick()
+		g.spawnVisitors()
+		for k := 0; k < 10; k++ {
+			g.updateCitizens(0.1)
+		}
+		customers += g.shopCustomers()[shop]
+	}
+	for _, b := range g.Budgets {
+		tourism += b.Tourism
+	}
+	return g.Players["p"].Money - money, customers, tourism
+}
+
+func TestAirportBringsVisitorsAndIncome(t *testing.T) {
+	earned, customers, _ := airportRun(t, false)
+	earnedAir, customersAir, tourism := airportRun(t, true)
+	if tourism <= 0 || earnedAir <= earned {
+		t.Errorf("earned %d with an airport (tourism %d), %d without", earnedAir, tourism, earned)
+	}
+	if customersAir <= customers {
+		t.Errorf("shop customers %d with an airport, %d without", customersAir, customers)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestBotSkipsTilesBesideARoadStub(t *testing.T) {
+	g := newGame(1)
+	g.hub = newHub()
+	go g.hub.run()
+	defer g.hub.stop()
+	bot := &Player{ID: "bot", Money: 10000, Bot: true}
+	g.Players[bot.ID] = bot
+	roadLine(g, 10, 10, 20, 10)
+	build(g, 12, 11, Residential)
+	g.Tiles[40][40].Road = &Road{} // a one-tile stub reaching nothing
+	g.roadsChanged()
+
+	if g.aiPlaceZone(bot, 41, 40, Residential) {
+		t.Fatal("bot zoned beside the stub")
+	}
+	if g.Tiles[40][41].Zone != nil || bot.Money != 10000 {
+		t.Fatal("refused zone changed the map or the money")
+	}
+	for i := 0; i < 200; i++ {
+		if x, y, ok := g.findZoneSpotNearRoad(false); ok && x >= 39 && x <= 41 && y >= 39 && y <= 41 {
+			t.Fatalf("spot finder offered (%d,%d) beside the stub", x, y)
+		}
+	}
+	if !g.aiPlaceZone(bot, 15, 11, Residential) {
+		t.Fatal("bot refused a tile on the served network")
+	}
+}
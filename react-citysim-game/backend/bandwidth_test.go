@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// deflatedSize is the size of b compressed the way permessage-deflate sends it: flate at
+// BestSpeed, as set by wsHandler.
+func deflatedSize(t *testing.T, b []byte) int {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(b)
+	w.Close()
+	return buf.Len()
+}
+
+// busyCity fills a 64x64 map with a road grid lined by finished homes, shops and industry, and
+// runs it for ticks seconds of game time so commuters, cars and goods are on the move.
+func busyCity(t testing.TB, ticks int) *Room {
+	r := testRoom(t)
+	g := r.game
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			tl := g.Tiles[y][x]
+			tl.Terrain, tl.Elevation, tl.Foliage = "grass", 0, ""
+			switch {
+			case y%4 == 0 || x%16 == 0:
+				tl.Road = &Road{}
+			case y%4 == 2 && x%12 == 6:
+				tl.Structure = &Structure{Type: "water_tower"}
+			case y%4 == 1:
+				tl.Zone = &Zone{Type: Residential}
+				tl.Building = &Building{Type: Residential, Final: true, Watered: true, Powered: true, Residents: maxResidents}
+			case y%4 == 3:
+				typ := Commercial
+				if x%2 == 0 {
+					typ = Industrial
+				}
+				tl.Zone = &Zone{Type: typ}
+				tl.Building = &Building{Type: typ, Final: true, Watered: true, Powered: true, Stock: 8, Supplies: 8}
+			}
+		}
+	}
+	g.Tiles[2][2].Structure = &Structure{Type: "power_plant", Plant: "nuclear", Capacity: plantSpecs["nuclear"].Capacity}
+	g.index = nil
+	g.roadsChanged()
+	for i := 0; i < ticks; i++ {
+		r.stepGame()
+		for f := 0; f < 10; f++ {
+			r.trafficFrame(100 * time.Millisecond)
+		}
+	}
+	return r
+}
+
+// TestBandwidth measures how much permessage-deflate saves on a busy city's traffic update and full
+// state; the README's Bandwidth figures come from its -v output.
+func TestBandwidth(t *testing.T) {
+	r := busyCity(t, 60)
+	// the hub is running, so catch the next traffic update on a registered client
+	c := &Client{id: "probe", room: r, send: make(chan []byte, 1024)}
+	r.hub.register <- c
+	var traffic []byte
+	for traffic == nil {
+		r.trafficFrame(100 * time.Millisecond)
+		for len(c.send) > 0 {
+			if b := <-c.send; bytes.HasPrefix(b, []byte(`{"type":"traffic"`)) {
+				traffic = b
+			}
+		}
+	}
+	full, err := json.Marshal(r.game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := r.game
+	t.Logf("population %d, %d moving entities", g.Population, len(g.Vehicles)+len(g.CitizenGroups)+len(g.GoodsIC)+len(g.GoodsCC))
+	for _, m := range []struct {
+		name     string
+		b        []byte
+		maxRatio float64
+	}{{"traffic", traffic, 0.5}, {"full_state", full, 0.25}} {
+		raw, z := len(m.b), deflatedSize(t, m.b)
+		t.Logf("%s: %d -> %d bytes (%.0f%% smaller)", m.name, raw, z, 100*(1-float64(z)/float64(raw)))
+		if float64(z) > m.maxRatio*float64(raw) {
+			t.Errorf("%s compressed to %d of %d bytes, want at most %.0f%%", m.name, z, raw, 100*m.maxRatio)
+		}
+	}
+}
+
+// TestCompressedFullStateRoundTrip joins over a connection that negotiates permessage-deflate and
+// checks the full state it receives decodes to the room's state.
+func TestCompressedFullStateRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?room=deflate&spectate=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("compression not negotiated: %q", ext)
+	}
+	var got GameState
+	if err := json.Unmarshal(wsWait(t, conn, EventFullState), &got); err != nil {
+		t.Fatal(err)
+	}
+	room := findRoom("deflate")
+	room.mu.RLock()
+	want := room.game
+	ok := got.Width == want.Width && got.Height == want.Height && got.Seed == want.Seed && len(got.Tiles) == len(want.Tiles) && len(got.Players) == len(want.Players)
+	room.mu.RUnlock()
+	if !ok {
+		t.Fatalf("full state %dx%d seed %d, want %dx%d seed %d", got.Width, got.Height, got.Seed, want.Width, want.Height, want.Seed)
+	}
+}
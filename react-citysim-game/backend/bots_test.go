@@ -0,0 +1,141 @@
+package main
+
+import "testing"
+
+// zonePicks counts the zone types strategy picks across a sweep of demand in a city with some
+// idle workers and spare housing.
+func zonePicks(strategy string) map[ZoneType]int {
+	return zonePicksUnder(strategy, simConfig)
+}
+
+// zonePicksUnder is zonePicks with the tuning cfg.
+func zonePicksUnder(strategy string, cfg SimConfig) map[ZoneType]int {
+	g := newGame(1)
+	g.Config = cfg
+	for x := 10; x < 20; x++ {
+		build(g, x, 10, Residential).Residents = 5
+	}
+	g.Population, g.Employed = 50, 30
+	picks := map[ZoneType]int{}
+	for r := -20; r <= 40; r += 5 {
+		for c := -20; c <= 40; c += 5 {
+			for i := -20; i <= 40; i += 5 {
+				g.Demand = Demand{Residential: r, Commercial: c, Industrial: i}
+				picks[g.pickZoneTypeByDemand(botProfiles[strategy])]++
+			}
+		}
+	}
+	return picks
+}
+
+func TestBotProfilesPickDifferentZones(t *testing.T) {
+	balanced, residential := zonePicks("balanced"), zonePicks("residential-focused")
+	t.Logf("balanced %v, residential-focused %v", balanced, residential)
+	if residential[Residential] <= balanced[Residential] || residential[Commercial] >= balanced[Commercial] {
+		t.Fatalf("residential-focused picks %v, balanced %v", residential, balanced)
+	}
+}
+
+// roadsPerZone runs a room with a single bot of the given strategy for 60 ticks and returns the
+// road tiles it laid per zone.
+func roadsPerZone(strategy string) float64 {
+	r := newRoomFrom("bots", roomConfig{Seed: 7, Speed: 1, Bots: []string{strategy}, BotMoney: botMoney})
+	go r.hub.run()
+	defer r.hub.stop()
+	for i := 0; i < 60; i++ {
+		r.stepGame()
+	}
+	zones, roads := 0, 0
+	for _, row := range r.game.Tiles {
+		for _, t := range row {
+			if t.Zone != nil {
+				zones++
+			}
+			if t.Road != nil {
+				roads++
+			}
+		}
+	}
+	return float64(roads) / float64(max(zones, 1))
+}
+
+func TestRoadHeavyBotLaysMoreRoad(t *testing.T) {
+	if heavy, focused := roadsPerZone("road-heavy"), roadsPerZone("residential-focused"); heavy <= 2*focused {
+		t.Fatalf("road tiles per zone: road-heavy %.2f, residential-focused %.2f", heavy, focused)
+	}
+}
+
+func TestBotsDisabled(t *testing.T) {
+	if got := parseBotStrategies("none"); got != nil {
+		t.Fatalf("CITYSIM_BOTS=none gives %v", got)
+	}
+	if got := parseBotStrategies("road-heavy, nonsense,balanced"); len(got) != 2 || got[0] != "road-heavy" || got[1] != "balanced" {
+		t.Fatalf("CITYSIM_BOTS list parsed to %v", got)
+	}
+	r := testRoom(t) // started with no bots
+	g := r.game
+	if len(g.BotIDs) != 0 || len(g.Players) != 0 {
+		t.Fatalf("bots %v, players %d", g.BotIDs, len(g.Players))
+	}
+	for i := 0; i < 50; i++ {
+		g.Tick++
+		g.aiTick()
+	}
+	for _, row := range g.Tiles {
+		for _, tile := range row {
+			if developed(tile) {
+				t.Fatalf("something was built at (%d,%d) with no bots", tile.X, tile.Y)
+			}
+		}
+	}
+}
+
+func TestAddAndRemoveBotNeedTheAdminToken(t *testing.T) {
+	setAdminToken(t, "secret")
+	r := testRoom(t)
+	join(r, "p", 0)
+	c := probe(r, "p")
+	if reason := request(t, c, ActionAddBot, AddBotPayload{Strategy: "balanced"}); reason != ReasonUnauthorized {
+		t.Fatalf("add_bot without the token: reason %q", reason)
+	}
+	if reason := request(t, c, ActionAddBot, AddBotPayload{Token: "secret", Strategy: "road-heavy"}); reason != "" || len(r.game.BotIDs) != 1 {
+		t.Fatalf("add_bot with the token: %q, bots %v", reason, r.game.BotIDs)
+	}
+	bot := r.game.BotIDs[0]
+	build(r.game, 5, 5, Residential)
+	r.game.Tiles[5][5].Zone.Owner = bot
+	r.game.Tiles[5][4].Road = &Road{Owner: bot}
+
+	if reason := request(t, c, ActionRemoveBot, RemoveBotPayload{ID: bot}); reason != ReasonUnauthorized {
+		t.Fatalf("remove_bot without the token: reason %q", reason)
+	}
+	if reason := request(t, c, ActionRemoveBot, RemoveBotPayload{Token: "secret", ID: bot}); reason != "" {
+		t.Fatalf("remove_bot with the token: %q", reason)
+	}
+	if len(r.game.BotIDs) != 0 || r.game.Players[bot] != nil {
+		t.Fatal("bot still in the game")
+	}
+	if tile := r.game.Tiles[5][5]; tile.Zone != nil || tile.Building != nil {
+		t.Fatal("removed bot's zone left standing")
+	}
+	if r.game.Tiles[5][4].Road == nil {
+		t.Fatal("removed bot's road was demolished")
+	}
+}
+
+func TestZoneBiasShiftsBotZoning(t *testing.T) {
+	commercial, industrial := defaultSimConfig(), defaultSimConfig()
+	commercial.AICommercialBias, commercial.AIIndustrialBias = 30, 0
+	industrial.AICommercialBias, industrial.AIIndustrialBias = 0, 30
+	c, i := zonePicksUnder("balanced", commercial), zonePicksUnder("balanced", industrial)
+	if c[Commercial] <= i[Commercial] || i[Industrial] <= c[Industrial] {
+		t.Fatalf("commercial-biased picks %v, industrial-biased %v", c, i)
+	}
+}
+
+func TestUnknownBotStrategyIsIgnored(t *testing.T) {
+	r := newRoomFrom("bots", roomConfig{Seed: 7, Speed: 1, Bots: []string{"balanced", "industrial"}, BotMoney: botMoney})
+	if len(r.game.BotIDs) != 1 || r.game.Players[r.game.BotIDs[0]].Strategy != "balanced" {
+		t.Fatalf("bots %v, want the balanced one only", r.game.BotIDs)
+	}
+}
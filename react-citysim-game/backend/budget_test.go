@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBudgetReportSumsToMoneyDelta(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 10000)
+	other := probe(r, "other")
+	g := r.game
+	pl := g.Players["p"]
+	for x := 10; x < 15; x++ {
+		build(g, x, 11, Residential).Residents = maxResidents
+		g.Tiles[11][x].Zone.Owner = "p"
+		g.Tiles[11][x].LandValue = 60
+	}
+	g.Tiles[13][10].Structure = &Structure{Type: "park", Owner: "p"}
+	g.Tiles[13][12].Structure = &Structure{Type: "police_station", Owner: "p"}
+	g.Population, g.Employed = 5*maxResidents, 3*maxResidents
+	pl.Debt, pl.InterestRate = 4000, loanInterestRate
+	g.addTrade("p", 45)
+
+	before := pl.Money
+	g.economicTick()
+	g.reportBudgets()
+	var report struct {
+		Players map[PlayerID]Budget `json:"players"`
+	}
+	json.Unmarshal(nextEvent(t, other, EventBudgetReport), &report)
+	b := report.Players["p"]
+	if b.Income <= 0 || b.LandTax <= 0 || b.Trade != 45 || b.Maintenance >= 0 || b.Interest >= 0 {
+		t.Fatalf("budget %+v is missing a component", b)
+	}
+	if sum := b.Income + b.LandTax + b.Trade + b.Tourism + b.Maintenance + b.Interest + b.Refunds; sum != b.Net {
+		t.Fatalf("components sum to %d, net %d", sum, b.Net)
+	}
+	if delta := pl.Money - before; delta != b.Net {
+		t.Fatalf("money changed by %d, report says %d", delta, b.Net)
+	}
+}
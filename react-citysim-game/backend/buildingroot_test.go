@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildingUpdateNamesRootAndFootprint(t *testing.T) {
+	g := newGame(1)
+	for y := 5; y <= 6; y++ {
+		for x := 5; x <= 6; x++ {
+			g.Tiles[y][x].Building = &Building{Type: Commercial, Final: true, Size: 2, IsRoot: x == 5 && y == 5}
+		}
+	}
+	g.Tiles[2][2].Building = &Building{Type: Residential}
+	cs := newBuildingChangeSet()
+	cs.add(6, 6)
+	cs.add(2, 2)
+	cs.add(5, 5)
+	u := cs.snapshot(g)
+
+	want := [][2]int{{5, 5}, {6, 5}, {5, 6}, {6, 6}}
+	for _, i := range []int{0, 2} { // a member tile and the root itself
+		if u[i].Root != [2]int{5, 5} || !slices.Equal(u[i].Footprint, want) {
+			t.Errorf("update for (%d,%d): root %v footprint %v, want (5,5) and %v", u[i].X, u[i].Y, u[i].Root, u[i].Footprint, want)
+		}
+	}
+	if u[1].Root != [2]int{2, 2} || !slices.Equal(u[1].Footprint, [][2]int{{2, 2}}) {
+		t.Errorf("single tile: root %v footprint %v", u[1].Root, u[1].Footprint)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUpdateAndBulldozeInOneTickSendsOneUpdate(t *testing.T) {
+	g := newGame(1)
+	tl := g.Tiles[5][5]
+	tl.Zone = &Zone{Type: Residential}
+	tl.Building = &Building{Type: Residential, Stage: 1, Watered: true}
+	changes := newBuildingChangeSet()
+	g.progressBuildings(changes)
+	if len(changes.order) != 1 {
+		t.Fatalf("construction recorded %d changes, want 1", len(changes.order))
+	}
+	// later in the same tick something demolishes the building and records the tile again
+	tl.Building, tl.Zone = nil, nil
+	changes.add(5, 5)
+	updates := changes.snapshot(g)
+	b, err := json.Marshal(updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Building != nil || strings.Count(string(b), `"building":null`) != 1 {
+		t.Fatalf("updates %s, want one for (5,5) with building null", b)
+	}
+}
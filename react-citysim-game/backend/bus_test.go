@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestCommuterNearStopsRidesTheBus(t *testing.T) {
+	g := newGame(5)
+	roadLine(g, 5, 10, 40, 10)
+	build(g, 5, 9, Residential).Residents = 5
+	g.Tiles[9][5].Citizens = 5
+	build(g, 40, 9, Industrial)
+	g.Tiles[11][6].Structure = &Structure{Type: "bus_stop"}
+	g.Tiles[11][39].Structure = &Structure{Type: "bus_stop"}
+	g.Population = 5
+	g.Tick = morningStart
+	g.updateTransit()
+	if len(g.busRoutes) != 1 {
+		t.Fatalf("%d bus routes between two connected stops, want 1", len(g.busRoutes))
+	}
+	for i := 0; i < 50 && len(g.CitizenGroups) == 0; i++ {
+		g.spawnCitizenGroups()
+	}
+	if len(g.CitizenGroups) == 0 {
+		t.Fatal("no commute started")
+	}
+	grp := g.CitizenGroups[0]
+	if grp.Transit == nil {
+		t.Fatalf("commute %+v walks to no stop", grp)
+	}
+	rode := false
+	for i := 0; i < 3000 && grp.State != "working"; i++ {
+		g.updateCongestion()
+		g.updateTraffic(0.1)
+		g.updateCitizens(0.1)
+		g.syncCommuterCars()
+		for _, v := range g.Vehicles {
+			if v.GroupID == grp.ID {
+				t.Fatal("the bus commuter drove a car")
+			}
+		}
+		rode = rode || grp.Transit != nil && grp.Transit.Bus != 0
+	}
+	if !rode || grp.State != "working" {
+		t.Fatalf("commute ended %q, rode the bus %v", grp.State, rode)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCapacityProjectionMatchesGrownBuildings(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	join(r, "p", 100000)
+	for _, z := range []struct {
+		x0, x1 int
+		zone   ZoneType
+	}{{5, 6, Residential}, {7, 8, Commercial}, {9, 10, Industrial}} {
+		if reason := act(t, r, "p", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: z.x0, Y0: 5, X1: z.x1, Y1: 7, Zone: z.zone}); reason != "" {
+			t.Fatalf("zoning %s: %s", z.zone, reason)
+		}
+	}
+	query := QueryCapacityPayload{X0: 12, Y0: 8, X1: 4, Y1: 4} // corners in either order
+	before, reason := g.capacityReport(query)
+	if reason != "" || before.Residential != (ZoneCapacity{Zoned: 6, Capacity: 6 * maxResidents}) {
+		t.Fatalf("projection before growth %+v (%q)", before, reason)
+	}
+
+	for i := 0; i < 5; i++ {
+		g.progressBuildings(newBuildingChangeSet())
+		for _, lot := range g.tiles().lots {
+			if lot.Building != nil {
+				lot.Building.Watered = true
+			}
+		}
+	}
+	actual := map[ZoneType]int{}
+	for _, lot := range g.tiles().buildings {
+		b := lot.Building
+		if !b.Final {
+			t.Fatalf("building at (%d,%d) unfinished", lot.X, lot.Y)
+		}
+		if b.Type == Residential {
+			actual[b.Type] += maxResidents
+		} else {
+			actual[b.Type] += g.jobSlots(b)
+		}
+	}
+
+	c := probe(r, "p")
+	payload, _ := json.Marshal(query)
+	msg, _ := json.Marshal(Envelope{Type: ActionQueryCapacity, Payload: payload})
+	c.handleMessage(msg)
+	var after CapacityReport
+	json.Unmarshal(nextEvent(t, c, EventCapacity), &after)
+	projected := map[ZoneType]ZoneCapacity{Residential: before.Residential, Commercial: before.Commercial, Industrial: before.Industrial}
+	for zone, got := range map[ZoneType]ZoneCapacity{Residential: after.Residential, Commercial: after.Commercial, Industrial: after.Industrial} {
+		if got.Capacity != actual[zone] || got.Capacity != projected[zone].Capacity || got.Finished != 6 {
+			t.Errorf("%s: %+v after growth, %d actual, %d projected", zone, got, actual[zone], projected[zone].Capacity)
+		}
+	}
+
+	if _, reason := g.capacityReport(QueryCapacityPayload{X0: -5, Y0: -5, X1: -1, Y1: -1}); reason != ReasonOutOfBounds {
+		t.Fatalf("query off the map: %q, want %q", reason, ReasonOutOfBounds)
+	}
+}
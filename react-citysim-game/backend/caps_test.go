@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// crowdedMap is an n x n room with one road across the middle, homes full of residents along one
+// side and stocked factories and shops along the other.
+func crowdedMap(n int) *Room {
+	r := newRoomFrom("caps", roomConfig{Seed: 3, Speed: 1})
+	g := newGameSize(3, n, n)
+	g.hub = r.hub
+	r.game = g
+	roadLine(g, 0, n/2, n-1, n/2)
+	for x := 0; x < n; x++ {
+		build(g, x, n/2-1, Residential).Residents = maxResidents
+		typ := Commercial
+		if x%2 == 0 {
+			typ = Industrial
+		}
+		b := build(g, x, n/2+1, typ)
+		b.Stock, b.Supplies = trainCapacity, 1
+	}
+	g.Tick = morningStart
+	return r
+}
+
+func TestEntityCapsScaleWithTheMap(t *testing.T) {
+	r := crowdedMap(16)
+	go r.hub.run()
+	defer r.hub.stop()
+	g := r.game
+	if cars, big := g.areaCap(maxCars), newGame(1).areaCap(maxCars); cars >= big || cars != maxCars*16*16/capReferenceArea {
+		t.Fatalf("car cap %d on 16x16, %d on the reference map", cars, big)
+	}
+	ceiling := g.entityCeiling()
+	if ceiling != min(g.areaCap(maxEntitiesPerMap), maxEntities) || ceiling >= maxEntitiesPerMap {
+		t.Fatalf("ceiling %d on 16x16", ceiling)
+	}
+	peak := 0
+	for i := 0; i < 200; i++ {
+		r.stepGame()
+		for f := 0; f < 10; f++ {
+			r.trafficFrame(100 * time.Millisecond)
+			if n := g.entityCount(); n > ceiling {
+				t.Fatalf("tick %d: %d entities over the ceiling of %d", g.Tick, n, ceiling)
+			} else {
+				peak = max(peak, n)
+			}
+		}
+	}
+	if peak < ceiling/2 {
+		t.Fatalf("peak of %d entities never tested the ceiling of %d", peak, ceiling)
+	}
+}
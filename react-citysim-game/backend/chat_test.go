@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeChat(t *testing.T) {
+	text, ok := sanitizeChat("  hi\x00\x1b there\n" + strings.Repeat("é", 2*maxChatLen))
+	if !ok || !strings.HasPrefix(text, "hi there") || utf8.RuneCountInString(text) != maxChatLen {
+		t.Fatalf("sanitized to %d runes %.20q (ok %v)", utf8.RuneCountInString(text), text, ok)
+	}
+	if _, ok := sanitizeChat(" \t\x01 "); ok {
+		t.Fatal("a message of only spaces and control characters was accepted")
+	}
+}
+
+func TestChatReachesEveryone(t *testing.T) {
+	r := testRoom(t)
+	join(r, "a", 0)
+	join(r, "b", 0)
+	a, b := probe(r, "a"), probe(r, "b")
+	msg, _ := json.Marshal(Envelope{Type: ActionChat, Payload: json.RawMessage(`{"text":"hello"}`)})
+	a.handleMessage(msg)
+	for _, c := range []*Client{a, b} {
+		var msg ChatMessage
+		json.Unmarshal(nextEvent(t, c, EventChat), &msg)
+		if msg.ID != "a" || msg.Name != "a" || msg.Text != "hello" || msg.At == 0 {
+			t.Fatalf("%s got %+v", c.id, msg)
+		}
+	}
+	if reason := request(t, a, ActionChat, ChatPayload{Text: "\x00"}); reason != ReasonInvalidText {
+		t.Fatalf("empty chat: %q, want %q", reason, ReasonInvalidText)
+	}
+
+	late := probe(r, "c")
+	late.sendChatBacklog()
+	var backlog struct {
+		Messages []ChatMessage `json:"messages"`
+	}
+	json.Unmarshal(nextEvent(t, late, EventChatBacklog), &backlog)
+	if len(backlog.Messages) != 1 || backlog.Messages[0].Text != "hello" {
+		t.Fatalf("joiner backlog %+v", backlog.Messages)
+	}
+}
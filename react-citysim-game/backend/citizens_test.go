@@ -0,0 +1,122 @@
+package main
+
+import "testing"
+
+// commuteCity lays a road along y=10 with a home of 20 at (1,11) and industry near it at (4,11)
+// and far from it at (30,11), and sets the clock to the start of the morning rush.
+func commuteCity(g *GameState) (home, near, far [2]int) {
+	roadLine(g, 0, 10, 40, 10)
+	home, near, far = [2]int{1, 11}, [2]int{4, 11}, [2]int{30, 11}
+	build(g, home[0], home[1], Residential).Residents = 20
+	g.Tiles[home[1]][home[0]].Citizens = 20
+	build(g, near[0], near[1], Industrial)
+	build(g, far[0], far[1], Industrial)
+	g.Population = 20
+	g.Tick = morningStart
+	return
+}
+
+func TestCommutersPreferTheNearestJob(t *testing.T) {
+	g := newGame(1)
+	_, near, far := commuteCity(g)
+	// 19 commuters already heading home leave room for one new trip per spawn
+	busy := &CitizenGroup{Count: 19, State: "return", OriginX: 1, OriginY: 11}
+	picks := map[[2]int]int{}
+	for i := 0; i < 20; i++ {
+		g.CitizenGroups = []*CitizenGroup{busy}
+		g.spawnCitizenGroups()
+		for _, c := range g.CitizenGroups[1:] {
+			picks[[2]int{c.DestX, c.DestY}]++
+		}
+	}
+	if picks[near] <= picks[far] || picks[near] == 0 {
+		t.Fatalf("near job picked %d times, far job %d", picks[near], picks[far])
+	}
+
+	// once the near job is full, commuters go to the far one
+	full := &CitizenGroup{Count: g.Config.IndustrialCapacity, State: "working", DestX: near[0], DestY: near[1]}
+	g.CitizenGroups = []*CitizenGroup{busy, full}
+	g.spawnCitizenGroups()
+	if len(g.CitizenGroups) != 3 || g.CitizenGroups[2].DestX != far[0] {
+		t.Fatalf("with the near job full the new trip went to %+v", g.CitizenGroups[2:])
+	}
+}
+
+// citizensAt counts the citizens on tiles plus those travelling.
+func citizensAt(g *GameState) int {
+	n := 0
+	for _, row := range g.Tiles {
+		for _, t := range row {
+			n += t.Citizens
+		}
+	}
+	for _, c := range g.CitizenGroups {
+		if c.State == "outbound" || c.State == "return" {
+			n += c.Count
+		}
+	}
+	return n
+}
+
+func TestBulldozedRoadMidCommuteKeepsCitizens(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 0)
+	g := r.game
+	home, near, _ := commuteCity(g)
+	g.Tiles[near[1]][near[0]].Building = nil
+	g.index = nil
+	g.CitizenGroups = []*CitizenGroup{{Count: 19, State: "return", OriginX: home[0], OriginY: home[1]}}
+	g.spawnCitizenGroups()
+	g.CitizenGroups = g.CitizenGroups[1:]
+	if len(g.CitizenGroups) != 1 {
+		t.Fatalf("%d commutes started, want 1", len(g.CitizenGroups))
+	}
+	for c, i := g.CitizenGroups[0], 0; c.X < 10; i++ {
+		if i == 100 {
+			t.Fatalf("commuter stuck at (%.1f,%.1f)", c.X, c.Y)
+		}
+		g.updateCitizens(1)
+	}
+	act(t, r, "p", ActionBulldoze, BulldozePayload{X: 20, Y: 10})
+	for i := 0; i < 1000 && len(g.CitizenGroups) > 0; i++ {
+		g.Tick++
+		g.updateCitizens(1)
+		if n := citizensAt(g); n != 20 {
+			t.Fatalf("tick %d: %d citizens, want 20", i, n)
+		}
+	}
+	if len(g.CitizenGroups) != 0 {
+		t.Fatalf("commuters never got home: %+v", g.CitizenGroups[0])
+	}
+	if n := g.Tiles[home[1]][home[0]].Citizens; n != 20 {
+		t.Fatalf("%d citizens at home, want 20", n)
+	}
+}
+
+func TestCommuteCycleRestoresHomeCitizens(t *testing.T) {
+	g := newGame(1)
+	home, _, _ := commuteCity(g)
+	g.CitizenGroups = []*CitizenGroup{{Count: 19, State: "return", OriginX: home[0], OriginY: home[1]}}
+	g.spawnCitizenGroups()
+	g.CitizenGroups = g.CitizenGroups[1:]
+	c := g.CitizenGroups[0]
+	g.Tiles[home[1]][home[0]].Citizens = 17 // drifted; the tick's reconcile puts it right
+	seen := map[string]bool{}
+	for i := 0; i < 2000 && len(g.CitizenGroups) > 0; i++ {
+		g.Tick++
+		g.updateCitizens(1)
+		g.reconcileCitizens()
+		seen[c.State] = true
+		if len(g.CitizenGroups) > 0 {
+			if n := g.Tiles[home[1]][home[0]].Citizens; n != 19 {
+				t.Fatalf("tick %d (%s): %d at home, want 19", i, c.State, n)
+			}
+		}
+	}
+	if len(g.CitizenGroups) != 0 || !seen["working"] || !seen["return"] {
+		t.Fatalf("commute did not finish a full cycle: states %v", seen)
+	}
+	if n := g.Tiles[home[1]][home[0]].Citizens; n != 20 {
+		t.Fatalf("%d at home after the cycle, want 20", n)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+// departures counts the commutes started over 50 spawns at the given hour.
+func departures(hour int) int {
+	g := newGame(1)
+	commuteCity(g)
+	n := 0
+	for i := 0; i < 50; i++ {
+		g.Tick = int64(i*ticksPerDay + hour)
+		g.CitizenGroups = nil
+		g.spawnCitizenGroups()
+		n += len(g.CitizenGroups)
+	}
+	return n
+}
+
+func TestCommutersLeaveInTheMorning(t *testing.T) {
+	morning, night := departures(morningStart), departures(nightStart+1)
+	if morning == 0 || night*5 > morning {
+		t.Fatalf("departures: %d in the morning, %d at night", morning, night)
+	}
+}
+
+func TestSummaryHour(t *testing.T) {
+	g := newGame(1)
+	g.Tick = 3*ticksPerDay + 7
+	if h := g.gameSummary().Hour; h != 7 {
+		t.Fatalf("summary hour %d at tick %d, want 7", h, g.Tick)
+	}
+}
+
+func TestSeasonalDemandPeaks(t *testing.T) {
+	d := Demand{Residential: 50, Commercial: 50, Industrial: 50}
+	type peak struct {
+		value       int
+		first, last int64 // the rounded offset holds its top value for a while around the peak
+	}
+	best := map[string]*peak{}
+	for tick := int64(1); tick <= ticksPerYear; tick++ {
+		applySeason(tick, &d)
+		for name, v := range map[string]int{"residential": d.Residential, "commercial": d.Commercial, "industrial": d.Industrial} {
+			switch p := best[name]; {
+			case p == nil || v > p.value:
+				best[name] = &peak{v, tick, tick}
+			case v == p.value:
+				p.last = tick
+			}
+		}
+	}
+	for name, eighth := range map[string]int{"residential": residentialPeak, "commercial": commercialPeak, "industrial": industrialPeak} {
+		want := int64(eighth * ticksPerYear / 8)
+		if p := best[name]; (p.first+p.last)/2 < want-ticksPerDay || (p.first+p.last)/2 > want+ticksPerDay {
+			t.Errorf("%s demand peaked over ticks %d-%d, want about %d", name, p.first, p.last, want)
+		}
+	}
+	if d != (Demand{Residential: 50, Commercial: 50, Industrial: 50}) {
+		t.Fatalf("demand after a full year %+v, want back where it started", d)
+	}
+
+	high := Demand{Residential: 118, Commercial: -49, Industrial: 118}
+	for tick := int64(1); tick <= ticksPerYear; tick++ {
+		applySeason(tick, &high)
+		for _, v := range []int{high.Residential, high.Commercial, high.Industrial} {
+			if v < -50 || v > 120 {
+				t.Fatalf("tick %d: demand %+v outside -50..120", tick, high)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+// laborTicks runs n ticks of population and labor allocation only.
+func laborTicks(g *GameState, n int) {
+	for i := 0; i < n; i++ {
+		g.Tick++
+		g.simulateCitizens()
+		g.allocateLaborAndSupplies(newBuildingChangeSet())
+	}
+}
+
+func TestWorkersTakeTheNearestJob(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 0, 10, 40, 10)
+	home := build(g, 1, 11, Residential)
+	home.Residents = 1
+	far := build(g, 25, 9, Industrial) // scanned first, so only distance puts near ahead
+	near := build(g, 4, 11, Industrial)
+	for i := 0; i < 5; i++ {
+		laborTicks(g, 1)
+		if near.Employees != 1 || far.Employees != 0 {
+			t.Fatalf("tick %d: the only worker is at near %d, far %d", i, near.Employees, far.Employees)
+		}
+	}
+}
+
+func TestJobsTooFarFromHousingAbandon(t *testing.T) {
+	g := newGame(1)
+	g.hub = newHub()
+	go g.hub.run()
+	defer g.hub.stop()
+	roadLine(g, 0, 10, 60, 10)
+	home := build(g, 1, 11, Residential)
+	home.Residents = maxResidents
+	near := build(g, 4, 11, Industrial)
+	far := build(g, 4+maxCommuteDistance+10, 11, Industrial)
+	for i := 0; i < 50 && far.AbandonPhase == 0; i++ {
+		laborTicks(g, 1)
+		if far.Employees != 0 {
+			t.Fatalf("a job %d road tiles from housing has %d employees", maxCommuteDistance+10, far.Employees)
+		}
+	}
+	if far.AbandonPhase == 0 || far.AbandonReason != AbandonCommuteTooFar {
+		t.Fatalf("far industry: phase %d reason %q, want %q", far.AbandonPhase, far.AbandonReason, AbandonCommuteTooFar)
+	}
+	if near.AbandonPhase != 0 || near.Employees == 0 {
+		t.Fatalf("near industry: phase %d employees %d", near.AbandonPhase, near.Employees)
+	}
+}
+
+func TestOneWayRoadsLimitCommutes(t *testing.T) {
+	for _, dir := range []RoadDirection{DirEast, DirWest} {
+		g := newGame(1)
+		roadLine(g, 0, 10, 20, 10)
+		g.Tiles[10][6].Road.Direction = dir
+		home := build(g, 2, 11, Residential)
+		home.Residents = maxResidents
+		build(g, 10, 11, Industrial)
+		shop := build(g, 11, 11, Commercial)
+		reachable := dir == DirEast // from the homes to the jobs, with the flow
+		if _, ok := g.commuteDistances()[[2]int{10, 10}]; ok != reachable {
+			t.Errorf("%s: job road reached from housing: %v, want %v", dir, ok, reachable)
+		}
+		if _, ok := g.jobDistances()[[2]int{2, 10}]; ok != reachable {
+			t.Errorf("%s: housing road has a job in reach: %v, want %v", dir, ok, reachable)
+		}
+		if got := g.shopCustomers()[shop] > 0; got != reachable {
+			t.Errorf("%s: shop has customers: %v, want %v", dir, got, reachable)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCommuteDrivesALinkedCar(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	commuteCity(g)
+	g.spawnCitizenGroups()
+	g.syncCommuterCars()
+	var group *CitizenGroup
+	for _, cg := range g.CitizenGroups {
+		if cg.State == "outbound" {
+			group = cg
+		}
+	}
+	if group == nil {
+		t.Fatal("no commute started")
+	}
+	var car *Vehicle
+	for _, v := range g.Vehicles {
+		if v.GroupID == group.ID {
+			car = v
+		}
+	}
+	if car == nil || car.X != group.X || car.Y != group.Y {
+		t.Fatalf("commute %d at (%.1f,%.1f) has car %+v", group.ID, group.X, group.Y, car)
+	}
+
+	c := probe(r, "watcher")
+	g.trafficFrame = trafficKeyframeEvery - 1 // a full update, whatever the delta setting
+	g.broadcastTraffic()
+	var update struct {
+		Vehicles []TrafficEntity `json:"vehicles"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventTrafficUpdate), &update)
+	for _, v := range update.Vehicles {
+		if v.ID == car.ID && v.GroupID == group.ID {
+			return
+		}
+	}
+	t.Fatalf("broadcast vehicles %+v carry no groupId %d", update.Vehicles, group.ID)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentSnapshotsDuringTicks reads the room through every read-only path while ticks,
+// traffic frames and actions run. It asserts little by itself; run it with `go test -race` to
+// catch writes hidden in the read paths.
+func TestConcurrentSnapshotsDuringTicks(t *testing.T) {
+	r := newRoomFrom("race", roomConfig{Seed: 3, Speed: 1, Bots: []string{"balanced"}, BotMoney: botMoney})
+	go r.hub.run()
+	t.Cleanup(r.hub.stop)
+	listRoom(t, r)
+	srv := newTestServer(t)
+	join(r, "p", 100000)
+	c := probe(r, "p")
+	go func() { // keep the probe's queue from filling and getting it dropped
+		for range c.send {
+		}
+	}()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 40; i++ {
+			r.input(journalEntry{Kind: journalStep})
+			for f := 0; f < 3; f++ {
+				r.input(journalEntry{Kind: journalFrame, Elapsed: 100 * time.Millisecond})
+			}
+			act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: i % 64, Y: 20, Zone: Residential})
+		}
+	}()
+	readers := []func(){
+		func() { c.sendFullState() },
+		func() { c.sendStateDiff(nil) },
+		func() { c.sendTileInfo(InspectTilePayload{X: 5, Y: 20}) },
+		func() { c.sendOwnership() },
+		func() { c.sendLeaderboard() },
+		func() { c.sendRoster() },
+	}
+	for _, path := range []string{"/state?room=race", "/summary?room=race", "/history?room=race", "/metrics"} {
+		readers = append(readers, func() {
+			resp, err := http.Get(srv.URL + path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		})
+	}
+	for _, read := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					read()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
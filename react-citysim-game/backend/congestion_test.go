@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// corridorProgress drives n cars together down a straight road for frames traffic frames and
+// returns how far the first one got.
+func corridorProgress(n, frames int) float64 {
+	g := newGame(1)
+	roadLine(g, 0, 5, 40, 5)
+	path := make([][2]int, 0, 40)
+	for x := 1; x <= 40; x++ {
+		path = append(path, [2]int{x, 5})
+	}
+	for i := 0; i < n; i++ {
+		g.Vehicles = append(g.Vehicles, &Vehicle{ID: int64(i + 1), X: 0, Y: 5, Path: path, Kind: VehicleCar})
+	}
+	lead := g.Vehicles[0]
+	for f := 0; f < frames; f++ {
+		g.updateCongestion()
+		g.updateTraffic(0.1)
+	}
+	return lead.X
+}
+
+func TestCongestionSlowsACorridor(t *testing.T) {
+	alone, crowded := corridorProgress(1, 20), corridorProgress(12, 20)
+	if crowded >= alone {
+		t.Fatalf("12 cars together got %.2f tiles, a lone car %.2f", crowded, alone)
+	}
+}
+
+func TestCongestionFactor(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 0, 5, 10, 5)
+	g.Congestion = map[[2]int]int{{3, 5}: congestionThreshold, {4, 5}: congestionThreshold * 2}
+	if f := g.congestionFactor(3, 5); f != 1 {
+		t.Fatalf("factor at the threshold %v, want 1", f)
+	}
+	if f := g.congestionFactor(4, 5); f != 0.5 {
+		t.Fatalf("factor at twice the threshold %v, want 0.5", f)
+	}
+}
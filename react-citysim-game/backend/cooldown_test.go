@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestBulldozeCooldown(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	zone := PlaceZonePayload{X: 5, Y: 5, Zone: Residential}
+	act(t, r, "p", ActionPlaceZone, zone)
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 5, Y: 5}); reason != "" {
+		t.Fatalf("first bulldoze: %s", reason)
+	}
+	act(t, r, "p", ActionPlaceZone, zone)
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 5, Y: 5}); reason != ReasonCooldown {
+		t.Fatalf("second bulldoze within the cooldown: %q, want %q", reason, ReasonCooldown)
+	}
+	if r.game.Tiles[5][5].Zone == nil {
+		t.Fatal("the throttled bulldoze removed the zone")
+	}
+	r.game.Tick += bulldozeCooldownTicks
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 5, Y: 5}); reason != "" {
+		t.Fatalf("bulldoze after the cooldown: %s", reason)
+	}
+}
+
+func TestBulldozeRateLimitIsSeparate(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	c := probe(r, "p")
+	for i := 0; i < int(bulldozeBurst); i++ {
+		if reason := request(t, c, ActionBulldoze, BulldozePayload{X: i, Y: 5}); reason != "" {
+			t.Fatalf("bulldoze %d: %s", i, reason)
+		}
+	}
+	if reason := request(t, c, ActionBulldoze, BulldozePayload{X: 30, Y: 5}); reason != ReasonRateLimited {
+		t.Fatalf("bulldoze past the burst: %q, want %q", reason, ReasonRateLimited)
+	}
+	if reason := request(t, c, ActionPlaceZone, PlaceZonePayload{X: 30, Y: 6, Zone: Residential}); reason != "" {
+		t.Fatalf("placing while bulldozing is throttled: %s", reason)
+	}
+}
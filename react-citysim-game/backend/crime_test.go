@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// crimeAt runs updateCrime on a city of contented homes at (10,10) and (40,40) with the given
+// employment and an optional police station at (10,12), and returns the crime at each home.
+func crimeAt(employed int, police bool) (near, far int) {
+	g := newGame(1)
+	build(g, 10, 10, Residential)
+	build(g, 40, 40, Residential)
+	if police {
+		g.Tiles[12][10].Structure = &Structure{Type: "police_station"}
+	}
+	g.Population, g.Employed, g.Happiness = 100, employed, 50
+	g.updateCrime()
+	return g.Tiles[10][10].Crime, g.Tiles[40][40].Crime
+}
+
+func TestUnemploymentRaisesCrime(t *testing.T) {
+	_, working := crimeAt(100, false)
+	_, idle := crimeAt(0, false)
+	if working != 0 || idle != crimeUnemployment {
+		t.Fatalf("crime %d at full employment, %d at none; want 0 and %d", working, idle, crimeUnemployment)
+	}
+}
+
+func TestPoliceSuppressCrimeInRange(t *testing.T) {
+	near, far := crimeAt(0, true)
+	if near >= crimeUnemployment {
+		t.Fatalf("crime beside the police station %d, want below %d", near, crimeUnemployment)
+	}
+	if far != crimeUnemployment {
+		t.Fatalf("crime out of the station's range %d, want %d", far, crimeUnemployment)
+	}
+}
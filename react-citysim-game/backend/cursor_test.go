@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCursorReachesOthersOnly(t *testing.T) {
+	r := testRoom(t)
+	join(r, "a", 0)
+	join(r, "b", 0)
+	r.game.Players["a"].Color = "#ff0000"
+	a, b := probe(r, "a"), probe(r, "b")
+	move := func(x, y int) {
+		msg, _ := json.Marshal(Envelope{Type: ActionCursor, Payload: json.RawMessage(fmt.Sprintf(`{"x":%d,"y":%d}`, x, y))})
+		a.handleMessage(msg)
+	}
+	move(5, 6)
+	move(7, 8) // within cursorInterval: dropped
+
+	var got Cursor
+	json.Unmarshal(nextEvent(t, b, EventCursor), &got)
+	if got != (Cursor{ID: "a", Color: "#ff0000", X: 5, Y: 6}) {
+		t.Fatalf("b saw cursor %+v", got)
+	}
+	if slices.Contains(drain(t, r, b), EventCursor) {
+		t.Fatal("a throttled cursor move was rebroadcast")
+	}
+	if slices.Contains(drain(t, r, a), EventCursor) {
+		t.Fatal("the cursor was echoed to its sender")
+	}
+
+	r.dropCursor("a")
+	var gone Cursor
+	json.Unmarshal(nextEvent(t, b, EventCursor), &gone)
+	if gone != (Cursor{ID: "a", Gone: true}) {
+		t.Fatalf("after dropping, b saw %+v", gone)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestShopsNeedCustomersInReach(t *testing.T) {
+	g := newGame(3)
+	roadLine(g, 1, 10, 8, 10)
+	roadLine(g, 20, 10, 40, 10) // a separate network with no homes on it
+	home := build(g, 2, 11, Residential)
+	home.Residents = maxResidents
+	near := build(g, 6, 11, Commercial)
+	far := build(g, 30, 11, Commercial)
+	if c := g.shopCustomers(); c[near] != maxResidents || c[far] != 0 {
+		t.Fatalf("customers: %d near the homes, %d out of reach; want %d and 0", c[near], c[far], maxResidents)
+	}
+
+	for i := 0; i < 40 && g.Tiles[11][30].Building != nil; i++ {
+		near.Supplies, far.Supplies = 5, 5 // stocked alike, so only customers differ
+		g.Tick++
+		g.simulateCitizens()
+		g.allocateLaborAndSupplies(newBuildingChangeSet())
+	}
+	if g.Tiles[11][30].Building != nil {
+		t.Fatal("the shop with no customers in reach did not abandon")
+	}
+	if g.Tiles[11][6].Building != near || near.AbandonPhase > 0 || near.IdleTicks > 0 {
+		t.Fatalf("the shop near the homes is failing: %+v", g.Tiles[11][6].Building)
+	}
+}
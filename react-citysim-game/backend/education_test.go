@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// goodsMade runs 60 ticks of a full home supplying workers to industry next door, with a school
+// covering the home if schooled, and returns the goods produced and the home's education level.
+func goodsMade(schooled bool) (goods, education int) {
+	g := newGame(1)
+	roadLine(g, 0, 10, 10, 10)
+	home := build(g, 2, 11, Residential)
+	home.Residents = maxResidents
+	ind := build(g, 6, 11, Industrial)
+	if schooled {
+		g.Tiles[13][2].Structure = &Structure{Type: "school"}
+	}
+	for i := 0; i < 60; i++ {
+		ind.Stock = 0
+		g.Tick++
+		g.updateEducation(newBuildingChangeSet())
+		g.simulateCitizens()
+		g.allocateLaborAndSupplies(newBuildingChangeSet())
+		goods += ind.Stock
+	}
+	return goods, home.EducationLevel
+}
+
+func TestEducatedWorkersMakeMoreGoods(t *testing.T) {
+	plain, level := goodsMade(false)
+	if level != 0 {
+		t.Fatalf("unschooled home reached education %d", level)
+	}
+	schooled, level := goodsMade(true)
+	if level != maxEducation {
+		t.Fatalf("schooled home reached education %d, want %d", level, maxEducation)
+	}
+	if schooled <= plain {
+		t.Fatalf("industry made %d goods with schooled workers, %d without", schooled, plain)
+	}
+}
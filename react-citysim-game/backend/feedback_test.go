@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// queued decodes the envelopes waiting in c's send channel.
+func queued(c *Client) []Envelope {
+	var out []Envelope
+	for {
+		select {
+		case b := <-c.send:
+			var env Envelope
+			json.Unmarshal(b, &env)
+			out = append(out, env)
+		default:
+			return out
+		}
+	}
+}
+
+func TestActionErrorGoesToTheActorOnly(t *testing.T) {
+	r := testRoom(t)
+	join(r, "poor", zoneCost-1)
+	join(r, "other", 100000)
+	poor, other := probe(r, "poor"), probe(r, "other")
+
+	msg, _ := json.Marshal(Envelope{Type: ActionPlaceZone, Payload: json.RawMessage(`{"x":5,"y":5,"zone":"R"}`)})
+	poor.handleMessage(msg)
+	var e ActionError
+	json.Unmarshal(nextEvent(t, poor, EventActionError), &e)
+	if e.Action != ActionPlaceZone || e.Reason != ReasonInsufficientFunds {
+		t.Fatalf("action_error %+v, want place_zone insufficient_funds", e)
+	}
+	// the hub delivers in order, so anything sent alongside the error has reached other by now
+	for _, env := range queued(other) {
+		if env.Type == EventActionError {
+			t.Fatalf("bystander got %s", env.Payload)
+		}
+	}
+	if r.game.Tiles[5][5].Zone != nil {
+		t.Fatal("zone placed without funds")
+	}
+}
+
+func TestAcksCarryTheSequence(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 2*zoneCost)
+	c := probe(r, "p")
+	send := func(seq uint64, x int) {
+		msg, _ := json.Marshal(Envelope{Type: ActionPlaceZone, Seq: seq, Payload: json.RawMessage(fmt.Sprintf(`{"x":%d,"y":5,"zone":"R"}`, x))})
+		c.handleMessage(msg)
+	}
+	send(0, 4)  // no seq, so no ack
+	send(42, 5) // spends the last of the money
+	send(43, 6)
+	for _, want := range []Ack{{Seq: 42, OK: true}, {Seq: 43, Reason: ReasonInsufficientFunds}} {
+		var got Ack
+		json.Unmarshal(nextEvent(t, c, EventAck), &got)
+		if got != want {
+			t.Fatalf("ack %+v, want %+v", got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+// fireRun burns a 5x5 block of houses around (24,24) for 10 ticks from one fire at (23,24), with a
+// fire station at the centre if covered, and returns how many other houses caught fire.
+func fireRun(seed int64, covered bool) int {
+	r := newRoomFrom("fire", roomConfig{Seed: seed, Speed: 1})
+	go r.hub.run()
+	defer r.hub.stop()
+	g := r.game
+	for y := 22; y <= 26; y++ {
+		for x := 22; x <= 26; x++ {
+			build(g, x, y, Residential).Residents = 3
+		}
+	}
+	if covered {
+		g.Tiles[24][24].Zone, g.Tiles[24][24].Building = nil, nil
+		g.Tiles[24][24].Structure = &Structure{Type: "fire_station"}
+	}
+	g.Tiles[24][23].Building.OnFire = 1
+	for i := 0; i < 10; i++ {
+		g.fireTick(newBuildingChangeSet())
+	}
+	caught := 0
+	for y := 22; y <= 26; y++ {
+		for x := 22; x <= 26; x++ {
+			t := g.Tiles[y][x]
+			if t.Structure == nil && (x != 23 || y != 24) && (t.Building == nil || t.Building.OnFire > 0) {
+				caught++
+			}
+		}
+	}
+	return caught
+}
+
+func TestFireSpreadsWithoutCoverage(t *testing.T) {
+	bare, covered := 0, 0
+	for seed := int64(1); seed <= 20; seed++ {
+		bare += fireRun(seed, false)
+		covered += fireRun(seed, true)
+	}
+	if bare == 0 {
+		t.Fatal("fire never spread without coverage")
+	}
+	if covered >= bare {
+		t.Fatalf("%d houses caught fire near a station, %d without one", covered, bare)
+	}
+	t.Logf("houses caught: %d uncovered, %d covered", bare, covered)
+}
+
+func TestFireEngineReachesOnlyConnectedFires(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	roadLine(g, 0, 5, 10, 5)
+	g.Tiles[4][1].Structure = &Structure{Type: "fire_station"}
+	reachable := build(g, 8, 4, Residential)
+	cutOff := build(g, 20, 20, Residential)
+	reachable.OnFire, cutOff.OnFire = 1, 1
+
+	g.fireTick(newBuildingChangeSet())
+	engines := 0
+	for _, v := range g.Vehicles {
+		if v.Kind == VehicleFire {
+			engines++
+			if v.Target != [2]int{8, 4} {
+				t.Fatalf("engine sent to %v", v.Target)
+			}
+		}
+	}
+	if engines != 1 {
+		t.Fatalf("%d fire engines dispatched, want 1", engines)
+	}
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 10; k++ {
+			g.updateTraffic(0.1)
+		}
+		g.fireTick(newBuildingChangeSet())
+	}
+	if reachable.OnFire != 0 || g.Tiles[4][8].Building != reachable {
+		t.Fatalf("connected fire not put out: %+v", g.Tiles[4][8].Building)
+	}
+	if cutOff.OnFire == 0 {
+		t.Fatal("fire with no road to it was put out")
+	}
+}
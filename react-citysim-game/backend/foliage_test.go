@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestFoliageRegrowsOnVacantGrassOnly(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	join(r, "p", 100000)
+	for _, row := range g.Tiles {
+		for _, tile := range row {
+			tile.Foliage = ""
+		}
+	}
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 10, Y: 10, Zone: Residential}); reason != "" {
+		t.Fatalf("zone: %s", reason)
+	}
+	roadLine(g, 5, 5, 15, 5)
+	lake := g.Tiles[20][20]
+	lake.Terrain = "water"
+	for i := 0; i < 5000; i++ {
+		g.regrowFoliage()
+	}
+	grown := 0
+	for _, row := range g.Tiles {
+		for _, tile := range row {
+			if tile.Foliage == "" {
+				continue
+			}
+			if developed(tile) || tile.Terrain == "water" {
+				t.Fatalf("foliage %q grew on (%d,%d)", tile.Foliage, tile.X, tile.Y)
+			}
+			grown++
+		}
+	}
+	if grown == 0 {
+		t.Fatal("no cleared grass regrew foliage")
+	}
+}
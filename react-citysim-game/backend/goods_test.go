@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+// shopStreet is a road along y=10 with a full home at (2,11) and a stocked shop at (6,11).
+func shopStreet(g *GameState) (home, shop *Building) {
+	roadLine(g, 0, 10, 30, 10)
+	home = build(g, 2, 11, Residential)
+	home.Residents = maxResidents
+	shop = build(g, 6, 11, Commercial)
+	shop.Supplies = g.Config.MaxCommercialSupplies
+	return home, shop
+}
+
+func TestShopWithoutShipmentsAbandons(t *testing.T) {
+	g := newGame(1)
+	g.hub = newHub()
+	go g.hub.run()
+	defer g.hub.stop()
+	_, shop := shopStreet(g)
+	ranDry := false
+	for i := 0; i < 500 && shop.AbandonPhase == 0; i++ {
+		laborTicks(g, 1)
+		g.reconcileCitizens()
+		if len(g.GoodsIC)+len(g.GoodsCC) != 0 {
+			t.Fatal("shipments appeared with no industry")
+		}
+		if shop.Supplies == 0 {
+			ranDry = true
+		}
+	}
+	if !ranDry || shop.AbandonPhase == 0 || shop.AbandonReason != AbandonNotViable {
+		t.Fatalf("shop ran dry %v, abandon phase %d reason %q", ranDry, shop.AbandonPhase, shop.AbandonReason)
+	}
+}
+
+func TestShipmentRestocksShop(t *testing.T) {
+	g := newGame(1)
+	_, shop := shopStreet(g)
+	shop.Supplies = 0
+	g.GoodsIC = []*GoodShipment{{X: 5, Y: 10, Path: [][2]int{{5, 10}, {6, 10}}, Kind: "IC", ToX: 6, ToY: 11, Units: 3}}
+	for i := 0; i < 20 && len(g.GoodsIC) > 0; i++ {
+		g.updateGoods(1)
+	}
+	if len(g.GoodsIC) != 0 || shop.Supplies != 3 {
+		t.Fatalf("after delivery: %d shipments left, supplies %d, want 3", len(g.GoodsIC), shop.Supplies)
+	}
+}
+
+// exportRun runs goods and the economy for an industry owned by p at (5,11) with full stock and no
+// shops, on a road along y=10 from x0 to 8, and returns p's trade income.
+func exportRun(x0 int) int {
+	g := newGame(1)
+	g.Players["p"] = &Player{ID: "p"}
+	roadLine(g, x0, 10, 8, 10)
+	ind := build(g, 5, 11, Industrial)
+	g.Tiles[11][5].Zone.Owner = "p"
+	for i := 0; i < 100; i++ {
+		g.Tick = morningStart + int64(i%8) // daytime, when goods always move
+		ind.Stock = maxIndustrialStock
+		g.spawnGoodsShipments()
+		g.updateGoods(1)
+		g.economicTick()
+	}
+	return g.budget("p").Trade
+}
+
+func TestIsolatedIndustryExports(t *testing.T) {
+	if income := exportRun(0); income <= 0 {
+		t.Fatalf("industry with a road to the map edge earned %d from exports", income)
+	}
+	if income := exportRun(2); income != 0 {
+		t.Fatalf("industry with no road to the edge earned %d from exports", income)
+	}
+}
+
+// trucksFor counts the trucks spawnVehicles keeps on a street of shops and industry while n road
+// shipments and n rail shipments are under way.
+func trucksFor(n int) int {
+	g := newGame(1)
+	roadLine(g, 0, 10, 40, 10)
+	for x := 2; x < 40; x += 4 {
+		build(g, x, 11, Industrial)
+		build(g, x, 9, Commercial)
+	}
+	for i := 0; i < n; i++ {
+		g.GoodsIC = append(g.GoodsIC, &GoodShipment{Kind: "IC"}, &GoodShipment{Kind: "IC", Train: true})
+	}
+	for i := 0; i < 10; i++ {
+		g.spawnVehicles()
+	}
+	trucks := 0
+	for _, v := range g.Vehicles {
+		if v.Kind == VehicleTruck {
+			trucks++
+		}
+	}
+	return trucks
+}
+
+func TestTrucksFollowIndustrialActivity(t *testing.T) {
+	for _, shipments := range []int{0, 4 * goodsPerTruck, 8 * goodsPerTruck} {
+		if got, want := trucksFor(shipments), shipments/goodsPerTruck; got != want {
+			t.Errorf("%d road shipments: %d trucks, want %d", shipments, got, want)
+		}
+	}
+}
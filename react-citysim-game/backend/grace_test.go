@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+// abandonAge completes a home nobody moves into, with the given grace period and an abandon
+// trigger of 5 idle ticks, and returns how many ticks after completion it starts abandoning.
+func abandonAge(t *testing.T, grace int) int64 {
+	g := newGame(3)
+	g.Config.AbandonGraceTicks, g.Config.AbandonTriggerTicksBase = grace, 5
+	roadLine(g, 0, 10, 20, 10)
+	tl := g.Tiles[11][4]
+	tl.Zone = &Zone{Type: Residential}
+	g.index = nil
+	step := func() {
+		g.Tick++
+		if tl.Building != nil {
+			tl.Building.Watered = true
+		}
+		changes := newBuildingChangeSet()
+		g.progressBuildings(changes)
+		g.allocateLaborAndSupplies(changes)
+	}
+	for i := 0; i < 100 && (tl.Building == nil || !tl.Building.Final); i++ {
+		step()
+	}
+	b := tl.Building
+	if b == nil || !b.Final {
+		t.Fatal("home never completed")
+	}
+	for i := 0; i < 100 && b.AbandonPhase == 0; i++ {
+		step()
+	}
+	if b.AbandonPhase == 0 {
+		t.Fatalf("grace %d: empty home never started abandoning", grace)
+	}
+	return g.Tick - b.CompletedTick
+}
+
+func TestGraceWindowDelaysAbandonment(t *testing.T) {
+	without := abandonAge(t, 0)
+	with := abandonAge(t, 20)
+	if without >= 20 {
+		t.Fatalf("without grace the empty home lasted %d ticks", without)
+	}
+	// it outlives the window, then abandons once the usual idle ticks accrue
+	if with < 20 || with > 20+without {
+		t.Fatalf("with 20 ticks of grace it started abandoning %d ticks after completion, %d without", with, without)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+// pendingCity is a 128x128 map of road rows with a row of homes on varied land below each,
+// awaiting 200 applicants.
+func pendingCity() *GameState {
+	g := newGameSize(1, 128, 128)
+	for y := 0; y+1 < g.Height; y += 4 {
+		roadLine(g, 0, y, g.Width-1, y)
+		for x := 0; x < g.Width; x++ {
+			build(g, x, y+1, Residential)
+			g.Tiles[y+1][x].LandValue = (x*7 + y*13) % 50
+		}
+	}
+	return g
+}
+
+// BenchmarkGrowthTick places 200 pending residents through the open-home heap.
+func BenchmarkGrowthTick(b *testing.B) {
+	g := pendingCity()
+	changes := newBuildingChangeSet()
+	for i := 0; i < b.N; i++ {
+		g.PendingResidents, g.CitizenGroups = make([]int, 200), nil
+		g.growthTick(changes)
+		if len(g.CitizenGroups) == 0 {
+			b.Fatal("no applicant found a home")
+		}
+	}
+}
+
+// BenchmarkGrowthTickFullScan places the same 200 residents the way growthTick used to, scanning
+// the whole grid for the best open home once per applicant, for comparison.
+func BenchmarkGrowthTickFullScan(b *testing.B) {
+	g := pendingCity()
+	for i := 0; i < b.N; i++ {
+		placed := map[*Tile]int{}
+		for range 200 {
+			var best *Tile
+			for _, row := range g.Tiles {
+				for _, t := range row {
+					if bl := t.Building; bl != nil && bl.Final && bl.Type == Residential && bl.Residents+placed[t] < maxResidents && (best == nil || t.LandValue > best.LandValue) {
+						best = t
+					}
+				}
+			}
+			placed[best]++
+		}
+	}
+}
+
+func TestOpenHomesAreKeptAcrossTicks(t *testing.T) {
+	g, home := newcomerTown(true)
+	homeTile := g.Tiles[11][10]
+	if !g.openHomes()[homeTile] {
+		t.Fatal("empty home not open")
+	}
+	// a home finishing later is filed as it completes, without a rescan
+	lot := g.Tiles[11][12]
+	lot.Zone = &Zone{Type: Residential}
+	g.index = nil
+	for i := 0; i < 50 && (lot.Building == nil || !lot.Building.Final); i++ {
+		g.Tick++
+		if lot.Building != nil {
+			lot.Building.Watered = true
+		}
+		g.progressBuildings(newBuildingChangeSet())
+	}
+	if lot.Building == nil || !lot.Building.Final || !g.homes[lot] {
+		t.Fatalf("finished home %+v not open", lot.Building)
+	}
+
+	home.Residents = maxResidents
+	g.growthTick(newBuildingChangeSet())
+	if g.homes[homeTile] {
+		t.Fatal("full home still open")
+	}
+	for _, grp := range g.CitizenGroups {
+		if grp.DestX == 10 {
+			t.Fatal("newcomers sent to a full home")
+		}
+	}
+	g.Population = maxResidents
+	for i := 0; i < 200 && !g.homes[homeTile]; i++ { // unhappy households move away
+		homeTile.Happiness = 0
+		g.employmentDemandAdjust(newBuildingChangeSet())
+	}
+	if !g.homes[homeTile] {
+		t.Fatal("home a resident left is not open again")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+// homeHappiness is the happiness of a full home at (10,11) on a street with a job, after the
+// structure kind (if any) is put at (10,13).
+func homeHappiness(t *testing.T, kind, plant string) int {
+	g := newGame(1)
+	roadLine(g, 0, 10, 20, 10)
+	build(g, 10, 11, Residential).Residents = maxResidents
+	build(g, 14, 11, Commercial)
+	if kind != "" {
+		g.Tiles[13][10].Structure = &Structure{Type: kind, Plant: plant}
+	}
+	g.simulateCitizens()
+	g.updateLandValue()
+	g.updateHappiness()
+	if g.Happiness != g.Tiles[11][10].Happiness {
+		t.Fatalf("city happiness %d, but its only home's is %d", g.Happiness, g.Tiles[11][10].Happiness)
+	}
+	return g.Happiness
+}
+
+func TestPollutionLowersHappiness(t *testing.T) {
+	base, polluted := homeHappiness(t, "", ""), homeHappiness(t, "power_plant", "coal")
+	if polluted >= base {
+		t.Fatalf("happiness beside a coal plant %d, without %d", polluted, base)
+	}
+}
+
+func TestParkRaisesHappiness(t *testing.T) {
+	base, park := homeHappiness(t, "", ""), homeHappiness(t, "park", "")
+	if park <= base {
+		t.Fatalf("happiness beside a park %d, without %d", park, base)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestHeadingTurnsSmoothlyRoundACorner(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 3, 10, 5, 10)
+	roadLine(g, 5, 10, 5, 12)
+	v := &Vehicle{ID: 1, X: 3, Y: 10, Path: [][2]int{{4, 10}, {5, 10}, {5, 11}, {5, 12}}, Kind: VehicleCar}
+	g.Vehicles = []*Vehicle{v}
+	last, turning := 0.0, 0
+	px, py := 3.0, 10.0
+	for i := 0; i < 200 && len(g.Vehicles) > 0; i++ {
+		g.updateTraffic(0.05)
+		x, y, h := g.vehiclePose(v)
+		if i == 0 && h != 0 {
+			t.Fatalf("heading %v heading east, want 0", h)
+		}
+		if h < last-1e-9 {
+			t.Fatalf("heading swung back from %v to %v", last, h)
+		}
+		if h > 1 && h < 89 {
+			turning++
+		}
+		if step := math.Hypot(x-px, y-py); step > 0.2 {
+			t.Fatalf("jumped %v to (%v,%v)", step, x, y)
+		}
+		last, px, py = h, x, y
+	}
+	if math.Abs(v.Heading-90) > 1e-6 || turning < 3 {
+		t.Fatalf("ended heading %v after %d frames mid-turn, want 90 after a gradual turn", v.Heading, turning)
+	}
+}
+
+func TestNewVehiclesFadeIn(t *testing.T) {
+	r := testRoom(t)
+	c := probe(r, "watcher")
+	r.game.Vehicles = []*Vehicle{{ID: 1, X: 3, Y: 10, Path: [][2]int{{4, 10}}, Kind: VehicleCar, Age: vehicleFadeIn / 2}}
+	r.game.broadcastTraffic()
+	var update struct{ Vehicles []TrafficEntity }
+	if err := json.Unmarshal(nextEvent(t, c, EventTrafficUpdate), &update); err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Vehicles) != 1 || update.Vehicles[0].FadeIn != 0.5 {
+		t.Fatalf("vehicles %+v, want one halfway through its fade-in", update.Vehicles)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHeatmapFindsTheBusyCorridor(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	roadLine(g, 0, 10, 20, 10) // busy
+	roadLine(g, 0, 12, 20, 12) // quiet
+	car := func(id int64, y int) *Vehicle {
+		p := g.roadPath([2]int{0, y}, [2]int{20, y}, 400)
+		return &Vehicle{ID: id, X: 0, Y: float64(y), Path: p[1:], Kind: VehicleCar}
+	}
+	for i := 0; i < 30; i++ {
+		if i%2 == 0 {
+			for k := 0; k < 5; k++ {
+				g.Vehicles = append(g.Vehicles, car(int64(i*10+k), 10))
+			}
+		}
+		if i%10 == 0 {
+			g.Vehicles = append(g.Vehicles, car(int64(1000+i), 12))
+		}
+		for f := 0; f < 10; f++ {
+			g.updateTraffic(0.1)
+		}
+		g.decayHeat()
+	}
+	busy, quiet := g.heat[10][10], g.heat[12][10]
+	if busy <= 2*quiet {
+		t.Fatalf("busy corridor %.1f, quiet road %.1f", busy, quiet)
+	}
+
+	c := probe(r, "watcher")
+	g.broadcastHeatmap()
+	var heatmap struct {
+		Values [][]int `json:"values"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventTrafficHeatmap), &heatmap)
+	if heatmap.Values[10][10] <= heatmap.Values[12][10] {
+		t.Fatalf("broadcast heatmap %d busy, %d quiet", heatmap.Values[10][10], heatmap.Values[12][10])
+	}
+
+	g.Vehicles = nil
+	for i := 0; i < 10; i++ {
+		g.updateTraffic(0.1)
+		g.decayHeat()
+	}
+	if g.heat[10][10] >= busy/2 {
+		t.Fatalf("corridor heat %.1f ten ticks after traffic stopped, was %.1f", g.heat[10][10], busy)
+	}
+	for i := 0; i < 60; i++ {
+		g.decayHeat()
+	}
+	if g.heat[10][10] != 0 {
+		t.Fatalf("corridor heat %.2f never cools to 0", g.heat[10][10])
+	}
+}
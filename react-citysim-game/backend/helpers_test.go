@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer serves the game's HTTP endpoints on a local test server, closed when t ends.
+func newTestServer(t testing.TB) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", wsHandler)
+	mux.HandleFunc("/state", stateHandler)
+	mux.HandleFunc("/summary", summaryHandler)
+	mux.HandleFunc("/history", historyHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// wsDial connects to srv's /ws with the query q; the connection closes when t ends.
+func wsDial(t testing.TB, srv *httptest.Server, q string) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?"+q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// wsWait reads messages until one of type typ arrives and returns its payload, failing after 5s.
+func wsWait(t testing.TB, c *websocket.Conn, typ string) json.RawMessage {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var env Envelope
+		if json.Unmarshal(data, &env) == nil && env.Type == typ {
+			return env.Payload
+		}
+	}
+}
+
+// wsSend sends an action with the given payload.
+func wsSend(t testing.TB, c *websocket.Conn, typ string, payload interface{}) {
+	t.Helper()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteJSON(Envelope{Type: typ, Payload: b}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testRoom is a room with no bots, a fixed seed and its hub running, but no loops: tests step it by
+// hand. Its hub stops when t ends.
+func testRoom(t testing.TB) *Room {
+	r := newRoomFrom("test", roomConfig{Seed: 1, Speed: 1})
+	go r.hub.run()
+	t.Cleanup(r.hub.stop)
+	return r
+}
+
+// join adds a connected player with the given money to r.
+func join(r *Room, pid PlayerID, money int) {
+	r.input(journalEntry{Kind: journalJoin, Player: pid, Name: string(pid), Money: money})
+}
+
+// act applies a client action for pid through the room's input path and returns the reject reason.
+func act(t testing.TB, r *Room, pid PlayerID, action string, payload interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.input(journalEntry{Kind: journalAction, Player: pid, Action: action, Payload: b})
+}
+
+// build puts a finished, watered and powered building of type typ on its own zone at (x,y).
+func build(g *GameState, x, y int, typ ZoneType) *Building {
+	t := g.Tiles[y][x]
+	t.Zone = &Zone{Type: typ}
+	t.Building = &Building{Type: typ, Final: true, Watered: true, Powered: true}
+	g.index = nil
+	g.homeChanged(t)
+	return t.Building
+}
+
+// roadLine lays local road on every tile from (x0,y0) to (x1,y1), which share a row or a column.
+func roadLine(g *GameState, x0, y0, x1, y1 int) {
+	for y := min(y0, y1); y <= max(y0, y1); y++ {
+		for x := min(x0, x1); x <= max(x0, x1); x++ {
+			g.Tiles[y][x].Road = &Road{}
+		}
+	}
+	g.index = nil
+	g.roadsChanged()
+}
+
+// probe registers a client on r's hub without a connection; its messages collect in its send channel.
+func probe(r *Room, id PlayerID) *Client {
+	c := &Client{id: id, room: r, send: make(chan []byte, 1024), limiter: newTokenBucket(actionRate, actionBurst), bulldoze: newTokenBucket(bulldozeRate, bulldozeBurst)}
+	r.hub.register <- c
+	return c
+}
+
+// nextEvent returns the payload of the first message of type typ queued for c, dropping the ones
+// before it, and fails t if none arrives within a second.
+func nextEvent(t testing.TB, c *Client, typ string) json.RawMessage {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case b := <-c.send:
+			var env Envelope
+			if json.Unmarshal(b, &env) == nil && env.Type == typ {
+				return env.Payload
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", typ)
+			return nil
+		}
+	}
+}
+
+// drain returns the types of the messages queued for c up to a marker broadcast on r, so every
+// broadcast published before the call is included.
+func drain(t testing.TB, r *Room, c *Client) []string {
+	t.Helper()
+	r.game.announce("marker", nil)
+	var types []string
+	for {
+		select {
+		case b := <-c.send:
+			var env Envelope
+			json.Unmarshal(b, &env)
+			if env.Type == "marker" {
+				return types
+			}
+			types = append(types, env.Type)
+		case <-time.After(time.Second):
+			t.Fatal("no marker")
+			return nil
+		}
+	}
+}
+
+// listRoom makes r reachable by code through the HTTP endpoints until t ends.
+func listRoom(t testing.TB, r *Room) {
+	roomsMu.Lock()
+	rooms[r.Code] = r
+	roomsMu.Unlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, r.Code)
+		roomsMu.Unlock()
+	})
+}
+
+// closeRoom removes the room with the given code from rooms and shuts it down when t ends, for
+// tests whose room the server opens; call it before connecting so it runs after the clients close.
+func closeRoom(t testing.TB, code string) {
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		r := rooms[code]
+		delete(rooms, code)
+		roomsMu.Unlock()
+		if r != nil {
+			r.shutdown()
+		}
+	})
+}
+
+// request sends an action as c would over its connection and returns the reason in its ack.
+func request(t testing.TB, c *Client, action string, payload interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := json.Marshal(Envelope{Type: action, Seq: 1, Payload: b})
+	c.handleMessage(msg)
+	var ack Ack
+	if err := json.Unmarshal(nextEvent(t, c, EventAck), &ack); err != nil {
+		t.Fatal(err)
+	}
+	return ack.Reason
+}
+
+// setAdminToken sets the admin token until t ends.
+func setAdminToken(t testing.TB, token string) {
+	old := adminToken
+	adminToken = token
+	t.Cleanup(func() { adminToken = old })
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+// traversalFrames drives one car down a 20-tile road of the given kind and returns the traffic
+// frames it takes to reach the end.
+func traversalFrames(kind RoadKind) int {
+	g := newGame(1)
+	path := make([][2]int, 0, 20)
+	for x := 0; x <= 20; x++ {
+		g.Tiles[5][x].Road = &Road{Kind: kind}
+		if x > 0 {
+			path = append(path, [2]int{x, 5})
+		}
+	}
+	g.Vehicles = []*Vehicle{{ID: 1, Y: 5, Path: path, Kind: VehicleCar}}
+	frames := 0
+	for len(g.Vehicles) > 0 && frames < 1000 {
+		g.updateCongestion()
+		g.updateTraffic(0.1)
+		frames++
+	}
+	return frames
+}
+
+func TestHighwayIsFaster(t *testing.T) {
+	local, highway := traversalFrames(RoadLocal), traversalFrames(RoadHighway)
+	if highway >= local {
+		t.Fatalf("highway took %d frames, local road %d", highway, local)
+	}
+}
+
+func TestRoutesPreferAHighwayDetour(t *testing.T) {
+	g := newGame(1)
+	// a straight local road, and a highway two rows down joined to both of its ends
+	roadLine(g, 0, 5, 20, 5)
+	roadLine(g, 0, 7, 20, 7)
+	roadLine(g, 0, 5, 0, 7)
+	roadLine(g, 20, 5, 20, 7)
+	for x := 1; x < 20; x++ {
+		g.Tiles[7][x].Road.Kind = RoadHighway
+	}
+	p := g.roadPath([2]int{0, 5}, [2]int{20, 5}, 400)
+	onHighway := 0
+	for _, c := range p {
+		if g.Tiles[c[1]][c[0]].Road.Kind == RoadHighway {
+			onHighway++
+		}
+	}
+	if onHighway == 0 {
+		t.Fatalf("route %v stays on the local road", p)
+	}
+}
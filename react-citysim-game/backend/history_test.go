@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHistoryEvictsTheOldest(t *testing.T) {
+	g := newGame(1)
+	for i := 1; i <= 10; i++ {
+		g.Tick = int64(i)
+		g.recordHistory()
+	}
+	if s := g.history.list(); len(s) != 10 || s[0].Tick != 1 || s[9].Tick != 10 {
+		t.Fatalf("after 10 ticks: %d samples, ticks %d..%d", len(s), s[0].Tick, s[len(s)-1].Tick)
+	}
+	for i := 11; i <= historyLen+50; i++ {
+		g.Tick = int64(i)
+		g.recordHistory()
+	}
+	s := g.history.list()
+	if len(s) != historyLen || s[0].Tick != 51 || s[historyLen-1].Tick != historyLen+50 {
+		t.Fatalf("after %d ticks: %d samples, ticks %d..%d", historyLen+50, len(s), s[0].Tick, s[len(s)-1].Tick)
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i].Tick != s[i-1].Tick+1 {
+			t.Fatalf("samples out of order at %d: %d after %d", i, s[i].Tick, s[i-1].Tick)
+		}
+	}
+}
+
+func TestRequestHistory(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 500)
+	for i := 0; i < 5; i++ {
+		r.stepGame()
+	}
+	c := probe(r, "p")
+	msg, _ := json.Marshal(Envelope{Type: ActionRequestHistory})
+	c.handleMessage(msg)
+	var h struct {
+		Samples []HistorySample `json:"samples"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventHistory), &h)
+	if len(h.Samples) != 5 || h.Samples[4].Money != 500 {
+		t.Fatalf("history %+v, want 5 samples ending with 500 money", h.Samples)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmptyRoomPausesAndResumes(t *testing.T) {
+	srv := newTestServer(t)
+	closeRoom(t, "idlepause")
+	r := getOrCreateRoom("idlepause")
+	r.mu.Lock()
+	r.game.Speed = 4 // four ticks a second keeps the waits short
+	r.mu.Unlock()
+	tick := func() int64 {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.game.Tick
+	}
+	// ticks waits until the room has ticked at least n times, failing after 3s.
+	ticks := func(n int64) {
+		t.Helper()
+		for deadline := time.Now().Add(3 * time.Second); tick() < n; time.Sleep(50 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("tick stuck at %d, want %d", tick(), n)
+			}
+		}
+	}
+
+	start := tick()
+	time.Sleep(time.Second)
+	if n := tick(); n != start {
+		t.Fatalf("room with no clients ticked from %d to %d", start, n)
+	}
+
+	c := wsDial(t, srv, "room=idlepause&name=A")
+	wsWait(t, c, EventFullState)
+	ticks(start + 2)
+	c.Close()
+	time.Sleep(2 * speedPollInterval) // the hub notices the close and the loop its next poll
+	n := tick()
+	time.Sleep(time.Second)
+	if m := tick(); m != n {
+		t.Fatalf("room ticked from %d to %d after its last client left", n, m)
+	}
+
+	c = wsDial(t, srv, "room=idlepause&name=B")
+	wsWait(t, c, EventFullState)
+	if m := tick(); m > n+1 { // at most the one poll since the join
+		t.Fatalf("tick jumped from %d to %d on rejoin", n, m)
+	}
+	ticks(n + 1)
+}
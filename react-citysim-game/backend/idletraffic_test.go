@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// trafficEvents counts the traffic broadcasts c received.
+func trafficEvents(t *testing.T, r *Room, c *Client) int {
+	n := 0
+	for _, typ := range drain(t, r, c) {
+		if typ == EventTrafficUpdate || typ == EventTrafficDelta {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEmptyGameSendsNoTraffic(t *testing.T) {
+	r := testRoom(t)
+	c := probe(r, "watcher")
+	for i := 0; i < 50; i++ {
+		r.trafficFrame(100 * time.Millisecond)
+	}
+	if n := trafficEvents(t, r, c); n != 0 {
+		t.Fatalf("empty game sent %d traffic broadcasts", n)
+	}
+
+	// a car sends updates; once it is gone one idle frame clears it, then silence again
+	r.game.Vehicles = []*Vehicle{{ID: 1, X: 1, Y: 1, Kind: VehicleCar}}
+	r.game.broadcastTraffic()
+	r.game.Vehicles = nil
+	for i := 0; i < 50; i++ {
+		r.game.broadcastTraffic()
+	}
+	if n := trafficEvents(t, r, c); n != 2 {
+		t.Fatalf("one moving frame then idle sent %d traffic broadcasts, want 2", n)
+	}
+}
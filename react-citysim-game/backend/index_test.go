@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// botCity is a room grown by three bots for ticks ticks, with traffic frames in between.
+func botCity(ticks int) *Room {
+	r := newRoomFrom("index", roomConfig{Seed: 7, Speed: 1, Bots: []string{"balanced", "road-heavy", "residential-focused"}, BotMoney: botMoney * 4})
+	go r.hub.run()
+	for i := 0; i < ticks; i++ {
+		cityTick(r)
+	}
+	return r
+}
+
+// cityTick steps r once and runs one game second of traffic frames.
+func cityTick(r *Room) {
+	r.stepGame()
+	for f := 0; f < 10; f++ {
+		r.trafficFrame(100 * time.Millisecond)
+	}
+}
+
+// cityDigest lists every tile's zone, road and building with its stage, residents and staff.
+func cityDigest(g *GameState) string {
+	var d strings.Builder
+	for _, row := range g.Tiles {
+		for _, tl := range row {
+			if tl.Zone != nil {
+				d.WriteString(string(tl.Zone.Type))
+			}
+			if tl.Road != nil {
+				d.WriteByte('#')
+			}
+			if b := tl.Building; b != nil {
+				fmt.Fprintf(&d, "%s%d/%d/%d", b.Type, b.Stage, b.Residents, b.Employees)
+			}
+			d.WriteByte(',')
+		}
+	}
+	return d.String()
+}
+
+// TestTileIndexChangesNothing grows the same seeded city with the cached index and open homes and
+// with both rebuilt on every use, as a full grid scan would, and checks the two cities agree.
+func TestTileIndexChangesNothing(t *testing.T) {
+	cached, scanned := botCity(0), botCity(0)
+	defer cached.hub.stop()
+	defer scanned.hub.stop()
+	scanned.game.fullScan = true
+	for i := 0; i < 100; i++ {
+		cityTick(cached)
+		cityTick(scanned)
+		if a, b := cached.game.gameSummary(), scanned.game.gameSummary(); !reflect.DeepEqual(a, b) {
+			t.Fatalf("tick %d: summary %+v with the index, %+v scanning", cached.game.Tick, a, b)
+		}
+		if cityDigest(cached.game) != cityDigest(scanned.game) {
+			t.Fatalf("tick %d: the cities differ", cached.game.Tick)
+		}
+	}
+	if cached.game.Population == 0 {
+		t.Fatal("nobody moved in")
+	}
+}
+
+// TestTileIndexMatchesTheGrid checks that the cached index always lists what a fresh scan of the
+// grid would, so every consumer sees the same tiles it would find scanning the grid itself.
+func TestTileIndexMatchesTheGrid(t *testing.T) {
+	r := botCity(0)
+	defer r.hub.stop()
+	g := r.game
+	for i := 0; i < 150; i++ {
+		cityTick(r)
+		cached := *g.tiles()
+		g.index = nil
+		if fresh := *g.tiles(); !reflect.DeepEqual(cached, fresh) {
+			t.Fatalf("tick %d: the cached tile index is out of date", g.Tick)
+		}
+	}
+	if len(g.tiles().buildings) == 0 {
+		t.Fatal("the bots built nothing")
+	}
+}
+
+// BenchmarkTick steps a grown bot city, reporting the grid scans each tick makes for its tile
+// index; the tick's consumers (growth, labor, demand, citizens and the spawners) share them.
+func BenchmarkTick(b *testing.B) {
+	r := botCity(300)
+	defer r.hub.stop()
+	scans := r.game.indexScans
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cityTick(r)
+	}
+	b.ReportMetric(float64(r.game.indexScans-scans)/float64(b.N), "scans/tick")
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// newcomerTown is a road along row 10, reaching the west map edge if edge is set, with one
+// empty home beside it.
+func newcomerTown(edge bool) (*GameState, *Building) {
+	g := newGame(3)
+	roadLine(g, 1, 10, 20, 10)
+	if edge {
+		roadLine(g, 0, 10, 0, 10)
+	}
+	home := build(g, 10, 11, Residential)
+	g.Happiness = 50 // three applicants a tick
+	return g, home
+}
+
+func TestNewcomersArriveFromTheEdge(t *testing.T) {
+	g, home := newcomerTown(true)
+	g.growthTick(newBuildingChangeSet())
+	if len(g.CitizenGroups) != 1 || home.Residents != 0 {
+		t.Fatalf("%d groups, %d residents; want 1 group on its way and nobody moved in", len(g.CitizenGroups), home.Residents)
+	}
+	if grp := g.CitizenGroups[0]; grp.State != "inbound" || grp.X != 0 || grp.Y != 10 || grp.Count != 3 || grp.DestX != 10 || grp.DestY != 11 {
+		t.Fatalf("newcomers %+v, want 3 inbound from (0,10) to (10,11)", grp)
+	}
+	for i := 0; i < 500 && len(g.CitizenGroups) > 0; i++ {
+		g.updateCitizens(0.1)
+		if len(g.CitizenGroups) > 0 && home.Residents != 0 {
+			t.Fatal("residents moved in before arriving")
+		}
+	}
+	if home.Residents != 3 || len(g.movedIn) != 1 {
+		t.Fatalf("after arriving: %d residents, %d homes moved into", home.Residents, len(g.movedIn))
+	}
+}
+
+func TestNoNewcomersWithoutAnEdgeRoad(t *testing.T) {
+	g, home := newcomerTown(false)
+	g.growthTick(newBuildingChangeSet())
+	if len(g.CitizenGroups) != 0 || home.Residents != 0 {
+		t.Fatalf("%d groups, %d residents with no road from the map edge", len(g.CitizenGroups), home.Residents)
+	}
+}
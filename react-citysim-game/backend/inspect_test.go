@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInspectTile(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	join(r, "p", 1000)
+	g.Players["p"].Name = "Ada"
+	b := build(g, 10, 10, Residential)
+	g.Tiles[10][10].Zone.Owner = "p"
+	b.Stage, b.Residents, b.IdleTicks, b.AbandonPhase, b.Powered = 2, 5, 3, 1, false
+	g.Tiles[10][10].LandValue, g.Tiles[10][10].Pollution = 42, 7
+
+	c := probe(r, "p")
+	msg, _ := json.Marshal(Envelope{Type: ActionInspectTile, Payload: json.RawMessage(`{"x":10,"y":10}`)})
+	c.handleMessage(msg)
+	var info struct {
+		X, Y      int
+		LandValue int
+		Pollution int
+		IdleTicks int
+		Owner     PlayerID
+		OwnerName string
+		Watered   bool
+		Powered   bool
+		Building  Building
+	}
+	if err := json.Unmarshal(nextEvent(t, c, EventTileInfo), &info); err != nil {
+		t.Fatal(err)
+	}
+	want := Building{Type: Residential, Stage: 2, Final: true, Residents: 5, AbandonPhase: 1, Watered: true}
+	if info.X != 10 || info.Y != 10 || info.Building != want {
+		t.Fatalf("building at (%d,%d) %+v, want %+v", info.X, info.Y, info.Building, want)
+	}
+	if info.Owner != "p" || info.OwnerName != "Ada" || info.IdleTicks != 3 || !info.Watered || info.Powered || info.LandValue != 42 || info.Pollution != 7 {
+		t.Fatalf("tile info %+v", info)
+	}
+
+	if reason := request(t, c, ActionInspectTile, InspectTilePayload{X: -1, Y: 0}); reason != ReasonOutOfBounds {
+		t.Fatalf("inspecting off the map: %q, want %q", reason, ReasonOutOfBounds)
+	}
+}
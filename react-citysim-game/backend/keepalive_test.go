@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepaliveServer serves clients into r as players named after the "id" query, with a short
+// keepalive window.
+func keepaliveServer(t *testing.T, r *Room, wait time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		id := PlayerID(req.URL.Query().Get("id"))
+		c := &Client{id: id, room: r, conn: conn, send: make(chan []byte, 128), limiter: newTokenBucket(actionRate, actionBurst), bulldoze: newTokenBucket(bulldozeRate, bulldozeBurst), pongWait: wait}
+		join(r, id, 0)
+		r.hub.register <- c
+		go c.writer()
+		c.reader()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// connected reports whether pid's player is online.
+func connected(r *Room, pid PlayerID) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pl := r.game.Players[pid]
+	return pl != nil && pl.Connected
+}
+
+func TestSilentClientIsReaped(t *testing.T) {
+	r := testRoom(t)
+	srv := keepaliveServer(t, r, 300*time.Millisecond)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?id="
+
+	silent, _, err := websocket.DefaultDialer.Dial(url+"silent", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	silent.SetPingHandler(func(string) error { return nil }) // never answers pings
+	alive, _, err := websocket.DefaultDialer.Dial(url+"alive", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alive.Close()
+	go func() { // reading answers the server's pings with pongs
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := silent.ReadMessage(); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("server never closed the silent client")
+			}
+			break
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for connected(r, "silent") {
+		if time.Now().After(deadline) {
+			t.Fatal("silent client still connected after its connection closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !connected(r, "alive") {
+		t.Fatal("client answering pings was dropped")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestEqualShopsShareWorkersEvenly(t *testing.T) {
+	g := newGame(5)
+	roadLine(g, 0, 10, 20, 10)
+	build(g, 10, 9, Residential).Residents = 3
+	var shops []*Building
+	for _, p := range [][2]int{{6, 9}, {14, 9}, {6, 11}, {14, 11}} { // all four blocks from home by road
+		shops = append(shops, build(g, p[0], p[1], Commercial))
+	}
+	staffed := make([]int, len(shops))
+	for i := 0; i < 400; i++ {
+		g.Population = 3 + i%3*2 // a shifting workforce makes the round-robin trim and refill
+		g.allocateLaborAndSupplies(newBuildingChangeSet())
+		for j, b := range shops {
+			staffed[j] += b.Employees
+			b.IdleTicks, b.AbandonPhase = 0, 0
+		}
+	}
+	lo, hi := staffed[0], staffed[0]
+	for _, n := range staffed {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	if hi > lo*5/4 {
+		t.Fatalf("worker-ticks per shop %v, want within 25%% of each other", staffed)
+	}
+}
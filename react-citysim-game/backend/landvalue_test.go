@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParkRaisesLandValue(t *testing.T) {
+	g := newGame(1)
+	g.updateLandValue()
+	before := g.Tiles[10][12].LandValue
+	g.Tiles[10][10].Structure = &Structure{Type: "park"}
+	g.updateLandValue()
+	if after := g.Tiles[10][12].LandValue; after <= before {
+		t.Fatalf("land value two tiles from a park %d, was %d", after, before)
+	}
+	if far := g.Tiles[40][40].LandValue; far != before {
+		t.Fatalf("land value far from the park %d, want %d", far, before)
+	}
+}
+
+func TestAdjacentIndustryLowersLandValue(t *testing.T) {
+	g := newGame(1)
+	g.updateLandValue()
+	before := g.Tiles[10][10].LandValue
+	g.Tiles[10][11].Zone = &Zone{Type: Industrial}
+	g.Tiles[10][11].Building = &Building{Type: Industrial, Final: true}
+	g.updateLandValue()
+	if after := g.Tiles[10][10].LandValue; after >= before {
+		t.Fatalf("land value beside industry %d, was %d", after, before)
+	}
+}
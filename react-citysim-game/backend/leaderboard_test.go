@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLeaderboardOrder(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	join(r, "a", 100000)
+	join(r, "b", 100000)
+	roadLine(g, 0, 4, 20, 4)
+	if reason := act(t, r, "a", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 1, Y0: 5, X1: 4, Y1: 5, Zone: Residential}); reason != "" {
+		t.Fatalf("a zoning: %s", reason)
+	}
+	if reason := act(t, r, "b", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 10, Y0: 5, X1: 11, Y1: 5, Zone: Residential}); reason != "" {
+		t.Fatalf("b zoning: %s", reason)
+	}
+	for i := 0; i < 5; i++ {
+		g.progressBuildings(newBuildingChangeSet())
+		for _, lot := range g.tiles().lots {
+			if lot.Building != nil {
+				lot.Building.Watered = true
+			}
+		}
+	}
+	if a, b := g.Players["a"].Stats.BuildingsBuilt, g.Players["b"].Stats.BuildingsBuilt; a != 4 || b != 2 {
+		t.Fatalf("buildings built: a %d, b %d; want 4 and 2", a, b)
+	}
+
+	c := probe(r, "a")
+	msg, _ := json.Marshal(Envelope{Type: ActionRequestLeaderboard})
+	c.handleMessage(msg)
+	var board struct {
+		Players []LeaderboardEntry `json:"players"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventLeaderboard), &board)
+	if len(board.Players) != 2 {
+		t.Fatalf("leaderboard %+v, want both players", board.Players)
+	}
+	first, second := board.Players[0], board.Players[1]
+	if first.ID != "a" || first.Rank != 1 || second.ID != "b" || second.Rank != 2 || first.Score <= second.Score {
+		t.Fatalf("leaderboard %+v, want a ahead of b", board.Players)
+	}
+	if first.Score != first.Stats.score() || first.Stats.BuildingsBuilt != 4 {
+		t.Fatalf("a's entry %+v", first)
+	}
+}
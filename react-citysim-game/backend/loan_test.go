@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestLoanLifecycle(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 1000)
+	g := r.game
+	pl := g.Players["p"]
+
+	if reason := act(t, r, "p", ActionTakeLoan, LoanPayload{Amount: 5000}); reason != "" {
+		t.Fatalf("loan refused: %s", reason)
+	}
+	if pl.Money != 6000 || pl.Debt != 5000 || pl.InterestRate != loanInterestRate {
+		t.Fatalf("after the loan: money %d debt %d rate %v", pl.Money, pl.Debt, pl.InterestRate)
+	}
+	if reason := act(t, r, "p", ActionTakeLoan, LoanPayload{Amount: loanBaseAllowance - 4999}); reason != ReasonCreditLimit {
+		t.Fatalf("loan past the limit: reason %q, want %q", reason, ReasonCreditLimit)
+	}
+
+	for i := 0; i < 10; i++ {
+		g.economicTick()
+	}
+	if pl.Money != 6000-10*10 || g.budget("p").Interest != -100 {
+		t.Fatalf("after 10 ticks of interest: money %d, budget interest %d", pl.Money, g.budget("p").Interest)
+	}
+
+	if reason := act(t, r, "p", ActionRepayLoan, LoanPayload{Amount: 2000}); reason != "" || pl.Debt != 3000 || pl.Money != 3900 {
+		t.Fatalf("partial repayment: %q, debt %d money %d", reason, pl.Debt, pl.Money)
+	}
+	if reason := act(t, r, "p", ActionRepayLoan, LoanPayload{}); reason != "" || pl.Debt != 0 || pl.InterestRate != 0 || pl.Money != 900 {
+		t.Fatalf("full repayment: %q, debt %d rate %v money %d", reason, pl.Debt, pl.InterestRate, pl.Money)
+	}
+	if reason := act(t, r, "p", ActionRepayLoan, LoanPayload{}); reason != ReasonNoDebt {
+		t.Fatalf("repaying no debt: reason %q, want %q", reason, ReasonNoDebt)
+	}
+
+	pl.Money = -1
+	if reason := act(t, r, "p", ActionTakeLoan, LoanPayload{Amount: 100}); reason != ReasonCreditLimit {
+		t.Fatalf("borrowing in the red: reason %q, want %q", reason, ReasonCreditLimit)
+	}
+}
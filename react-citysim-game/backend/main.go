@@ -1,12 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
+	"container/heap"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -21,8 +42,98 @@ const (
 	Residential ZoneType = "R"
 	Commercial  ZoneType = "C"
 	Industrial  ZoneType = "I"
+	// Reserve is planned green space: it never develops, keeps and regrows its foliage, and raises
+	// the land value (and so the happiness) of the tiles around it.
+	Reserve ZoneType = "P"
 )
 
+// zoneCost is the price of zoning one tile; reserves cost reserveZoneCost.
+const (
+	zoneCost        = 100
+	reserveZoneCost = 20
+)
+
+// zoneCostOf is the base price of zoning one tile as z.
+func zoneCostOf(z ZoneType) int {
+	if z == Reserve {
+		return reserveZoneCost
+	}
+	return zoneCost
+}
+
+// bulldozeRefundPct is the share of the placement cost returned when a player demolishes their own work.
+const bulldozeRefundPct = 25
+
+// bulldozeCooldownTicks is how long a demolished tile is protected from being bulldozed again, so
+// a player cannot spam bulldoze-and-replace on one spot.
+const bulldozeCooldownTicks = 3
+
+// Terrain surcharges on placement costs; see placementCost.
+const (
+	elevationCostPct = 25 // added per elevation step above sea level
+	hillCostPct      = 50 // added on hill terrain
+	maxBuildSlope    = 2  // steepest elevation difference to an orthogonal neighbor that can be built on
+)
+
+// Rejection reason for zoning that breaks the room's zoning buffer rule.
+const ReasonIncompatibleNeighbor = "incompatible_neighbor"
+
+// zoningConflict returns ReasonIncompatibleNeighbor when the room's ZoningBuffer rule is on and
+// zoning (x,y) as z would put industry orthogonally beside housing (zoned or built), or housing
+// beside industry; otherwise "".
+func (game *GameState) zoningConflict(x, y int, z ZoneType) string {
+	if !game.ZoningBuffer || (z != Residential && z != Industrial) {
+		return ""
+	}
+	other := Industrial
+	if z == Industrial {
+		other = Residential
+	}
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		nx, ny := x+d[0], y+d[1]
+		if !game.inBounds(nx, ny) {
+			continue
+		}
+		n := game.Tiles[ny][nx]
+		if (n.Zone != nil && n.Zone.Type == other) || (n.Building != nil && n.Building.Type == other) {
+			return ReasonIncompatibleNeighbor
+		}
+	}
+	return ""
+}
+
+// validZoneType reports whether z is a zone type clients may place.
+func validZoneType(z ZoneType) bool {
+	switch z {
+	case Residential, Commercial, Industrial, Reserve:
+		return true
+	}
+	return false
+}
+
+// abandonKeepsZone holds the zone types whose zoning survives the removal of an abandoned
+// building, so the lot redevelops on its own. CITYSIM_ABANDON_KEEP_ZONE overrides the default
+// (residential only) with a comma-separated list of zone types, or "none".
+var abandonKeepsZone = func() map[ZoneType]bool {
+	v := os.Getenv("CITYSIM_ABANDON_KEEP_ZONE")
+	if v == "" {
+		return map[ZoneType]bool{Residential: true}
+	}
+	keep := map[ZoneType]bool{}
+	if v == "none" {
+		return keep
+	}
+	for _, z := range strings.Split(v, ",") {
+		z := ZoneType(strings.TrimSpace(z))
+		if !validZoneType(z) {
+			log.Printf("ignoring unknown zone type in CITYSIM_ABANDON_KEEP_ZONE: %q", z)
+			continue
+		}
+		keep[z] = true
+	}
+	return keep
+}()
+
 type Demand struct {
 	Residential int `json:"residential"`
 	Commercial  int `json:"commercial"`
@@ -30,15 +141,76 @@ type Demand struct {
 }
 
 type Player struct {
-	ID    PlayerID `json:"id"`
-	Name  string   `json:"name"`
-	Money int      `json:"money"`
+	ID           PlayerID    `json:"id"`
+	Name         string      `json:"name"`
+	Color        string      `json:"color,omitempty"` // "#rrggbb", lets the UI tint each player's zones/roads
+	Money        int         `json:"money"`
+	Connected    bool        `json:"connected,omitempty"` // a client currently controls this player
+	Bot          bool        `json:"bot,omitempty"`
+	Strategy     string      `json:"strategy,omitempty"` // bot profile name, see botProfiles
+	Debt         int         `json:"debt,omitempty"`
+	InterestRate float64     `json:"interestRate,omitempty"` // charged on Debt each tick
+	Stats        PlayerStats `json:"stats"`
+}
+
+// PlayerStats are a player's cumulative results, counted on the zones they own.
+type PlayerStats struct {
+	PeakPopulation int `json:"peakPopulation"` // most residents housed at once
+	TaxEarned      int `json:"taxEarned"`      // land tax collected
+	BuildingsBuilt int `json:"buildingsBuilt"` // buildings completed
+	BuildingsLost  int `json:"buildingsLost"`  // buildings demolished after abandonment
+}
+
+func (p *Player) info() PlayerInfo {
+	return PlayerInfo{ID: p.ID, Name: p.Name, Color: p.Color, Bot: p.Bot, Strategy: p.Strategy}
 }
 
 type Road struct {
+	Owner     PlayerID      `json:"owner"`
+	PlacedAt  int64         `json:"placedAt"`
+	Direction RoadDirection `json:"direction,omitempty"`
+	Kind      RoadKind      `json:"kind,omitempty"`
+}
+
+// Rail is a railway track; only trains use it (see railPath).
+type Rail struct {
 	Owner    PlayerID `json:"owner"`
 	PlacedAt int64    `json:"placedAt"`
 }
+
+// RoadKind is the road tier; RoadLocal is the default street.
+type RoadKind string
+
+const (
+	RoadLocal   RoadKind = ""
+	RoadHighway RoadKind = "highway"
+)
+
+// roadCosts is the placement cost of each road tier.
+var roadCosts = map[RoadKind]int{
+	RoadLocal:   20,
+	RoadHighway: 60,
+}
+
+// RoadDirection restricts travel on a one-way road tile; DirNone is a normal two-way road.
+type RoadDirection string
+
+const (
+	DirNone  RoadDirection = ""
+	DirNorth RoadDirection = "N"
+	DirEast  RoadDirection = "E"
+	DirSouth RoadDirection = "S"
+	DirWest  RoadDirection = "W"
+)
+
+// roadDirectionVectors maps each one-way direction to its unit step (north is -y).
+var roadDirectionVectors = map[RoadDirection][2]int{
+	DirNorth: {0, -1},
+	DirEast:  {1, 0},
+	DirSouth: {0, 1},
+	DirWest:  {-1, 0},
+}
+
 type Zone struct {
 	Type     ZoneType `json:"type"`
 	Owner    PlayerID `json:"owner"`
@@ -46,22 +218,34 @@ type Zone struct {
 }
 type Structure struct {
 	Type     string   `json:"type"`
+	Plant    string   `json:"plant,omitempty"`    // power_plant only: coal, solar or nuclear
+	Capacity int      `json:"capacity,omitempty"` // power units a plant supplies
+	Size     int      `json:"size,omitempty"`     // footprint side of a multi-tile structure's root
+	Root     *[2]int  `json:"root,omitempty"`     // root tile of a structureMember
 	Owner    PlayerID `json:"owner"`
 	PlacedAt int64    `json:"placedAt"`
 }
 
 type Building struct {
-	Type         ZoneType `json:"type"`
-	Stage        int      `json:"stage"`
-	Final        bool     `json:"final"`
-	Residents    int      `json:"residents,omitempty"`
-	Employees    int      `json:"employees,omitempty"`
-	Supplies     int      `json:"supplies,omitempty"`
-	CompletedAt  *int64   `json:"completedAt,omitempty"`
-	AbandonPhase int      `json:"abandonPhase,omitempty"`
-	IdleTicks    int      `json:"-"`
-	Size         int      `json:"size,omitempty"`
-	IsRoot       bool     `json:"isRoot,omitempty"`
+	Type          ZoneType `json:"type"`
+	Stage         int      `json:"stage"`
+	Final         bool     `json:"final"`
+	Residents     int      `json:"residents,omitempty"`
+	Employees     int      `json:"employees,omitempty"`
+	Supplies      int      `json:"supplies,omitempty"`
+	Stock         int      `json:"stock,omitempty"` // industrial goods waiting to ship
+	CompletedAt   *int64   `json:"completedAt,omitempty"`
+	CompletedTick int64    `json:"completedTick,omitempty"` // tick CompletedAt was stamped, for the abandonment grace period
+	AbandonPhase  int      `json:"abandonPhase,omitempty"`
+	AbandonReason string   `json:"abandonReason,omitempty"`
+	OnFire        int      `json:"onFire,omitempty"` // ticks burning; 0 when not on fire
+	Watered       bool     `json:"watered,omitempty"`
+	Powered       bool     `json:"powered,omitempty"`
+	// EducationLevel is 0..maxEducation: schooling of residents for housing, of the local workforce for industry
+	EducationLevel int  `json:"educationLevel,omitempty"`
+	IdleTicks      int  `json:"-"`
+	Size           int  `json:"size,omitempty"`
+	IsRoot         bool `json:"isRoot,omitempty"`
 }
 
 type Tile struct {
@@ -72,9 +256,16 @@ type Tile struct {
 	Foliage   string     `json:"foliage,omitempty"`
 	Zone      *Zone      `json:"zone,omitempty"`
 	Road      *Road      `json:"road,omitempty"`
+	Rail      *Rail      `json:"rail,omitempty"`
 	Structure *Structure `json:"structure,omitempty"`
 	Building  *Building  `json:"building,omitempty"`
 	Citizens  int        `json:"citizens,omitempty"`
+	LandValue int        `json:"landValue,omitempty"`
+	Pollution int        `json:"pollution,omitempty"`
+	Happiness int        `json:"happiness,omitempty"` // 0..100 for occupied housing
+	Crime     int        `json:"crime,omitempty"`     // 0..100 on built tiles
+	// ChangedTick is the tick of the last zone/road/structure/building change, used for sync diffs
+	ChangedTick int64 `json:"-"`
 }
 
 type GameState struct {
@@ -86,15 +277,58 @@ type GameState struct {
 	Tick                 int64                `json:"tick"`
 	Population           int                  `json:"population"`
 	Employed             int                  `json:"employed"`
-	BotID                PlayerID             `json:"botId,omitempty"`
+	Happiness            int                  `json:"happiness"` // resident-weighted average of housing happiness
+	BotIDs               []PlayerID           `json:"botIds,omitempty"`
 	AILastAction         int64                `json:"-"`
 	CitizenGroups        []*CitizenGroup      `json:"citizenGroups,omitempty"`
 	PendingResidents     []int                `json:"-"`
 	UnemploymentPressure int                  `json:"-"`
 	JustRoadThisTick     map[[2]int]int64     `json:"-"`
+	bulldozedAt          map[[2]int]int64     // tick each tile was last demolished, pruned after bulldozeCooldownTicks
 	Vehicles             []*Vehicle           `json:"vehicles,omitempty"`
 	GoodsIC              []*GoodShipment      `json:"goodsIC,omitempty"`
 	GoodsCC              []*GoodShipment      `json:"goodsCC,omitempty"`
+	Congestion           map[[2]int]int       `json:"-"` // vehicles per road tile, refreshed each traffic frame
+	Trade                map[PlayerID]int     `json:"-"` // export income less import costs since the last economic tick
+	Budgets              map[PlayerID]*Budget `json:"-"` // money flows since the last budget report
+	Seed                 int64                `json:"seed"`
+	Speed                int                  `json:"speed"`                  // game speed multiplier, 0 = paused
+	ZoningBuffer         bool                 `json:"zoningBuffer,omitempty"` // forbid industrial zones beside residential ones
+	Objectives           []*Objective         `json:"objectives,omitempty"`
+	EndOnGoals           bool                 `json:"endOnGoals,omitempty"`
+	Ended                bool                 `json:"ended,omitempty"` // every objective met with EndOnGoals set; the room stays paused
+	rng                  *rand.Rand           // all simulation randomness; seeded from Seed for reproducible runs
+	hub                  *Hub                 // room hub that announce broadcasts to
+	vehicleSeq           int64
+	trafficIdleGroups    int // citizen groups in the last traffic update if nothing was moving, else -1
+	trafficFrame         int64
+	clock                int64                     // unix time of the input being applied (see Room.input); 0 outside inputs
+	trafficSent          []map[int64]TrafficEntity // delta traffic: last position sent per class and id
+	goodsSeq             int64
+	citizenSeq           int64
+	arrivedResponders    [][2]int // incident tiles reached by an emergency vehicle since the last tick
+	movedIn              [][2]int // homes newcomers moved into since the last tick
+	history              metricHistory
+	tileLog              map[[2]int]*tileEvents // per-tile change log, kept when tileHistoryLen > 0
+	busRoutes            []*BusRoute            // rebuilt by updateTransit when bus stops or their roads change
+	busStops             [][2]int               // stop access roads the routes were built from
+	routeSeq             int64
+	laborRotation        int           // start offset of the labor round-robin, advanced every tick
+	index                *tileIndex    // tiles by content, see tiles; nil until next use
+	indexScans           int           // grid scans tiles has made, for benchmarks
+	homes                homeSet       // homes that may have room for newcomers, see openHomes; nil until next use
+	fullScan             bool          // rebuild the index and open homes on every use: the uncached baseline, for tests
+	roadNet              *roadNetwork  // road components, see roadNetwork; nil after a road change
+	roadsDirty           bool          // roads changed since the last road_network_changed
+	roadsQuiet           time.Duration // traffic-frame time since the last road change
+	roadNetworks         int           // road networks as of the last tick, for gameSummary
+	stranded             float64       // strandedFraction as of the last tick, for gameSummary
+	heat                 [][]float64   // traffic heatmap, see addHeat; nil until something moves
+	owners               [][]PlayerID  // last ownership sent in ownership_update, nil until the first tick
+	ownerDirty           [][2]int      // tiles marked since then, rechecked by flushOwnership
+	PowerSupply          int           `json:"-"` // total plant capacity, refreshed by updatePower
+	PowerDemand          int           `json:"-"` // total load of finished buildings, powered or not
+	Config               SimConfig     `json:"-"` // simulation tuning, see loadSimConfig
 }
 
 type Vehicle struct {
@@ -102,18 +336,269 @@ type Vehicle struct {
 	X, Y      float64
 	Path      [][2]int
 	PathIndex int
+	Kind      string     // VehicleCar, VehicleTruck, VehicleBus or an emergency kind; empty in old saves means car
+	Target    [2]int     // incident tile an emergency vehicle is responding to
+	GroupID   int64      // commuting CitizenGroup this car carries; it moves with the group, not along Path
+	Route     int64      // BusRoute a bus loops around
+	Heading   float64    // degrees clockwise from east (y grows south), eased through turns; see vehiclePose
+	Age       float64    // game seconds since spawning, for the fade-in
+	from      [2]int     // last path tile reached
+	fromDir   [2]float64 // direction it was entered in; zero before the first one
+}
+
+const (
+	VehicleCar   = "car"
+	VehicleTruck = "truck"
+	VehicleBus   = "bus"
+	VehicleFire  = "fire"
+)
+
+// emergencyVehicle reports whether a vehicle kind responds to incidents.
+func emergencyVehicle(kind string) bool {
+	return kind == VehicleFire
+}
+
+// Room is an independent city: its own game state, hub, lock and simulation loops.
+type Room struct {
+	Code      string
+	game      *GameState
+	hub       *Hub
+	mu        sync.RWMutex // guards game: Lock for anything that mutates state, RLock for read-only snapshots
+	metrics   simMetrics
+	quit      chan struct{}            // closed to stop the game and traffic loops
+	stop      sync.Once                // guards shutdown, which process exit and the idle reaper can both reach
+	joining   atomic.Int32             // clients between joinRoom and registering; incremented under roomsMu
+	idleLimit time.Duration            // roomIdleTimeout when the room opened, read by gameLoop
+	loops     sync.WaitGroup           // running game and traffic loops
+	undo      map[PlayerID][]undoEntry // recent reversible actions per player; guarded by mu, not saved
+	// traffic frame spawn accumulators, in game time; guarded by mu
+	spawnAcc, citizenSpawnAcc, goodsSpawnAcc time.Duration
+	seq                                      sync.Mutex    // held by input, so inputs are journaled in the order they are applied
+	journal                                  *json.Encoder // CITYSIM_RECORD_DIR journal; nil when not recording. Guarded by seq
+	journalFile                              *os.File
+	cursorMu                                 sync.Mutex
+	cursors                                  map[PlayerID]time.Time // last cursor rebroadcast per player; guarded by cursorMu
+	chatMu                                   sync.Mutex
+	chatLog                                  []ChatMessage // last chatBacklog messages, oldest first; guarded by chatMu
+}
+
+// defaultRoomCode is used when a client or HTTP request names no room.
+const defaultRoomCode = "default"
+
+var (
+	rooms   = map[string]*Room{}
+	roomsMu sync.Mutex
+)
+
+// maxRooms (CITYSIM_MAX_ROOMS) caps how many rooms may exist at once; joining a new code beyond it
+// is refused. roomIdleTimeout (CITYSIM_ROOM_IDLE_SECONDS) is how long a room other than the default
+// one may stay without clients before it is shut down and forgotten.
+var (
+	maxRooms        = int(envFloat("CITYSIM_MAX_ROOMS", 100))
+	roomIdleTimeout = time.Duration(envFloat("CITYSIM_ROOM_IDLE_SECONDS", 300) * float64(time.Second))
+)
+
+// validRoomCode accepts short codes of letters, digits, '-' and '_'.
+func validRoomCode(code string) bool {
+	if code == "" || len(code) > 32 {
+		return false
+	}
+	for _, ch := range code {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// getOrCreateRoom returns the room for code, creating it and starting its loops on first use. It
+// returns nil when the room does not exist and maxRooms are already open.
+func getOrCreateRoom(code string) *Room {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	return openRoomLocked(code)
+}
+
+// joinRoom is getOrCreateRoom for a connecting client: it also counts the client in joining, so the
+// idle reaper leaves the room alone until the client has registered. The caller decrements it.
+func joinRoom(code string) *Room {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	r := openRoomLocked(code)
+	if r != nil {
+		r.joining.Add(1)
+	}
+	return r
+}
+
+// openRoomLocked is getOrCreateRoom with roomsMu held.
+func openRoomLocked(code string) *Room {
+	if r, ok := rooms[code]; ok {
+		return r
+	}
+	if len(rooms) >= maxRooms {
+		return nil
+	}
+	r := newRoom(code)
+	r.idleLimit = roomIdleTimeout
+	rooms[code] = r
+	go r.hub.run()
+	r.loops.Add(3)
+	go func() { defer r.loops.Done(); r.gameLoop() }()
+	go func() { defer r.loops.Done(); r.trafficLoop() }()
+	go func() { defer r.loops.Done(); r.cursorLoop() }()
+	log.Println("room created", code)
+	return r
+}
+
+// shutdown stops the room's loops, saves its final state and disconnects its clients. Later calls
+// do nothing.
+func (r *Room) shutdown() {
+	r.stop.Do(func() {
+		close(r.quit)
+		r.loops.Wait()
+		r.mu.RLock()
+		if err := r.save(); err != nil {
+			log.Println("room", r.Code, "save failed:", err)
+		}
+		r.mu.RUnlock()
+		r.hub.stop()
+		r.stopJournal()
+	})
+}
+
+// reap removes r from rooms and shuts it down if it is still empty and nobody is joining; the
+// default room is kept. Called from its own goroutine, since shutdown waits for the loops.
+func (r *Room) reap() {
+	roomsMu.Lock()
+	if r.Code == defaultRoomCode || rooms[r.Code] != r || !r.idle() || r.joining.Load() > 0 {
+		roomsMu.Unlock()
+		return
+	}
+	delete(rooms, r.Code)
+	roomsMu.Unlock()
+	log.Println("room", r.Code, "closed after", r.idleLimit, "without clients")
+	r.shutdown()
+}
+
+// save writes the room's game state to CITYSIM_SAVE_DIR/<code>.json; a no-op if the dir is unset.
+// Callers hold at least the room read lock.
+func (r *Room) save() error {
+	dir := os.Getenv("CITYSIM_SAVE_DIR")
+	if dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(r.game)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, r.Code+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// shutdownRooms stops every room; used on process shutdown.
+func shutdownRooms() {
+	roomsMu.Lock()
+	list := make([]*Room, 0, len(rooms))
+	for _, r := range rooms {
+		list = append(list, r)
+	}
+	roomsMu.Unlock()
+	for _, r := range list {
+		r.shutdown()
+	}
+}
+
+// roomCount returns how many rooms are open.
+func roomCount() int {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	return len(rooms)
+}
+
+// findRoom returns an existing room without creating one.
+func findRoom(code string) *Room {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	return rooms[code]
+}
+
+// simSeed returns the RNG seed for a new game: CITYSIM_SEED if set, otherwise the current time.
+func simSeed() int64 {
+	if v := os.Getenv("CITYSIM_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			return seed
+		}
+		log.Println("ignoring invalid CITYSIM_SEED:", v)
+	}
+	return time.Now().UnixNano()
+}
+
+// initialSpeed returns the starting game speed for new rooms from CITYSIM_SPEED (default 1x).
+func initialSpeed() int {
+	if v := os.Getenv("CITYSIM_SPEED"); v != "" {
+		speed, err := strconv.Atoi(v)
+		if err == nil && validSpeeds[speed] {
+			return speed
+		}
+		log.Println("ignoring invalid CITYSIM_SPEED:", v)
+	}
+	return 1
+}
+
+// roomConfig is everything a new room's starting state depends on besides its inputs.
+type roomConfig struct {
+	Seed         int64       `json:"seed"`
+	Speed        int         `json:"speed"`
+	ZoningBuffer bool        `json:"zoningBuffer,omitempty"`
+	Bots         []string    `json:"bots,omitempty"`
+	BotMoney     int         `json:"botMoney"`
+	Sim          *SimConfig  `json:"sim,omitempty"` // nil keeps the server's simConfig
+	Objectives   []Objective `json:"objectives,omitempty"`
+	EndOnGoals   bool        `json:"endOnGoals,omitempty"` // pause the room for good once every objective is met
+}
+
+func newRoom(code string) *Room {
+	cfg := roomConfig{Seed: simSeed(), Speed: initialSpeed(), ZoningBuffer: os.Getenv("CITYSIM_ZONING_BUFFER") == "1", Bots: botStrategies, BotMoney: botMoney, Sim: &simConfig,
+		Objectives: objectiveSpecs, EndOnGoals: os.Getenv("CITYSIM_OBJECTIVES_END") == "1"}
+	r := newRoomFrom(code, cfg)
+	if err := r.startJournal(cfg); err != nil {
+		log.Println("room", code, "recording disabled:", err)
+	}
+	return r
 }
 
-// global game state mutex & instance
-var game *GameState
-var gameMu sync.Mutex
-var vehicleSeq int64
-var goodsSeq int64
-var citizenSeq int64
+func newRoomFrom(code string, cfg roomConfig) *Room {
+	r := &Room{Code: code, hub: newHub(), quit: make(chan struct{}), undo: map[PlayerID][]undoEntry{}, cursors: map[PlayerID]time.Time{}}
+	r.game = newGame(cfg.Seed)
+	r.game.Speed = cfg.Speed
+	r.game.ZoningBuffer = cfg.ZoningBuffer
+	if cfg.Sim != nil {
+		r.game.Config = *cfg.Sim
+	}
+	for _, o := range cfg.Objectives {
+		r.game.Objectives = append(r.game.Objectives, &Objective{Metric: o.Metric, Target: o.Target})
+	}
+	r.game.EndOnGoals = cfg.EndOnGoals
+	r.game.hub = r.hub
+	for _, strategy := range cfg.Bots {
+		if _, ok := botProfiles[strategy]; !ok {
+			log.Printf("room %s: ignoring unknown bot strategy %q", code, strategy)
+			continue
+		}
+		r.game.createBotLocked(strategy, cfg.BotMoney)
+	}
+	return r
+}
 
 // (Removed old hub implementation duplicate)
 // extendRoadIfNeeded now supports straight growth, curves, and perpendicular branching (crossroads/T intersections).
-func extendRoadIfNeeded(p *Player) {
+func (game *GameState) extendRoadIfNeeded(p *Player, prof botProfile) {
 	if p.Money < 5 {
 		return
 	}
@@ -122,7 +607,7 @@ func extendRoadIfNeeded(p *Player) {
 		for dx := -1; dx <= 0; dx++ {
 			for dy := -1; dy <= 0; dy++ {
 				ax, ay := x+dx, y+dy
-				if !inBounds(ax, ay) || !inBounds(ax+1, ay+1) {
+				if !game.inBounds(ax, ay) || !game.inBounds(ax+1, ay+1) {
 					continue
 				}
 				// corners of prospective square
@@ -146,7 +631,7 @@ func extendRoadIfNeeded(p *Player) {
 		return false
 	}
 	tryPlace := func(x, y int) bool {
-		if !inBounds(x, y) || wouldThicken(x, y) {
+		if !game.inBounds(x, y) || wouldThicken(x, y) {
 			return false
 		}
 		t := game.Tiles[y][x]
@@ -154,17 +639,20 @@ func extendRoadIfNeeded(p *Player) {
 			return false
 		}
 		if t.Road == nil && t.Zone == nil && t.Structure == nil && t.Building == nil {
-			return aiPlaceRoad(p, x, y)
+			return game.aiPlaceRoad(p, x, y)
 		}
-		if t.Road == nil { // bulldoze single obstacle
+		if t.Road == nil && len(game.structureFootprint(x, y)) == 1 { // bulldoze single obstacle
+			before := game.layersAt(x, y)
 			t.Zone = nil
 			t.Building = nil
 			t.Structure = nil
-			announce(EventBulldozed, struct {
+			game.markTile(t)
+			game.logLayers(x, y, before, "bot", p.ID)
+			game.announce(EventBulldozed, struct {
 				X int `json:"x"`
 				Y int `json:"y"`
 			}{x, y})
-			return aiPlaceRoad(p, x, y)
+			return game.aiPlaceRoad(p, x, y)
 		}
 		return false
 	}
@@ -175,7 +663,7 @@ func extendRoadIfNeeded(p *Player) {
 	}
 	const pCurve = 0.25
 	const pBranch = 0.35 // chance to attempt a perpendicular branch instead of endpoint growth
-	attempts := aiMaxRoadAttempts
+	attempts := prof.RoadAttempts
 	for attempts > 0 {
 		attempts--
 		endpoints := []endpoint{}
@@ -186,10 +674,10 @@ func extendRoadIfNeeded(p *Player) {
 				if t.Road == nil {
 					continue
 				}
-				rR := inBounds(x+1, y) && game.Tiles[y][x+1].Road != nil
-				rL := inBounds(x-1, y) && game.Tiles[y][x-1].Road != nil
-				rD := inBounds(x, y+1) && game.Tiles[y+1][x].Road != nil
-				rU := inBounds(x, y-1) && game.Tiles[y-1][x].Road != nil
+				rR := game.inBounds(x+1, y) && game.Tiles[y][x+1].Road != nil
+				rL := game.inBounds(x-1, y) && game.Tiles[y][x-1].Road != nil
+				rD := game.inBounds(x, y+1) && game.Tiles[y+1][x].Road != nil
+				rU := game.inBounds(x, y-1) && game.Tiles[y-1][x].Road != nil
 				cnt := 0
 				if rR {
 					cnt++
@@ -233,8 +721,8 @@ func extendRoadIfNeeded(p *Player) {
 		}
 		placed := false
 		// branch attempt
-		if !placed && len(segments) > 0 && rand.Float64() < pBranch {
-			s := segments[rand.Intn(len(segments))]
+		if !placed && len(segments) > 0 && game.rng.Float64() < pBranch {
+			s := segments[game.rng.Intn(len(segments))]
 			// choose ONE perpendicular direction only
 			dirs := [][2]int{}
 			if s.horiz {
@@ -242,7 +730,7 @@ func extendRoadIfNeeded(p *Player) {
 			} else {
 				dirs = [][2]int{{1, 0}, {-1, 0}}
 			}
-			rand.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
+			game.rng.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
 			for _, d := range dirs {
 				if tryPlace(s.x+d[0], s.y+d[1]) {
 					placed = true
@@ -252,15 +740,15 @@ func extendRoadIfNeeded(p *Player) {
 		}
 		// endpoint growth
 		if !placed && len(endpoints) > 0 {
-			ep := endpoints[rand.Intn(len(endpoints))]
-			if rand.Float64() < pCurve { // curve -> pick perpendicular, not both
+			ep := endpoints[game.rng.Intn(len(endpoints))]
+			if game.rng.Float64() < pCurve { // curve -> pick perpendicular, not both
 				var choices [][2]int
 				if ep.dx != 0 {
 					choices = [][2]int{{0, 1}, {0, -1}}
 				} else {
 					choices = [][2]int{{1, 0}, {-1, 0}}
 				}
-				rand.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
+				game.rng.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
 				for _, c := range choices {
 					if tryPlace(ep.x+c[0], ep.y+c[1]) {
 						placed = true
@@ -282,26 +770,88 @@ func extendRoadIfNeeded(p *Player) {
 
 // Event names sent to frontend
 const (
-	EventFullState       = "full_state"
-	EventZonePlaced      = "zone_placed"
-	EventRoadPlaced      = "road_placed"
-	EventTick            = "tick"
-	EventTrafficUpdate   = "traffic"
-	EventBuildingUpdate  = "building_update"
-	EventBulldozed       = "bulldozed"
-	EventStructurePlaced = "structure_placed"
+	EventFullState          = "full_state"
+	EventZonePlaced         = "zone_placed"
+	EventRoadPlaced         = "road_placed"
+	EventTick               = "tick"
+	EventTrafficUpdate      = "traffic"
+	EventBuildingUpdate     = "building_update"
+	EventBulldozed          = "bulldozed"
+	EventStructurePlaced    = "structure_placed"
+	EventLandValue          = "land_value"
+	EventStateDiff          = "state_diff"
+	EventSpeedChanged       = "speed_changed"
+	EventPlayerUpdate       = "player_update"
+	EventPlayerJoined       = "player_joined"
+	EventPlayerLeft         = "player_left"
+	EventRoster             = "roster"
+	EventZonesPlaced        = "zones_placed"
+	EventRoadsPlaced        = "roads_placed"
+	EventUndone             = "undone"
+	EventDisaster           = "disaster"
+	EventCrime              = "crime"
+	EventTrafficHeatmap     = "traffic_heatmap"
+	EventLoanUpdate         = "loan_update"
+	EventBudgetReport       = "budget_report"
+	EventRulesChanged       = "rules_changed"
+	EventActionError        = "action_error"
+	EventAck                = "ack"
+	EventBotRemoved         = "bot_removed"
+	EventHistory            = "history"
+	EventFoliage            = "foliage"
+	EventTrafficDelta       = "traffic_delta"
+	EventTileInfo           = "tile_info"
+	EventOwnership          = "ownership"
+	EventOwnershipUpdate    = "ownership_update"
+	EventCursor             = "cursor"
+	EventChat               = "chat"
+	EventChatBacklog        = "chat_backlog"
+	EventRailPlaced         = "rail_placed"
+	EventLeaderboard        = "leaderboard"
+	EventObjectiveComplete  = "objective_complete"
+	EventCapacity           = "capacity"
+	EventAreaCleared        = "area_cleared"
+	EventTileHistory        = "tile_history"
+	EventRoadNetworkChanged = "road_network_changed"
 )
 
 // Client -> Server actions
 const (
-	ActionPlaceZone      = "place_zone"
-	ActionBulldoze       = "bulldoze"
-	ActionPlaceStructure = "place_structure"
+	ActionPlaceZone          = "place_zone"
+	ActionPlaceZoneRect      = "place_zone_rect"
+	ActionPlaceRoad          = "place_road"
+	ActionBuildRoadPath      = "build_road_path"
+	ActionBulldoze           = "bulldoze"
+	ActionPlaceStructure     = "place_structure"
+	ActionPlaceRail          = "place_rail"
+	ActionRequestLeaderboard = "request_leaderboard"
+	ActionRequestSync        = "request_sync"
+	ActionSetSpeed           = "set_speed"
+	ActionSetName            = "set_name"
+	ActionSetColor           = "set_color"
+	ActionRequestRoster      = "request_roster"
+	ActionUndo               = "undo"
+	ActionTakeLoan           = "take_loan"
+	ActionRepayLoan          = "repay_loan"
+	ActionSetZoningBuffer    = "set_zoning_buffer"
+	ActionAddBot             = "add_bot"
+	ActionRemoveBot          = "remove_bot"
+	ActionRequestHistory     = "request_history"
+	ActionInspectTile        = "inspect_tile"
+	ActionRequestOwnership   = "request_ownership"
+	ActionCursor             = "cursor"
+	ActionChat               = "chat"
+	ActionQueryCapacity      = "query_capacity"
+	ActionAdminClearRect     = "admin_clear_rect"
+	ActionAdminReset         = "admin_reset"
+	ActionTileHistory        = "tile_history"
+	ActionSubscribe          = "subscribe"
 )
 
 type Envelope struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	Seq     uint64          `json:"seq,omitempty"` // optional client-chosen action number, echoed in its ack
 }
 
 // Payload helper types
@@ -310,7 +860,27 @@ type PlaceZonePayload struct {
 	Y    int      `json:"y"`
 	Zone ZoneType `json:"zone"`
 }
+type PlaceZoneRectPayload struct {
+	X0   int      `json:"x0"`
+	Y0   int      `json:"y0"`
+	X1   int      `json:"x1"`
+	Y1   int      `json:"y1"`
+	Zone ZoneType `json:"zone"`
+}
 type PlaceRoadPayload struct {
+	X         int           `json:"x"`
+	Y         int           `json:"y"`
+	Direction RoadDirection `json:"direction,omitempty"`
+	Kind      RoadKind      `json:"kind,omitempty"`
+}
+type BuildRoadPathPayload struct {
+	X0   int      `json:"x0"`
+	Y0   int      `json:"y0"`
+	X1   int      `json:"x1"`
+	Y1   int      `json:"y1"`
+	Kind RoadKind `json:"kind,omitempty"`
+}
+type PlaceRailPayload struct {
 	X int `json:"x"`
 	Y int `json:"y"`
 }
@@ -319,48 +889,391 @@ type BulldozePayload struct {
 	Y int `json:"y"`
 }
 type PlaceStructurePayload struct {
-	X    int    `json:"x"`
-	Y    int    `json:"y"`
-	Kind string `json:"kind"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Kind  string `json:"kind"`
+	Plant string `json:"plant,omitempty"` // power_plant type, default coal
+}
+type RequestSyncPayload struct {
+	Since *int64 `json:"since,omitempty"` // defaults to the client's last synced tick
+}
+type StateDiffEvent struct {
+	Tick    int64                `json:"tick"`
+	Since   int64                `json:"since"`
+	Tiles   []*Tile              `json:"tiles"`
+	Demand  Demand               `json:"demand"`
+	Players map[PlayerID]*Player `json:"players"`
+}
+type SetSpeedPayload struct {
+	Token string `json:"token"`
+	Speed int    `json:"speed"`
+}
+type SetNamePayload struct {
+	Name string `json:"name"`
+}
+type SetColorPayload struct {
+	Color string `json:"color"`
+}
+type SetZoningBufferPayload struct {
+	Token   string `json:"token"`
+	Enabled bool   `json:"enabled"`
+}
+type AddBotPayload struct {
+	Token    string `json:"token"`
+	Strategy string `json:"strategy"` // a botProfiles name; empty means "balanced"
+}
+type RemoveBotPayload struct {
+	Token string   `json:"token"`
+	ID    PlayerID `json:"id"`
+}
+type InspectTilePayload struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+type QueryCapacityPayload struct {
+	X0 int `json:"x0"`
+	Y0 int `json:"y0"`
+	X1 int `json:"x1"`
+	Y1 int `json:"y1"`
+}
+type AdminClearRectPayload struct {
+	Token string `json:"token"`
+	X0    int    `json:"x0"`
+	Y0    int    `json:"y0"`
+	X1    int    `json:"x1"`
+	Y1    int    `json:"y1"`
+}
+type AdminResetPayload struct {
+	Token string `json:"token"`
+	Seed  *int64 `json:"seed,omitempty"` // defaults to the room's current seed
+}
+type SubscribePayload struct {
+	Events []string `json:"events"` // broadcast event types to receive; omitted or null for all
+}
+type TileHistoryPayload struct {
+	Token string `json:"token"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+}
+type LoanPayload struct {
+	Amount int `json:"amount"` // repay_loan: 0 repays as much as possible
+}
+
+// PlayerInfo is the public identity of a player, used by roster and player events.
+type PlayerInfo struct {
+	ID       PlayerID `json:"id"`
+	Name     string   `json:"name"`
+	Color    string   `json:"color,omitempty"`
+	Bot      bool     `json:"bot,omitempty"`
+	Strategy string   `json:"strategy,omitempty"`
 }
 type ZonePlacedEvent struct {
 	X    int   `json:"x"`
 	Y    int   `json:"y"`
 	Zone *Zone `json:"zone"`
 }
+type ZonesPlacedEvent struct {
+	Zones []ZonePlacedEvent `json:"zones"`
+}
+type RoadPlacedEvent struct {
+	X    int   `json:"x"`
+	Y    int   `json:"y"`
+	Road *Road `json:"road"`
+}
+
+// RoadsPlacedEvent reports a road path build. Complete is false when the path was blocked
+// or funds ran out; StoppedAt is then the last tile of the route that carries road.
+type RoadsPlacedEvent struct {
+	Roads     []RoadPlacedEvent `json:"roads"`
+	Complete  bool              `json:"complete"`
+	StoppedAt *[2]int           `json:"stoppedAt,omitempty"`
+}
 
 type Client struct {
-	id   PlayerID
-	conn *websocket.Conn
-	send chan []byte
+	id       PlayerID
+	room     *Room
+	conn     *websocket.Conn
+	send     chan []byte
+	lastTick atomic.Int64  // tick of the last full state or diff sent; atomic since syncs only hold the room read lock
+	limiter  *tokenBucket  // action rate limit; used only by the reader goroutine
+	bulldoze *tokenBucket  // separate, tighter limit on bulldoze actions
+	pongWait time.Duration // read deadline for any message or pong; pongWait unless a test shortens it
+	// spectator clients receive state and events but have no Player and may only request data
+	spectator  bool
+	binary     bool      // negotiated ?format=msgpack: binaryEvents and full state arrive as msgpack
+	lastCursor time.Time // last cursor rebroadcast; used only by the reader goroutine
+	// events are the broadcast event types the client subscribed to, nil for all; used only by the hub goroutine
+	events map[string]bool
+}
+
+// tokenBucket is a token-bucket rate limiter: it holds up to burst tokens, refilled at rate per second.
+type tokenBucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Per-connection action limits; override with CITYSIM_ACTION_RATE (actions/sec) and CITYSIM_ACTION_BURST.
+var (
+	actionRate  = envFloat("CITYSIM_ACTION_RATE", 20)
+	actionBurst = envFloat("CITYSIM_ACTION_BURST", 40)
+)
+
+// Bulldoze actions also draw on their own bucket; override with CITYSIM_BULLDOZE_RATE and CITYSIM_BULLDOZE_BURST.
+var (
+	bulldozeRate  = envFloat("CITYSIM_BULLDOZE_RATE", 4)
+	bulldozeBurst = envFloat("CITYSIM_BULLDOZE_BURST", 10)
+)
+
+// envFloat reads a positive float from the environment, falling back to def.
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		log.Printf("ignoring invalid %s: %s", name, v)
+		return def
+	}
+	return f
+}
+
+// Starting balances; override with CITYSIM_START_MONEY (players) and CITYSIM_BOT_MONEY (the planner bot).
+var (
+	startMoney = envMoney("CITYSIM_START_MONEY", 100000)
+	botMoney   = envMoney("CITYSIM_BOT_MONEY", 50000)
+)
+
+// envMoney reads a non-negative integer from the environment, falling back to def.
+func envMoney(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("ignoring invalid %s: %s", name, v)
+		return def
+	}
+	return n
+}
+
+// SimConfig holds the labor, abandonment and AI tuning values. Defaults come from defaultSimConfig;
+// CITYSIM_CONFIG names a JSON file whose fields override them.
+type SimConfig struct {
+	IndustrialCapacity      int     `json:"industrialCapacity"`      // workers per industrial building
+	CommercialCapacity      int     `json:"commercialCapacity"`      // workers per commercial building
+	CommercialCustomerNeed  int     `json:"commercialCustomerNeed"`  // customers a shop needs to stay open
+	AbandonTriggerTicksBase int     `json:"abandonTriggerTicksBase"` // idle ticks before R & I start abandoning
+	CommercialAbandonFactor int     `json:"commercialAbandonFactor"` // commercial takes this many times longer
+	AbandonGraceTicks       int     `json:"abandonGraceTicks"`       // ticks after completion during which idle ticks don't count
+	MaxCommercialSupplies   int     `json:"maxCommercialSupplies"`
+	AIActionInterval        int64   `json:"aiActionInterval"` // ticks between bot actions
+	AIWaterReserve          int     `json:"aiWaterReserve"`   // money the bot keeps back when building water towers
+	AIPowerReserve          int     `json:"aiPowerReserve"`   // money the bot keeps back when building power plants
+	AIBridgeChance          float64 `json:"aiBridgeChance"`   // chance each action tries to join disconnected road fragments
+	AIMaxBridgeLen          int     `json:"aiMaxBridgeLen"`   // longest new road the bot lays to join two fragments
+	// Bot zone choice, see pickZoneTypeByDemand. The biases add to every bot's score for the type on
+	// top of its profile, so a positive commercial or industrial bias tilts bots toward that city.
+	AICommercialBias             int `json:"aiCommercialBias"`
+	AIIndustrialBias             int `json:"aiIndustrialBias"`
+	AINoIdleWorkersPenalty       int `json:"aiNoIdleWorkersPenalty"`       // industrial penalty with under 5 unemployed
+	AIFewIdleWorkersPenalty      int `json:"aiFewIdleWorkersPenalty"`      // industrial penalty with under 15 unemployed
+	AIFullHousingBonus           int `json:"aiFullHousingBonus"`           // residential bonus with no open homes
+	AITightHousingBonus          int `json:"aiTightHousingBonus"`          // residential bonus with under 10 open homes
+	AIIdleWorkersCommercialBonus int `json:"aiIdleWorkersCommercialBonus"` // commercial bonus with over 10 unemployed and spare housing
+}
+
+func defaultSimConfig() SimConfig {
+	return SimConfig{
+		IndustrialCapacity:           4,
+		CommercialCapacity:           2,
+		CommercialCustomerNeed:       5,
+		AbandonTriggerTicksBase:      5,
+		CommercialAbandonFactor:      3,
+		AbandonGraceTicks:            10,
+		MaxCommercialSupplies:        8,
+		AIActionInterval:             4,
+		AIWaterReserve:               1000,
+		AIPowerReserve:               1000,
+		AIBridgeChance:               0.5,
+		AIMaxBridgeLen:               24,
+		AINoIdleWorkersPenalty:       8,
+		AIFewIdleWorkersPenalty:      4,
+		AIFullHousingBonus:           10,
+		AITightHousingBonus:          5,
+		AIIdleWorkersCommercialBonus: 2,
+	}
+}
+
+// simConfig is the tuning new rooms start with.
+var simConfig = loadSimConfig(os.Getenv("CITYSIM_CONFIG"))
+
+// loadSimConfig overlays the JSON file at path (if any) on the defaults, keeping the defaults if the
+// file can't be read or holds out-of-range values.
+func loadSimConfig(path string) SimConfig {
+	cfg := defaultSimConfig()
+	if path == "" {
+		return cfg
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("ignoring CITYSIM_CONFIG:", err)
+		return cfg
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Println("ignoring CITYSIM_CONFIG:", err)
+		return defaultSimConfig()
+	}
+	if err := cfg.validate(); err != nil {
+		log.Println("ignoring CITYSIM_CONFIG:", err)
+		return defaultSimConfig()
+	}
+	return cfg
+}
+
+func (c SimConfig) validate() error {
+	switch {
+	case c.IndustrialCapacity < 1 || c.CommercialCapacity < 1:
+		return fmt.Errorf("job capacities must be at least 1")
+	case c.CommercialCustomerNeed < 0 || c.AIWaterReserve < 0 || c.AIPowerReserve < 0 || c.AIMaxBridgeLen < 0 || c.AbandonGraceTicks < 0:
+		return fmt.Errorf("commercialCustomerNeed, aiWaterReserve, aiPowerReserve, aiMaxBridgeLen and abandonGraceTicks must not be negative")
+	case c.AbandonTriggerTicksBase < 1 || c.CommercialAbandonFactor < 1:
+		return fmt.Errorf("abandonment ticks and factor must be at least 1")
+	case c.MaxCommercialSupplies < 2:
+		return fmt.Errorf("maxCommercialSupplies must be at least 2")
+	case c.AIActionInterval < 1:
+		return fmt.Errorf("aiActionInterval must be at least 1")
+	case c.AIBridgeChance < 0 || c.AIBridgeChance > 1:
+		return fmt.Errorf("aiBridgeChance must be between 0 and 1")
+	}
+	return nil
 }
+
+// directMessage is a message addressed to a single client rather than broadcast.
+type directMessage struct {
+	client *Client
+	msg    []byte
+}
+
 type Hub struct {
 	clients    map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
-	broadcast  chan []byte
+	broadcast  chan broadcastMessage
+	direct     chan directMessage
+	subscribe  chan subscription
+	quit       chan struct{} // closed by stop to end run
+	done       chan struct{} // closed once run has disconnected every client and returned
+	binary     atomic.Int32  // registered clients that negotiated msgpack
+	connected  atomic.Int32  // registered clients; the room's loops idle while there are none
+}
+
+// broadcastMessage is one broadcast as JSON text and, for binaryEvents while msgpack clients are
+// connected, as msgpack.
+type broadcastMessage struct {
+	text, binary []byte
+	except       *Client // if set, not sent to this client
+	event        string  // event type, for subscriptions; empty ones reach every client
+}
+
+// subscription replaces a client's set of subscribed broadcast events; nil subscribes to all.
+type subscription struct {
+	client *Client
+	events map[string]bool
 }
 
 func newHub() *Hub {
-	return &Hub{clients: map[*Client]bool{}, register: make(chan *Client), unregister: make(chan *Client), broadcast: make(chan []byte, 256)}
+	return &Hub{clients: map[*Client]bool{}, register: make(chan *Client), unregister: make(chan *Client), broadcast: make(chan broadcastMessage, 256), direct: make(chan directMessage, 256), subscribe: make(chan subscription), quit: make(chan struct{}), done: make(chan struct{})}
+}
+
+// stop ends run, closing every client's send channel (which closes its connection), and waits.
+func (h *Hub) stop() {
+	close(h.quit)
+	<-h.done
+}
+
+// publish queues a broadcast, dropping it if the hub has stopped.
+func (h *Hub) publish(msg broadcastMessage) {
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
 }
+
+// drop removes a registered client and closes its send channel.
+func (h *Hub) drop(c *Client) {
+	delete(h.clients, c)
+	h.connected.Add(-1)
+	close(c.send)
+	if c.binary {
+		h.binary.Add(-1)
+	}
+}
+
 func (h *Hub) run() {
+	defer close(h.done)
 	for {
 		select {
+		case <-h.quit:
+			for c := range h.clients {
+				h.drop(c)
+			}
+			return
 		case c := <-h.register:
 			h.clients[c] = true
+			h.connected.Add(1)
+			if c.binary {
+				h.binary.Add(1)
+			}
 		case c := <-h.unregister:
 			if h.clients[c] {
-				delete(h.clients, c)
-				close(c.send)
+				h.drop(c)
+			}
+		case sub := <-h.subscribe:
+			if h.clients[sub.client] {
+				sub.client.events = sub.events
+			}
+		case dm := <-h.direct:
+			if h.clients[dm.client] {
+				select {
+				case dm.client.send <- dm.msg:
+				default:
+					h.drop(dm.client)
+				}
 			}
-		case msg := <-h.broadcast:
+		case bm := <-h.broadcast:
 			for c := range h.clients {
+				if c == bm.except || c.events != nil && bm.event != "" && !c.events[bm.event] {
+					continue
+				}
+				msg := bm.text
+				if c.binary && bm.binary != nil {
+					msg = bm.binary
+				}
 				select {
 				case c.send <- msg:
 				default:
-					delete(h.clients, c)
-					close(c.send)
+					h.drop(c)
 				}
 			}
 		}
@@ -368,843 +1281,5687 @@ func (h *Hub) run() {
 }
 
 func (c *Client) reader() {
-	defer func() { hub.unregister <- c; c.conn.Close() }()
+	defer func() {
+		select {
+		case c.room.hub.unregister <- c:
+		case <-c.room.hub.done:
+		}
+		c.conn.Close()
+		if !c.spectator {
+			c.room.dropCursor(c.id)
+			c.room.input(journalEntry{Kind: journalLeave, Player: c.id})
+		}
+	}()
+	c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	})
 	for {
 		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			return
 		}
-		var env Envelope
-		if json.Unmarshal(data, &env) != nil {
-			continue
-		}
-		switch env.Type {
-		case ActionPlaceZone:
-			var p PlaceZonePayload
-			if json.Unmarshal(env.Payload, &p) == nil {
-				placeZone(c.id, p)
-			}
-		case ActionBulldoze:
-			var p BulldozePayload
-			if json.Unmarshal(env.Payload, &p) == nil {
-				bulldoze(c.id, p)
-			}
-		case ActionPlaceStructure:
-			var p PlaceStructurePayload
-			if json.Unmarshal(env.Payload, &p) == nil {
-				placeStructure(c.id, p)
-			}
-		}
-	}
-}
-func (c *Client) writer() {
-	for msg := range c.send {
-		c.conn.WriteMessage(websocket.TextMessage, msg)
-	}
-}
-
-var hub = newHub()
-var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
-
-func wsHandler(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
-	if name == "" {
-		name = "Player"
-	}
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		return
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait)) // any message proves the client is alive
+		c.handleMessage(data)
 	}
-	id := PlayerID(uuid.New().String())
-	c := &Client{id: id, conn: conn, send: make(chan []byte, 128)}
-	gameMu.Lock()
-	game.Players[id] = &Player{ID: id, Name: name, Money: 100000}
-	gameMu.Unlock()
-	hub.register <- c
-	go c.writer()
-	go c.reader()
-	sendFullState(c)
-}
-
-func sendFullState(c *Client) {
-	gameMu.Lock()
-	defer gameMu.Unlock()
-	payload, _ := json.Marshal(game)
-	env := Envelope{Type: EventFullState, Payload: payload}
-	b, _ := json.Marshal(env)
-	c.send <- b
 }
 
-func placeZone(pid PlayerID, p PlaceZonePayload) {
-	if !inBounds(p.X, p.Y) {
+// handleMessage applies one client message. A panic while handling it is logged and reported to
+// the client as internal_error instead of taking down the server; the client stays connected.
+func (c *Client) handleMessage(data []byte) {
+	var env Envelope
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("room %s: panic handling %q from %s: %v\n%s", c.room.Code, env.Type, c.id, v, debug.Stack())
+			c.sendEvent(EventActionError, ActionError{Action: env.Type, Reason: ReasonInternalError})
+			c.ack(env.Seq, ReasonInternalError)
+		}
+	}()
+	if json.Unmarshal(data, &env) != nil {
 		return
 	}
-	gameMu.Lock()
-	defer gameMu.Unlock()
-	t := game.Tiles[p.Y][p.X]
-	if t.Zone != nil || t.Road != nil || t.Structure != nil {
+	if env.Type == ActionCursor { // throttled on its own so pointer moves don't use up the action rate
+		reason := c.moveCursor(env.Payload)
+		if reason != "" {
+			c.sendEvent(EventActionError, ActionError{Action: env.Type, Reason: reason})
+		}
+		c.ack(env.Seq, reason)
 		return
 	}
-	pl := game.Players[pid]
-	if pl.Money < 100 {
+	if !c.limiter.allow(time.Now()) { // drop actions beyond the client's rate
+		c.ack(env.Seq, ReasonRateLimited)
 		return
 	}
-	pl.Money -= 100
-	// Clear foliage when zoning
-	t.Foliage = ""
-	t.Zone = &Zone{Type: p.Zone, Owner: pid, PlacedAt: time.Now().Unix()}
-	announce(EventZonePlaced, ZonePlacedEvent{X: p.X, Y: p.Y, Zone: t.Zone})
-}
-func placeStructure(pid PlayerID, p PlaceStructurePayload) {
-	if p.Kind != "power_plant" {
+	if env.Type == ActionBulldoze && !c.bulldoze.allow(time.Now()) {
+		c.ack(env.Seq, ReasonRateLimited)
 		return
 	}
-	if !inBounds(p.X, p.Y) {
+	if c.spectator && !readOnlyActions[env.Type] {
+		c.sendEvent(EventActionError, ActionError{Action: env.Type, Reason: ReasonSpectator})
+		c.ack(env.Seq, ReasonSpectator)
 		return
 	}
-	gameMu.Lock()
-	defer gameMu.Unlock()
-	t := game.Tiles[p.Y][p.X]
-	if t.Structure != nil || t.Zone != nil || t.Road != nil {
+	var reason string
+	switch env.Type {
+	case ActionRequestRoster:
+		c.sendRoster()
+	case ActionRequestHistory:
+		c.sendHistory()
+	case ActionRequestOwnership:
+		c.sendOwnership()
+	case ActionRequestLeaderboard:
+		c.sendLeaderboard()
+	case ActionChat:
+		var p ChatPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = c.chat(p)
+		}
+	case ActionInspectTile:
+		var p InspectTilePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = c.sendTileInfo(p)
+		}
+	case ActionQueryCapacity:
+		var p QueryCapacityPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = c.sendCapacity(p)
+		}
+	case ActionRequestSync:
+		var p RequestSyncPayload
+		if len(env.Payload) == 0 || json.Unmarshal(env.Payload, &p) == nil {
+			c.sendStateDiff(p.Since)
+		} else {
+			reason = ReasonBadPayload
+		}
+	case ActionAdminClearRect, ActionAdminReset, ActionAddBot, ActionRemoveBot, ActionSetZoningBuffer, ActionSetSpeed:
+		if reason = c.checkAdmin(env.Payload); reason == "" {
+			reason = c.room.input(journalEntry{Kind: journalAction, Player: c.id, Action: env.Type, Payload: withoutToken(env.Payload)})
+		}
+	case ActionSubscribe:
+		var p SubscribePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			c.subscribe(p)
+		}
+	case ActionTileHistory:
+		var p TileHistoryPayload
+		if reason = c.checkAdmin(env.Payload); reason == "" {
+			if reason = decodePayload(env.Payload, &p); reason == "" {
+				reason = c.sendTileHistory(p)
+			}
+		}
+	default:
+		reason = c.room.input(journalEntry{Kind: journalAction, Player: c.id, Action: env.Type, Payload: env.Payload})
+	}
+	if reason != "" { // tell only the acting client why nothing happened
+		c.sendEvent(EventActionError, ActionError{Action: env.Type, Reason: reason})
+	}
+	c.ack(env.Seq, reason)
+}
+
+// apply performs a state-changing action for pid and returns "" or the Reason it was rejected.
+// Client actions reach it through input, so they are journaled.
+func (r *Room) apply(pid PlayerID, env Envelope) string {
+	var reason string
+	switch env.Type {
+	case ActionPlaceZone:
+		var p PlaceZonePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.placeZone(pid, p)
+		}
+	case ActionPlaceZoneRect:
+		var p PlaceZoneRectPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.placeZoneRect(pid, p)
+		}
+	case ActionPlaceRoad:
+		var p PlaceRoadPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.placeRoad(pid, p)
+		}
+	case ActionBuildRoadPath:
+		var p BuildRoadPathPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.buildRoadPath(pid, p)
+		}
+	case ActionBulldoze:
+		var p BulldozePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.bulldoze(pid, p)
+		}
+	case ActionPlaceStructure:
+		var p PlaceStructurePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.placeStructure(pid, p)
+		}
+	case ActionPlaceRail:
+		var p PlaceRailPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.placeRail(pid, p)
+		}
+	case ActionSetSpeed:
+		var p SetSpeedPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.setSpeed(p)
+		}
+	case ActionSetName:
+		var p SetNamePayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.setName(pid, p)
+		}
+	case ActionSetColor:
+		var p SetColorPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.setColor(pid, p)
+		}
+	case ActionUndo:
+		reason = r.undoLast(pid)
+	case ActionSetZoningBuffer:
+		var p SetZoningBufferPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			r.setZoningBuffer(p)
+		}
+	case ActionAddBot:
+		var p AddBotPayload
+		if len(env.Payload) == 0 || json.Unmarshal(env.Payload, &p) == nil {
+			reason = r.addBot(p)
+		} else {
+			reason = ReasonBadPayload
+		}
+	case ActionRemoveBot:
+		var p RemoveBotPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.removeBot(p)
+		}
+	case ActionTakeLoan:
+		var p LoanPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.takeLoan(pid, p)
+		}
+	case ActionAdminClearRect:
+		var p AdminClearRectPayload
+		if reason = decodePayload(env.Payload, &p); reason == "" {
+			reason = r.adminClearRect(p)
+		}
+	case ActionAdminReset:
+		var p AdminResetPayload
+		if len(env.Payload) == 0 || json.Unmarshal(env.Payload, &p) == nil {
+			r.adminReset(p)
+		} else {
+			reason = ReasonBadPayload
+		}
+	case ActionRepayLoan:
+		var p LoanPayload
+		if len(env.Payload) == 0 || json.Unmarshal(env.Payload, &p) == nil {
+			reason = r.repayLoan(pid, p)
+		} else {
+			reason = ReasonBadPayload
+		}
+	default:
+		reason = ReasonUnknownAction
+	}
+	return reason
+}
+
+// Ack confirms or rejects a client's numbered action.
+type Ack struct {
+	Seq    uint64 `json:"seq"`
+	OK     bool   `json:"ok"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ack answers an action that carried a seq; actions without one are not acknowledged.
+func (c *Client) ack(seq uint64, reason string) {
+	if seq == 0 {
 		return
 	}
-	pl := game.Players[pid]
-	if pl.Money < 5000 {
+	c.sendEvent(EventAck, Ack{Seq: seq, OK: reason == "", Reason: reason})
+}
+
+// Reason codes sent in action_error events
+const (
+	ReasonBadPayload        = "bad_payload"
+	ReasonUnknownAction     = "unknown_action"
+	ReasonRateLimited       = "rate_limited"
+	ReasonSpectator         = "spectator"
+	ReasonNoPlayer          = "no_player"
+	ReasonInvalidType       = "invalid_type" // unknown zone, structure, road kind/direction or speed
+	ReasonInvalidName       = "invalid_name"
+	ReasonInvalidText       = "invalid_text"
+	ReasonRoomEnded         = "room_ended"
+	ReasonInvalidColor      = "invalid_color"
+	ReasonOutOfBounds       = "out_of_bounds"
+	ReasonOccupied          = "occupied"
+	ReasonUnbuildable       = "unbuildable" // water
+	ReasonTooSteep          = "too_steep"
+	ReasonInsufficientFunds = "insufficient_funds"
+	ReasonNoRoute           = "no_route"
+	ReasonNothingToUndo     = "nothing_to_undo"
+	ReasonTileChanged       = "tile_changed"
+	ReasonCreditLimit       = "credit_limit"
+	ReasonNoDebt            = "no_debt"
+	ReasonBotLimit          = "bot_limit"
+	ReasonCooldown          = "cooldown" // tile bulldozed too recently
+	ReasonUnauthorized      = "unauthorized"
+	ReasonInternalError     = "internal_error" // the server failed handling the action
+)
+
+// readOnlyActions are the actions a spectator may send.
+var readOnlyActions = map[string]bool{ActionSubscribe: true, ActionRequestRoster: true, ActionRequestSync: true, ActionRequestHistory: true, ActionInspectTile: true, ActionRequestOwnership: true, ActionRequestLeaderboard: true, ActionQueryCapacity: true}
+
+// ActionError tells a client why its action was rejected.
+type ActionError struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+func decodePayload(raw json.RawMessage, v interface{}) string {
+	if json.Unmarshal(raw, v) != nil {
+		return ReasonBadPayload
+	}
+	return ""
+}
+func (c *Client) writer() {
+	ticker := time.NewTicker(c.pongWait * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close() // unblocks reader, which unregisters the client
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok { // hub dropped us or is shutting down
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			// permessage-deflate costs more than it saves on tiny frames (acks, single tile events)
+			c.conn.EnableWriteCompression(len(msg) >= compressMinBytes)
+			frame := websocket.TextMessage
+			if len(msg) > 0 && msg[0] != '{' { // msgpack; JSON envelopes always start with '{'
+				frame = websocket.BinaryMessage
+			}
+			if err := c.conn.WriteMessage(frame, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Keepalive: the server pings at 9/10 of pongWait and drops clients that send nothing (not even
+// a pong) within pongWait, or whose writes stall longer than writeWait.
+const (
+	writeWait = 10 * time.Second
+	pongWait  = 60 * time.Second
+)
+
+// compressMinBytes is the smallest outgoing message worth compressing.
+const compressMinBytes = 512
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin:       func(r *http.Request) bool { return true },
+	EnableCompression: true, // negotiated per connection; clients without permessage-deflate get plain frames
+}
+
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := sanitizeName(r.URL.Query().Get("name"))
+	if !ok {
+		name = "Player"
+	}
+	code := r.URL.Query().Get("room")
+	if code == "" {
+		code = defaultRoomCode
+	}
+	if !validRoomCode(code) {
+		http.Error(w, "invalid room code", http.StatusBadRequest)
 		return
 	}
-	pl.Money -= 5000
-	t.Structure = &Structure{Type: p.Kind, Owner: pid, PlacedAt: time.Now().Unix()}
-	announce(EventStructurePlaced, struct {
-		X         int        `json:"x"`
-		Y         int        `json:"y"`
-		Structure *Structure `json:"structure"`
-	}{p.X, p.Y, t.Structure})
+	spectate, _ := strconv.ParseBool(r.URL.Query().Get("spectate"))
+	useMsgpack := r.URL.Query().Get("format") == "msgpack"
+	if findRoom(code) == nil && roomCount() >= maxRooms {
+		http.Error(w, "too many rooms", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	room := joinRoom(code)
+	if room == nil { // the last free slot went to another room since the check above
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many rooms"), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	defer room.joining.Add(-1)
+	id := PlayerID(uuid.New().String())
+	conn.SetCompressionLevel(flate.BestSpeed)
+	c := &Client{id: id, room: room, conn: conn, send: make(chan []byte, 128), limiter: newTokenBucket(actionRate, actionBurst), bulldoze: newTokenBucket(bulldozeRate, bulldozeBurst), pongWait: pongWait, spectator: spectate, binary: useMsgpack}
+	if !spectate { // joined before registering, so the announcement goes only to the others
+		room.input(journalEntry{Kind: journalJoin, Player: id, Name: name, Money: startMoney})
+	}
+	select {
+	case room.hub.register <- c:
+	case <-room.hub.done:
+		conn.Close()
+		return
+	}
+	go c.writer()
+	go c.reader()
+	c.sendFullState()
+	c.sendChatBacklog()
 }
 
-func bulldoze(pid PlayerID, p BulldozePayload) {
-	if !inBounds(p.X, p.Y) {
+// playerJoined adds a connected player and tells the room.
+func (r *Room) playerJoined(pid PlayerID, name string, money int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pl := &Player{ID: pid, Name: name, Money: money, Connected: true}
+	r.game.Players[pid] = pl
+	r.game.announce(EventPlayerJoined, pl.info())
+}
+
+// playerLeft marks a disconnected client's player offline and tells the room. The player and
+// its money stay in the game.
+func (r *Room) playerLeft(pid PlayerID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pl := r.game.Players[pid]
+	if pl == nil {
 		return
 	}
-	gameMu.Lock()
-	defer gameMu.Unlock()
+	pl.Connected = false
+	r.game.announce(EventPlayerLeft, pl.info())
+}
+
+// sendRoster sends the requesting client the connected players plus bots, ordered by name.
+func (c *Client) sendRoster() {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	roster := []PlayerInfo{}
+	for _, pl := range c.room.game.Players {
+		if pl.Connected || pl.Bot {
+			roster = append(roster, pl.info())
+		}
+	}
+	sort.Slice(roster, func(i, j int) bool {
+		if roster[i].Name != roster[j].Name {
+			return roster[i].Name < roster[j].Name
+		}
+		return roster[i].ID < roster[j].ID
+	})
+	c.sendEvent(EventRoster, struct {
+		Players []PlayerInfo `json:"players"`
+	}{roster})
+}
+
+// TileInfo is the click-to-inspect detail of one tile.
+type TileInfo struct {
+	*Tile
+	IdleTicks int      `json:"idleTicks,omitempty"` // building ticks without workers or customers
+	Owner     PlayerID `json:"owner,omitempty"`     // of the zone, else the structure, else the road
+	OwnerName string   `json:"ownerName,omitempty"`
+	Watered   bool     `json:"watered"`
+	Powered   bool     `json:"powered"`
+}
+
+// sendTileInfo sends the requesting client the details of one tile.
+func (c *Client) sendTileInfo(p InspectTilePayload) string {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	game := c.room.game
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
+	}
 	t := game.Tiles[p.Y][p.X]
-	t.Zone = nil
-	t.Building = nil
-	t.Road = nil
-	t.Structure = nil
-	announce(EventBulldozed, struct {
-		X int `json:"x"`
-		Y int `json:"y"`
-	}{p.X, p.Y})
+	info := TileInfo{Tile: t, Powered: game.powered()}
+	info.Owner = tileOwner(t)
+	if pl := game.Players[info.Owner]; pl != nil {
+		info.OwnerName = pl.Name
+	}
+	if b := t.Building; b != nil {
+		info.IdleTicks = b.IdleTicks
+		info.Watered = b.Watered
+		info.Powered = b.Powered
+	}
+	c.sendEvent(EventTileInfo, info)
+	return ""
 }
 
-type TickSummary struct {
-	Tick       int64  `json:"tick"`
-	Demand     Demand `json:"demand"`
-	Population int    `json:"population"`
-	Employed   int    `json:"employed"`
+// ZoneCapacity sums one zone type over a rectangle. Capacity is the residents (R) or jobs (C, I)
+// the zoned tiles support once every building there is finished; Occupied is what they hold now.
+type ZoneCapacity struct {
+	Zoned    int `json:"zoned"`
+	Building int `json:"building"` // under construction
+	Finished int `json:"finished"`
+	Capacity int `json:"capacity"`
+	Occupied int `json:"occupied"`
 }
 
-type BuildingUpdate struct {
-	X        int       `json:"x"`
-	Y        int       `json:"y"`
-	Building *Building `json:"building"`
+// CapacityReport answers query_capacity for the rectangle, clipped to the map.
+type CapacityReport struct {
+	X0          int          `json:"x0"`
+	Y0          int          `json:"y0"`
+	X1          int          `json:"x1"`
+	Y1          int          `json:"y1"`
+	Residential ZoneCapacity `json:"residential"`
+	Commercial  ZoneCapacity `json:"commercial"`
+	Industrial  ZoneCapacity `json:"industrial"`
 }
 
-// progressBuildings advances simple construction stages for zones without final buildings.
-func progressBuildings() []BuildingUpdate {
-	updates := []BuildingUpdate{}
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
+// capacityOf is how many residents or jobs one finished building of the zone type supports.
+func (game *GameState) capacityOf(z ZoneType) int {
+	switch z {
+	case Residential:
+		return maxResidents
+	case Industrial:
+		return game.Config.IndustrialCapacity
+	default:
+		return game.Config.CommercialCapacity
+	}
+}
+
+// capacityReport projects the capacity of the zoned tiles in a rectangle from their zones and
+// building stages, alongside their current residents and employees.
+func (game *GameState) capacityReport(p QueryCapacityPayload) (CapacityReport, string) {
+	x0, x1 := max(min(p.X0, p.X1), 0), min(max(p.X0, p.X1), game.Width-1)
+	y0, y1 := max(min(p.Y0, p.Y1), 0), min(max(p.Y0, p.Y1), game.Height-1)
+	if x0 > x1 || y0 > y1 {
+		return CapacityReport{}, ReasonOutOfBounds
+	}
+	rep := CapacityReport{X0: x0, Y0: y0, X1: x1, Y1: y1}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
 			t := game.Tiles[y][x]
-			if t.Zone != nil && t.Building == nil { // start
-				b := &Building{Type: t.Zone.Type, Stage: 1}
-				t.Building = b
-				updates = append(updates, BuildingUpdate{X: x, Y: y, Building: b})
-			} else if t.Building != nil && !t.Building.Final {
-				if t.Building.Stage < 3 {
-					t.Building.Stage++
-				} else {
-					t.Building.Final = true
-					ct := time.Now().Unix()
-					t.Building.CompletedAt = &ct
-				}
-				updates = append(updates, BuildingUpdate{X: x, Y: y, Building: t.Building})
+			if t.Zone == nil || t.Zone.Type == Reserve {
+				continue
+			}
+			zc := &rep.Commercial
+			switch t.Zone.Type {
+			case Residential:
+				zc = &rep.Residential
+			case Industrial:
+				zc = &rep.Industrial
+			}
+			zc.Zoned++
+			zc.Capacity += game.capacityOf(t.Zone.Type)
+			switch b := t.Building; {
+			case b == nil:
+			case b.Final:
+				zc.Finished++
+				zc.Occupied += b.Residents + b.Employees
+			default:
+				zc.Building++
 			}
 		}
 	}
-	return updates
+	return rep, ""
 }
 
-func gameLoop() {
-	ticker := time.NewTicker(time.Second)
-	for range ticker.C {
-		stepGame()
+// sendCapacity sends the requesting client the capacity report for a rectangle.
+func (c *Client) sendCapacity(p QueryCapacityPayload) string {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	rep, reason := c.room.game.capacityReport(p)
+	if reason == "" {
+		c.sendEvent(EventCapacity, rep)
 	}
+	return reason
 }
-func stepGame() {
-	gameMu.Lock()
-	defer gameMu.Unlock()
-	// prune expired short-term road protection entries (prevent zoning over very recent roads)
-	if game.JustRoadThisTick == nil {
-		game.JustRoadThisTick = map[[2]int]int64{}
-	} else {
-		for k, exp := range game.JustRoadThisTick {
-			if exp <= game.Tick { // expired
-				delete(game.JustRoadThisTick, k)
-			}
-		}
+
+// ================= Cursors =================
+const (
+	cursorInterval = 100 * time.Millisecond // cursor moves closer together than this are dropped
+	cursorTimeout  = 10 * time.Second       // cursors not moved for this long are removed
+)
+
+type CursorPayload struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Cursor is where a player is pointing; Gone tells the other clients to remove it.
+type Cursor struct {
+	ID    PlayerID `json:"id"`
+	Color string   `json:"color,omitempty"`
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Gone  bool     `json:"gone,omitempty"`
+}
+
+// moveCursor rebroadcasts the client's cursor to everyone else, at most once per cursorInterval.
+// Cursors are presentation only: they bypass the action rate limit and the replay journal.
+func (c *Client) moveCursor(raw json.RawMessage) string {
+	if c.spectator {
+		return ReasonSpectator
 	}
-	game.Tick++
-	adjustDemand(&game.Demand) // baseline drift
-	updates := progressBuildings()
-	gt := growthTick()
-	if len(gt) > 0 {
-		updates = append(updates, gt...)
+	var p CursorPayload
+	if reason := decodePayload(raw, &p); reason != "" {
+		return reason
 	}
-	simulateCitizens()
-	alloc := allocateLaborAndSupplies()
-	if len(alloc) > 0 {
-		updates = append(updates, alloc...)
+	now := time.Now()
+	if now.Sub(c.lastCursor) < cursorInterval {
+		return ""
 	}
-	// Employment & demand adjustment
-	employmentDemandAdjust(&updates)
-	economicTick()
-	aiTick()
-	// Reconcile building updates after AI actions (e.g., bulldoze+road) so we don't send stale building pointers
-	if len(updates) > 0 {
-		for i := range updates {
-			bx, by := updates[i].X, updates[i].Y
-			if inBounds(bx, by) {
-				updates[i].Building = game.Tiles[by][bx].Building // current final state (nil if bulldozed)
-			}
-		}
+	c.room.mu.RLock()
+	ev := Cursor{ID: c.id, X: p.X, Y: p.Y}
+	inBounds := c.room.game.inBounds(p.X, p.Y)
+	if pl := c.room.game.Players[c.id]; pl != nil {
+		ev.Color = pl.Color
 	}
-	if len(updates) > 0 {
-		announce(EventBuildingUpdate, struct {
-			Updates []BuildingUpdate `json:"updates"`
-		}{updates})
+	c.room.mu.RUnlock()
+	if !inBounds {
+		return ReasonOutOfBounds
 	}
-	announce(EventTick, gameSummary())
+	c.lastCursor = now
+	c.room.cursorMu.Lock()
+	c.room.cursors[c.id] = now
+	c.room.cursorMu.Unlock()
+	c.room.publishCursor(ev, c)
+	return ""
 }
-func gameSummary() TickSummary {
-	return TickSummary{Tick: game.Tick, Demand: game.Demand, Population: game.Population, Employed: game.Employed}
+
+// publishCursor broadcasts a cursor event to every client but except.
+func (r *Room) publishCursor(ev Cursor, except *Client) {
+	payload, _ := json.Marshal(ev)
+	b, _ := json.Marshal(Envelope{Type: EventCursor, Payload: payload})
+	r.hub.publish(broadcastMessage{text: b, except: except, event: EventCursor})
 }
 
-// employmentDemandAdjust recalculates employment, adjusts demands and triggers out-migration when sustained unemployment.
-func employmentDemandAdjust(updates *[]BuildingUpdate) {
-	jobCapacity := 0
-	actualEmployees := 0
-	industrialEmployees := 0
-	commercialEmployees := 0
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
-			b := game.Tiles[y][x].Building
-			if b == nil || !b.Final || b.AbandonPhase > 0 {
-				continue
+// dropCursor removes pid's cursor, if shown, from every client.
+func (r *Room) dropCursor(pid PlayerID) {
+	r.cursorMu.Lock()
+	_, shown := r.cursors[pid]
+	delete(r.cursors, pid)
+	r.cursorMu.Unlock()
+	if shown {
+		r.publishCursor(Cursor{ID: pid, Gone: true}, nil)
+	}
+}
+
+// cursorLoop removes cursors that haven't moved for cursorTimeout.
+func (r *Room) cursorLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case now := <-ticker.C:
+			var stale []PlayerID
+			r.cursorMu.Lock()
+			for pid, at := range r.cursors {
+				if now.Sub(at) >= cursorTimeout {
+					stale = append(stale, pid)
+				}
 			}
-			switch b.Type {
-			case Industrial:
-				jobCapacity += industrialCapacity
-				industrialEmployees += b.Employees
-			case Commercial:
-				jobCapacity += commercialCapacity
-				commercialEmployees += b.Employees
+			r.cursorMu.Unlock()
+			for _, pid := range stale {
+				r.dropCursor(pid)
 			}
-			actualEmployees += b.Employees
 		}
 	}
-	game.Employed = actualEmployees
-	unemployed := game.Population - actualEmployees
-	if game.Population == 0 {
+}
+
+// ================= Chat =================
+const (
+	maxChatLen  = 280 // runes kept of a chat message
+	chatBacklog = 20  // recent messages sent to joining clients
+)
+
+type ChatPayload struct {
+	Text string `json:"text"`
+}
+
+// ChatMessage is a relayed chat line; At is the server's receive time in unix milliseconds.
+type ChatMessage struct {
+	ID   PlayerID `json:"id"`
+	Name string   `json:"name"`
+	Text string   `json:"text"`
+	At   int64    `json:"at"`
+}
+
+// sanitizeChat drops control characters, trims surrounding space and truncates to maxChatLen runes;
+// it fails if nothing is left.
+func sanitizeChat(raw string) (string, bool) {
+	text := strings.TrimSpace(strings.Map(func(ch rune) rune {
+		if unicode.IsControl(ch) || ch == utf8.RuneError {
+			return -1
+		}
+		return ch
+	}, raw))
+	if utf8.RuneCountInString(text) > maxChatLen {
+		text = strings.TrimSpace(string([]rune(text)[:maxChatLen]))
+	}
+	return text, text != ""
+}
+
+// chat relays a message from the client's player to the whole room and keeps it for joiners.
+// Chat is not game state: it skips the replay journal and isn't saved.
+func (c *Client) chat(p ChatPayload) string {
+	text, ok := sanitizeChat(p.Text)
+	if !ok {
+		return ReasonInvalidText
+	}
+	c.room.mu.RLock()
+	pl := c.room.game.Players[c.id]
+	var name string
+	if pl != nil {
+		name = pl.Name
+	}
+	c.room.mu.RUnlock()
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	msg := ChatMessage{ID: c.id, Name: name, Text: text, At: time.Now().UnixMilli()}
+	r := c.room
+	r.chatMu.Lock()
+	r.chatLog = append(r.chatLog, msg)
+	if len(r.chatLog) > chatBacklog {
+		r.chatLog = r.chatLog[len(r.chatLog)-chatBacklog:]
+	}
+	r.chatMu.Unlock()
+	payload, _ := json.Marshal(msg)
+	b, _ := json.Marshal(Envelope{Type: EventChat, Payload: payload})
+	r.hub.publish(broadcastMessage{text: b, event: EventChat})
+	return ""
+}
+
+// sendChatBacklog sends a joining client the room's recent chat, if any.
+func (c *Client) sendChatBacklog() {
+	c.room.chatMu.Lock()
+	msgs := slices.Clone(c.room.chatLog)
+	c.room.chatMu.Unlock()
+	if len(msgs) == 0 {
 		return
 	}
-	ratio := float64(unemployed) / float64(game.Population)
-	// Compute residential capacity & open slots fresh for demand basis
-	resCap := 0
-	resUsed := 0
+	c.sendEvent(EventChatBacklog, struct {
+		Messages []ChatMessage `json:"messages"`
+	}{msgs})
+}
+
+// ================= Ownership overlay =================
+
+// tileOwner is who owns t: the zone's owner, else the structure's, else the road's or rail's; "" if nobody.
+func tileOwner(t *Tile) PlayerID {
+	switch {
+	case t.Zone != nil:
+		return t.Zone.Owner
+	case t.Structure != nil:
+		return t.Structure.Owner
+	case t.Road != nil:
+		return t.Road.Owner
+	case t.Rail != nil:
+		return t.Rail.Owner
+	}
+	return ""
+}
+
+// OwnerRun is Len owned tiles in a row starting at (X,Y) and running east.
+type OwnerRun struct {
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Len   int      `json:"len"`
+	Owner PlayerID `json:"owner"`
+}
+
+// OwnerChange is one tile whose owner changed; Owner is "" once nobody owns it.
+type OwnerChange struct {
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Owner PlayerID `json:"owner"`
+}
+
+// ownerRuns run-length encodes the owned tiles of each row.
+func (game *GameState) ownerRuns() []OwnerRun {
+	runs := []OwnerRun{}
 	for y := 0; y < game.Height; y++ {
 		for x := 0; x < game.Width; x++ {
-			if b := game.Tiles[y][x].Building; b != nil && b.Final && b.Type == Residential && b.AbandonPhase == 0 {
-				resCap += 10
-				resUsed += b.Residents
+			owner := tileOwner(game.Tiles[y][x])
+			if owner == "" {
+				continue
+			}
+			if n := len(runs); n > 0 && runs[n-1].Y == y && runs[n-1].X+runs[n-1].Len == x && runs[n-1].Owner == owner {
+				runs[n-1].Len++
+				continue
 			}
+			runs = append(runs, OwnerRun{x, y, 1, owner})
 		}
 	}
-	openSlots := resCap - resUsed
-	// Residential demand core rule: fewer slots => higher demand.
-	if resCap == 0 {
-		game.Demand.Residential += 8
-	} else if openSlots <= 0 { // full
-		game.Demand.Residential += 6
-	} else if openSlots < 8 {
-		game.Demand.Residential += 3
-	} else if openSlots > 50 {
-		game.Demand.Residential -= 4
-	} else if openSlots > 30 {
-		game.Demand.Residential -= 2
-	}
+	return runs
+}
 
-	// Job-driven adjustments: if many unfilled jobs (capacity >> population), increase residential to attract workers.
-	unfilledJobs := jobCapacity - actualEmployees
-	if unfilledJobs > 0 {
-		// scale bonus with unfilled ratio but cap
-		bonus := unfilledJobs / 10
-		if bonus > 6 {
-			bonus = 6
+// sendOwnership sends the requesting client the whole ownership overlay; ownership_update
+// broadcasts keep it current afterwards.
+func (c *Client) sendOwnership() {
+	c.room.mu.RLock()
+	game := c.room.game
+	ev := struct {
+		Width  int        `json:"width"`
+		Height int        `json:"height"`
+		Runs   []OwnerRun `json:"runs"`
+	}{game.Width, game.Height, game.ownerRuns()}
+	c.room.mu.RUnlock()
+	c.sendEvent(EventOwnership, ev)
+}
+
+// flushOwnership rechecks the owner of every tile marked since the last call and broadcasts the
+// tiles whose owner changed.
+func (game *GameState) flushOwnership() {
+	if game.owners == nil {
+		game.owners = make([][]PlayerID, game.Height)
+		for y := range game.owners {
+			game.owners[y] = make([]PlayerID, game.Width)
+			for x := range game.owners[y] {
+				game.owners[y][x] = tileOwner(game.Tiles[y][x])
+			}
 		}
-		if bonus > 0 {
-			game.Demand.Residential += bonus
+		game.ownerDirty = nil
+		return
+	}
+	changes := []OwnerChange{}
+	for _, k := range game.ownerDirty {
+		x, y := k[0], k[1]
+		if owner := tileOwner(game.Tiles[y][x]); owner != game.owners[y][x] {
+			game.owners[y][x] = owner
+			changes = append(changes, OwnerChange{x, y, owner})
 		}
 	}
+	game.ownerDirty = game.ownerDirty[:0]
+	if len(changes) > 0 {
+		game.announce(EventOwnershipUpdate, struct {
+			Tiles []OwnerChange `json:"tiles"`
+		}{changes})
+	}
+}
 
-	// Unemployment handling: if moderate unemployment, lightly push non-residential, but don't tank residential harshly.
-	if ratio > 0.25 { // noticeable unemployment
-		game.Demand.Industrial += 2
-		game.Demand.Commercial += 1
-		// light out-migration pressure
-		if rand.Float64() < ratio*0.1 {
-			removed := 0
-			target := 2 + rand.Intn(4)
-			for y := 0; y < game.Height && removed < target; y++ {
-				for x := 0; x < game.Width && removed < target; x++ {
-					b := game.Tiles[y][x].Building
-					if b != nil && b.Final && b.Type == Residential && b.Residents > 0 {
-						b.Residents--
-						removed++
-					}
-				}
+// powered reports whether the city has electricity at all: any power plant feeds the whole grid,
+// though buildings far from every plant may be browned out (see updatePower).
+func (game *GameState) powered() bool {
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			if t.Structure != nil && t.Structure.Type == "power_plant" {
+				return true
 			}
 		}
-	} else if ratio < 0.05 { // very low unemployment, ease back res demand
-		game.Demand.Residential -= 1
 	}
+	return false
 }
-func adjustDemand(d *Demand) {
-	list := []*int{&d.Residential, &d.Commercial, &d.Industrial}
-	for _, v := range list {
-		*v += rand.Intn(5) - 2
-		if *v < -50 {
-			*v = -50
-		} else if *v > 120 {
-			*v = 120
+
+// sendHistory sends the requesting client the recent HistorySamples, oldest first.
+func (c *Client) sendHistory() {
+	c.room.mu.RLock()
+	samples := c.room.game.history.list()
+	c.room.mu.RUnlock()
+	c.sendEvent(EventHistory, struct {
+		Samples []HistorySample `json:"samples"`
+	}{samples})
+}
+
+func (c *Client) sendFullState() {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	game := c.room.game
+	payload, _ := json.Marshal(game)
+	env := Envelope{Type: EventFullState, Payload: payload}
+	b, _ := json.Marshal(env)
+	if c.binary {
+		if mp, err := msgpackFromJSON(b); err == nil {
+			b = mp
 		}
 	}
+	c.lastTick.Store(game.Tick)
+	c.sendDirect(b)
 }
-func simulateCitizens() {
-	// Population = sum of residents in residential buildings
-	pop := 0
+
+// sendStateDiff sends the requesting client every tile changed since the given tick (or its last
+// synced tick), plus the small global fields, so a reconnecting client can catch up cheaply.
+func (c *Client) sendStateDiff(since *int64) {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	game := c.room.game
+	from := c.lastTick.Load()
+	if since != nil {
+		from = *since
+	}
+	diff := StateDiffEvent{Tick: game.Tick, Since: from, Tiles: game.changedTilesSince(from), Demand: game.Demand, Players: game.Players}
+	c.lastTick.Store(game.Tick)
+	c.sendEvent(EventStateDiff, diff)
+}
+
+// changedTilesSince lists tiles changed at or after tick. Inclusive, since actions applied during a
+// tick may land after a snapshot taken in that same tick.
+func (game *GameState) changedTilesSince(tick int64) []*Tile {
+	out := []*Tile{}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			if t := game.Tiles[y][x]; t.ChangedTick >= tick {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// markTile records that a tile's zone/road/structure/building changed this tick.
+func (game *GameState) markTile(t *Tile) {
+	t.ChangedTick = game.Tick
+	game.ownerDirty = append(game.ownerDirty, [2]int{t.X, t.Y})
+	game.index = nil
+}
+
+// tileIndex lists tiles by what they hold, each list in row-major scan order, so tick and frame
+// code walks only the tiles it needs instead of the whole grid. Callers still check the tile.
+type tileIndex struct {
+	roads       []*Tile
+	lots        []*Tile // zoned tiles and tiles with a building
+	buildings   []*Tile // tiles with a building of any type
+	residential []*Tile
+	commercial  []*Tile
+	industrial  []*Tile
+}
+
+// tiles returns the tile index, scanning the grid only when a layer changed since the last call.
+// markTile drops it; code that starts or removes buildings without markTile drops it directly.
+func (game *GameState) tiles() *tileIndex {
+	if game.index != nil && !game.fullScan {
+		return game.index
+	}
+	game.indexScans++
+	idx := &tileIndex{}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if t.Road != nil {
+				idx.roads = append(idx.roads, t)
+			}
+			if t.Zone != nil || t.Building != nil {
+				idx.lots = append(idx.lots, t)
+			}
+			b := t.Building
+			if b == nil {
+				continue
+			}
+			idx.buildings = append(idx.buildings, t)
+			switch b.Type {
+			case Residential:
+				idx.residential = append(idx.residential, t)
+			case Commercial:
+				idx.commercial = append(idx.commercial, t)
+			case Industrial:
+				idx.industrial = append(idx.industrial, t)
+			}
+		}
+	}
+	game.index = idx
+	return idx
+}
+
+// stateHandler serves GET /state?room=CODE: the room's GameState as JSON. ?players=false omits the player map.
+func stateHandler(w http.ResponseWriter, r *http.Request) {
+	room := httpRoom(w, r)
+	if room == nil {
+		return
+	}
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	if r.URL.Query().Get("players") == "false" {
+		writeJSON(w, struct {
+			*GameState
+			Players map[PlayerID]*Player `json:"players,omitempty"`
+		}{GameState: room.game})
+		return
+	}
+	writeJSON(w, room.game)
+}
+
+// summaryHandler serves GET /summary?room=CODE: the same TickSummary broadcast each tick.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	room := httpRoom(w, r)
+	if room == nil {
+		return
+	}
+	room.mu.RLock()
+	summary := room.game.gameSummary()
+	room.mu.RUnlock()
+	writeJSON(w, summary)
+}
+
+// historyHandler serves GET /history?room=CODE: the recent HistorySamples, oldest first.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	room := httpRoom(w, r)
+	if room == nil {
+		return
+	}
+	room.mu.RLock()
+	samples := room.game.history.list()
+	room.mu.RUnlock()
+	writeJSON(w, samples)
+}
+
+// httpRoom resolves the ?room= query (default room if empty) for read-only GET endpoints,
+// writing the error response and returning nil if the method or room is invalid.
+func httpRoom(w http.ResponseWriter, r *http.Request) *Room {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	code := r.URL.Query().Get("room")
+	if code == "" {
+		code = defaultRoomCode
+	}
+	room := findRoom(code)
+	if room == nil {
+		http.Error(w, "room not found", http.StatusNotFound)
+	}
+	return room
+}
+
+// simMetrics holds a room's values exported on /metrics. The game and traffic loops refresh them
+// while holding the room lock, so scrapes never touch game state.
+type simMetrics struct {
+	population        atomic.Int64
+	employed          atomic.Int64
+	demandResidential atomic.Int64
+	demandCommercial  atomic.Int64
+	demandIndustrial  atomic.Int64
+	vehicles          atomic.Int64
+	citizenGroups     atomic.Int64
+	goods             atomic.Int64
+	players           atomic.Int64
+	ticks             atomic.Int64
+}
+
+// recordTickMetrics refreshes the per-tick gauges; called at the end of stepGame.
+func (r *Room) recordTickMetrics() {
+	game, metrics := r.game, &r.metrics
+	metrics.population.Store(int64(game.Population))
+	metrics.employed.Store(int64(game.Employed))
+	metrics.demandResidential.Store(int64(game.Demand.Residential))
+	metrics.demandCommercial.Store(int64(game.Demand.Commercial))
+	metrics.demandIndustrial.Store(int64(game.Demand.Industrial))
+	metrics.players.Store(int64(len(game.Players)))
+	metrics.ticks.Add(1)
+}
+
+// recordTrafficMetrics refreshes the moving-entity gauges; called from trafficLoop.
+func (r *Room) recordTrafficMetrics() {
+	game, metrics := r.game, &r.metrics
+	metrics.vehicles.Store(int64(len(game.Vehicles)))
+	metrics.citizenGroups.Store(int64(len(game.CitizenGroups)))
+	metrics.goods.Store(int64(len(game.GoodsIC) + len(game.GoodsCC)))
+}
+
+// metricsHandler serves /metrics in the Prometheus text exposition format, one series per room.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	roomsMu.Lock()
+	list := make([]*Room, 0, len(rooms))
+	for _, rm := range rooms {
+		list = append(list, rm)
+	}
+	roomsMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	write := func(name, kind, help string, value func(m *simMetrics) int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, rm := range list {
+			fmt.Fprintf(w, "%s{room=%q} %d\n", name, rm.Code, value(&rm.metrics))
+		}
+	}
+	write("citysim_population", "gauge", "Residents housed in the city.", func(m *simMetrics) int64 { return m.population.Load() })
+	write("citysim_employed", "gauge", "Residents holding a job.", func(m *simMetrics) int64 { return m.employed.Load() })
+	write("citysim_demand_residential", "gauge", "Residential demand.", func(m *simMetrics) int64 { return m.demandResidential.Load() })
+	write("citysim_demand_commercial", "gauge", "Commercial demand.", func(m *simMetrics) int64 { return m.demandCommercial.Load() })
+	write("citysim_demand_industrial", "gauge", "Industrial demand.", func(m *simMetrics) int64 { return m.demandIndustrial.Load() })
+	write("citysim_vehicles", "gauge", "Vehicles on the road network.", func(m *simMetrics) int64 { return m.vehicles.Load() })
+	write("citysim_citizen_groups", "gauge", "Citizen groups commuting or at work.", func(m *simMetrics) int64 { return m.citizenGroups.Load() })
+	write("citysim_goods_total", "gauge", "Goods shipments in transit.", func(m *simMetrics) int64 { return m.goods.Load() })
+	write("citysim_players", "gauge", "Players in the game, including bots.", func(m *simMetrics) int64 { return m.players.Load() })
+	write("citysim_ticks_total", "counter", "Simulation ticks processed.", func(m *simMetrics) int64 { return m.ticks.Load() })
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("write json:", err)
+	}
+}
+
+// Room action handlers return "" on success or a Reason code saying why nothing happened.
+
+func (r *Room) placeZone(pid PlayerID, p PlaceZonePayload) string {
+	if !validZoneType(p.Zone) {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
+	}
+	t := game.Tiles[p.Y][p.X]
+	if t.Zone != nil || t.Road != nil || t.Rail != nil || t.Structure != nil {
+		return ReasonOccupied
+	}
+	if reason := game.zoningConflict(p.X, p.Y, p.Zone); reason != "" {
+		return reason
+	}
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	cost, ok := game.placementCost(zoneCostOf(p.Zone), p.X, p.Y)
+	if !ok {
+		return ReasonTooSteep
+	}
+	if pl.Money < cost {
+		return ReasonInsufficientFunds
+	}
+	before := game.layersAt(p.X, p.Y)
+	pl.Money -= cost
+	// Clear foliage when zoning for development
+	if p.Zone != Reserve {
+		t.Foliage = ""
+	}
+	t.Zone = &Zone{Type: p.Zone, Owner: pid, PlacedAt: game.unixNow()}
+	game.markTile(t)
+	game.logLayers(p.X, p.Y, before, ActionPlaceZone, pid)
+	r.pushUndo(pid, undoEntry{Spent: cost, Tiles: []undoTile{{p.X, p.Y, before, game.layersAt(p.X, p.Y)}}})
+	game.announce(EventZonePlaced, ZonePlacedEvent{X: p.X, Y: p.Y, Zone: t.Zone})
+	return ""
+}
+
+// placeZoneRect zones every free, dry tile in the rectangle (corners inclusive, in any order),
+// row by row, charging per tile until the player runs out of money. One event lists all zoned tiles.
+// It fails only when no tile could be zoned.
+func (r *Room) placeZoneRect(pid PlayerID, p PlaceZoneRectPayload) string {
+	if !validZoneType(p.Zone) {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	x0, x1 := min(p.X0, p.X1), max(p.X0, p.X1)
+	y0, y1 := min(p.Y0, p.Y1), max(p.Y0, p.Y1)
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 = min(x1, game.Width-1), min(y1, game.Height-1)
+	placed := []ZonePlacedEvent{}
+	entry := undoEntry{}
+	now := game.unixNow()
+	base := zoneCostOf(p.Zone)
+	for y := y0; y <= y1 && pl.Money >= base; y++ {
+		for x := x0; x <= x1 && pl.Money >= base; x++ {
+			t := game.Tiles[y][x]
+			if t.Zone != nil || t.Road != nil || t.Rail != nil || t.Structure != nil || t.Building != nil || t.Terrain == "water" || game.zoningConflict(x, y, p.Zone) != "" {
+				continue
+			}
+			cost, ok := game.placementCost(base, x, y)
+			if !ok || pl.Money < cost {
+				continue
+			}
+			before := game.layersAt(x, y)
+			pl.Money -= cost
+			if p.Zone != Reserve {
+				t.Foliage = ""
+			}
+			t.Zone = &Zone{Type: p.Zone, Owner: pid, PlacedAt: now}
+			game.markTile(t)
+			game.logLayers(x, y, before, ActionPlaceZoneRect, pid)
+			placed = append(placed, ZonePlacedEvent{X: x, Y: y, Zone: t.Zone})
+			entry.Tiles = append(entry.Tiles, undoTile{x, y, before, game.layersAt(x, y)})
+			entry.Spent += cost
+		}
+	}
+	r.pushUndo(pid, entry)
+	switch {
+	case len(placed) > 0:
+		game.announce(EventZonesPlaced, ZonesPlacedEvent{Zones: placed})
+	case pl.Money < base:
+		return ReasonInsufficientFunds
+	default:
+		return ReasonOccupied
+	}
+	return ""
+}
+
+func (r *Room) placeRoad(pid PlayerID, p PlaceRoadPayload) string {
+	if _, ok := roadDirectionVectors[p.Direction]; !ok && p.Direction != DirNone {
+		return ReasonInvalidType
+	}
+	if _, ok := roadCosts[p.Kind]; !ok {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
+	}
+	before := game.layersAt(p.X, p.Y)
+	cost, _ := game.placementCost(roadCosts[p.Kind], p.X, p.Y)
+	if reason := game.placeRoadTile(pl, p.X, p.Y, p.Direction, p.Kind); reason != "" {
+		return reason
+	}
+	game.logLayers(p.X, p.Y, before, ActionPlaceRoad, pid)
+	r.pushUndo(pid, undoEntry{Spent: cost, Tiles: []undoTile{{p.X, p.Y, before, game.layersAt(p.X, p.Y)}}})
+	return ""
+}
+
+// buildRoadPath fails with no_route when the endpoints are not connected over buildable land, and
+// with the reason the route stopped early (e.g. insufficient_funds) after announcing what was built.
+func (r *Room) buildRoadPath(pid PlayerID, p BuildRoadPathPayload) string {
+	if _, ok := roadCosts[p.Kind]; !ok {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	route := game.roadRoute(p.X0, p.Y0, p.X1, p.Y1)
+	if route == nil {
+		return ReasonNoRoute
+	}
+	reason := ""
+	ev := RoadsPlacedEvent{Roads: []RoadPlacedEvent{}, Complete: route != nil}
+	entry := undoEntry{}
+	for i, c := range route {
+		t := game.Tiles[c[1]][c[0]]
+		if t.Road != nil { // existing road is reused for free
+			continue
+		}
+		before := game.layersAt(c[0], c[1])
+		cost, _ := game.placementCost(roadCosts[p.Kind], c[0], c[1])
+		if reason = game.buildRoadTile(pl, c[0], c[1], DirNone, p.Kind); reason != "" {
+			ev.Complete = false
+			if i > 0 {
+				ev.StoppedAt = &route[i-1]
+			}
+			break
+		}
+		game.logLayers(c[0], c[1], before, ActionBuildRoadPath, pid)
+		ev.Roads = append(ev.Roads, RoadPlacedEvent{X: c[0], Y: c[1], Road: t.Road})
+		entry.Tiles = append(entry.Tiles, undoTile{c[0], c[1], before, game.layersAt(c[0], c[1])})
+		entry.Spent += cost
+	}
+	r.pushUndo(pid, entry)
+	game.announce(EventRoadsPlaced, ev)
+	return reason
+}
+
+const maxNameLen = 24
+
+// sanitizeName trims a display name and checks it is 1..maxNameLen runes of letters, digits,
+// spaces or - _ . ' characters.
+func sanitizeName(raw string) (string, bool) {
+	name := strings.TrimSpace(raw)
+	n := utf8.RuneCountInString(name)
+	if n == 0 || n > maxNameLen {
+		return "", false
+	}
+	for _, ch := range name {
+		if !(unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == ' ' || strings.ContainsRune("-_.'", ch)) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// validColor accepts "#rrggbb" hex colors.
+func validColor(c string) bool {
+	if len(c) != 7 || c[0] != '#' {
+		return false
+	}
+	for _, ch := range c[1:] {
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Room) setName(pid PlayerID, p SetNamePayload) string {
+	name, ok := sanitizeName(p.Name)
+	if !ok {
+		return ReasonInvalidName
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pl := r.game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	pl.Name = name
+	r.game.announce(EventPlayerUpdate, pl.info())
+	return ""
+}
+
+func (r *Room) setColor(pid PlayerID, p SetColorPayload) string {
+	if !validColor(p.Color) {
+		return ReasonInvalidColor
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pl := r.game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	pl.Color = strings.ToLower(p.Color)
+	r.game.announce(EventPlayerUpdate, pl.info())
+	return ""
+}
+
+// ================= Loans =================
+const (
+	loanBaseAllowance = 10000 // credit every player has before counting assets
+	loanAssetShare    = 2     // a player may borrow up to 1/loanAssetShare of their asset value on top
+	loanInterestRate  = 0.002 // per tick
+)
+
+// playerAssets values what pid has built at its placement cost.
+func (game *GameState) playerAssets(pid PlayerID) int {
+	total := 0
 	for y := 0; y < game.Height; y++ {
 		for x := 0; x < game.Width; x++ {
-			if b := game.Tiles[y][x].Building; b != nil && b.Final && b.Type == Residential {
-				pop += b.Residents
+			total += game.ownedCost(game.Tiles[y][x], pid)
+		}
+	}
+	return total
+}
+
+func (game *GameState) loanLimit(pid PlayerID) int {
+	return loanBaseAllowance + game.playerAssets(pid)/loanAssetShare
+}
+
+// takeLoan lends the player Amount if their total debt stays within loanLimit. Players already in
+// the red (see economicTick) cannot borrow more.
+func (r *Room) takeLoan(pid PlayerID, p LoanPayload) string {
+	if p.Amount <= 0 {
+		return ReasonBadPayload
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	if pl.Money < 0 || pl.Debt+p.Amount > game.loanLimit(pid) {
+		return ReasonCreditLimit
+	}
+	pl.Money += p.Amount
+	pl.Debt += p.Amount
+	pl.InterestRate = loanInterestRate
+	game.announceLoan(pl)
+	return ""
+}
+
+func (r *Room) repayLoan(pid PlayerID, p LoanPayload) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	if pl.Debt == 0 {
+		return ReasonNoDebt
+	}
+	amount := min(pl.Debt, max(pl.Money, 0))
+	if p.Amount > 0 {
+		amount = min(amount, p.Amount)
+	}
+	if amount == 0 {
+		return ReasonInsufficientFunds
+	}
+	pl.Money -= amount
+	pl.Debt -= amount
+	if pl.Debt == 0 {
+		pl.InterestRate = 0
+	}
+	game.announceLoan(pl)
+	return ""
+}
+
+func (game *GameState) announceLoan(pl *Player) {
+	game.announce(EventLoanUpdate, struct {
+		ID           PlayerID `json:"id"`
+		Money        int      `json:"money"`
+		Debt         int      `json:"debt"`
+		InterestRate float64  `json:"interestRate"`
+	}{pl.ID, pl.Money, pl.Debt, pl.InterestRate})
+}
+
+// structureSpec describes a placeable structure kind and its effect on the surrounding tiles.
+type structureSpec struct {
+	Cost           int
+	Radius         int  // manhattan radius of the effects below
+	LandValueBonus int  // bonus at the structure tile, fading linearly to the edge of Radius
+	PollutionCut   int  // pollution absorbed at the structure tile, fading the same way
+	FireCover      bool // tiles within Radius count as covered by fire protection
+	Water          bool // supplies water up to Radius steps along developed tiles
+	Education      bool // residential buildings within Radius gain education
+	CrimeCut       int  // crime suppressed at the structure tile, fading like LandValueBonus
+	Pollution      int  // pollution emitted at the structure tile, fading the same way
+	Capacity       int  // power units supplied
+	Upkeep         int  // charged to the owner every tick
+	Size           int  // footprint side in tiles; 0 means 1
+}
+
+// structureSpecs is the explicit set of structure kinds players may place; any other kind is rejected.
+var structureSpecs = map[string]structureSpec{
+	"power_plant":    {Cost: 5000, Upkeep: 10}, // priced by plantSpecs
+	"park":           {Cost: 800, Radius: 4, LandValueBonus: 12, PollutionCut: 10, Upkeep: 2},
+	"plaza":          {Cost: 1500, Radius: 3, LandValueBonus: 16, Upkeep: 3},
+	"fire_station":   {Cost: 2500, Radius: 6, FireCover: true, Upkeep: 8},
+	"water_tower":    {Cost: 2000, Radius: 8, Water: true, Upkeep: 5},
+	"school":         {Cost: 3000, Radius: 6, Education: true, Upkeep: 8},
+	"police_station": {Cost: 2500, Radius: 8, CrimeCut: 40, Upkeep: 8},
+	"bus_stop":       {Cost: 300, Upkeep: 1},
+	"stadium":        {Cost: 12000, Radius: 6, LandValueBonus: 10, Upkeep: 20, Size: 2},
+	"airport":        {Cost: 30000, Radius: 4, Pollution: 12, Upkeep: 40, Size: 3},
+}
+
+// structureMember is the Type of the non-root tiles of a multi-tile structure. Members have no
+// spec of their own, so every effect, cost and upkeep comes from the root alone.
+const structureMember = "member"
+
+// structureFootprint returns the tiles of the structure on (x,y), root first: a multi-tile
+// structure of Size n covers n x n tiles from its root at the top-left, any other tile just itself.
+func (game *GameState) structureFootprint(x, y int) [][2]int {
+	s := game.Tiles[y][x].Structure
+	if s != nil && s.Root != nil {
+		x, y = s.Root[0], s.Root[1]
+		s = game.Tiles[y][x].Structure
+	}
+	if s == nil || s.Size <= 1 {
+		return [][2]int{{x, y}}
+	}
+	tiles := make([][2]int, 0, s.Size*s.Size)
+	tiles = append(tiles, [2]int{x, y})
+	for fy := y; fy < y+s.Size; fy++ {
+		for fx := x; fx < x+s.Size; fx++ {
+			if (fx != x || fy != y) && game.inBounds(fx, fy) {
+				tiles = append(tiles, [2]int{fx, fy})
+			}
+		}
+	}
+	return tiles
+}
+
+// defaultPlant is the power plant type built when place_structure names none.
+const defaultPlant = "coal"
+
+// plantSpecs replaces the power_plant structureSpec for each plant type: coal pollutes its
+// surroundings, solar is clean but small, nuclear is clean and large but expensive to build and run.
+var plantSpecs = map[string]structureSpec{
+	"coal":    {Cost: 5000, Radius: 4, Pollution: 24, Capacity: 500, Upkeep: 10},
+	"solar":   {Cost: 3000, Capacity: 150, Upkeep: 4},
+	"nuclear": {Cost: 20000, Capacity: 2500, Upkeep: 40},
+}
+
+// spec is the structureSpec that applies to s, taking a power plant's type into account.
+func (s *Structure) spec() structureSpec {
+	if s.Type == "power_plant" {
+		if spec, ok := plantSpecs[s.Plant]; ok {
+			return spec
+		}
+	}
+	return structureSpecs[s.Type]
+}
+
+func (r *Room) placeStructure(pid PlayerID, p PlaceStructurePayload) string {
+	spec, ok := structureSpecs[p.Kind]
+	if !ok {
+		return ReasonInvalidType
+	}
+	if p.Kind == "power_plant" {
+		if p.Plant == "" {
+			p.Plant = defaultPlant
+		}
+		if spec, ok = plantSpecs[p.Plant]; !ok {
+			return ReasonInvalidType
+		}
+	} else if p.Plant != "" {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	// (p.X,p.Y) is the root; a larger structure also covers the Size x Size tiles to its south-east,
+	// all of which must be on the map, empty and buildable
+	size := max(spec.Size, 1)
+	var footprint [][2]int
+	for y := p.Y; y < p.Y+size; y++ {
+		for x := p.X; x < p.X+size; x++ {
+			footprint = append(footprint, [2]int{x, y})
+		}
+	}
+	cost := 0
+	for i, c := range footprint {
+		if !game.inBounds(c[0], c[1]) {
+			return ReasonOutOfBounds
+		}
+		t := game.Tiles[c[1]][c[0]]
+		if t.Structure != nil || t.Zone != nil || t.Road != nil || t.Rail != nil || t.Building != nil {
+			return ReasonOccupied
+		}
+		if size > 1 && t.Terrain == "water" {
+			return ReasonUnbuildable
+		}
+		base := 0
+		if i == 0 {
+			base = spec.Cost
+		}
+		tileCost, ok := game.placementCost(base, c[0], c[1])
+		if !ok {
+			return ReasonTooSteep
+		}
+		cost += tileCost
+	}
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	if pl.Money < cost {
+		return ReasonInsufficientFunds
+	}
+	pl.Money -= cost
+	now := game.unixNow()
+	root := &Structure{Type: p.Kind, Plant: p.Plant, Capacity: spec.Capacity, Owner: pid, PlacedAt: now}
+	if size > 1 {
+		root.Size = size
+	}
+	undo := undoEntry{Spent: cost}
+	for i, c := range footprint {
+		t := game.Tiles[c[1]][c[0]]
+		before := game.layersAt(c[0], c[1])
+		t.Structure = root
+		if i > 0 {
+			t.Structure = &Structure{Type: structureMember, Owner: pid, PlacedAt: now, Root: &[2]int{p.X, p.Y}}
+		}
+		game.markTile(t)
+		game.logLayers(c[0], c[1], before, ActionPlaceStructure, pid)
+		undo.Tiles = append(undo.Tiles, undoTile{c[0], c[1], before, game.layersAt(c[0], c[1])})
+	}
+	r.pushUndo(pid, undo)
+	ev := struct {
+		X         int        `json:"x"`
+		Y         int        `json:"y"`
+		Structure *Structure `json:"structure"`
+		Footprint [][2]int   `json:"footprint,omitempty"` // every tile covered, root first, for multi-tile structures
+	}{X: p.X, Y: p.Y, Structure: root}
+	if size > 1 {
+		ev.Footprint = footprint
+	}
+	game.announce(EventStructurePlaced, ev)
+	return ""
+}
+
+func (r *Room) bulldoze(pid PlayerID, p BulldozePayload) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
+	}
+	k := [2]int{p.X, p.Y}
+	if at, ok := game.bulldozedAt[k]; ok && game.Tick-at < bulldozeCooldownTicks {
+		return ReasonCooldown
+	}
+	t := game.Tiles[p.Y][p.X]
+	before := game.layersAt(p.X, p.Y)
+	refund := game.ownedCost(t, pid) * bulldozeRefundPct / 100
+	// demolishing any tile of a multi-tile structure takes the rest of its footprint with it
+	var others []undoTile
+	var tiles [][2]int
+	if t.Structure != nil {
+		if fp := game.structureFootprint(p.X, p.Y); len(fp) > 1 {
+			for _, c := range fp {
+				if c == k {
+					continue
+				}
+				m := game.Tiles[c[1]][c[0]]
+				refund += game.ownedCost(m, pid) * bulldozeRefundPct / 100
+				others = append(others, undoTile{c[0], c[1], game.layersAt(c[0], c[1]), tileLayers{}})
+				m.Structure = nil
+				game.markTile(m)
+				game.logTile(c[0], c[1], "structure", "removed", ActionBulldoze, pid)
+			}
+			tiles = fp
+		}
+	}
+	if pl := game.Players[pid]; pl != nil {
+		pl.Money += refund
+		game.budget(pid).Refunds += refund
+	} else {
+		refund = 0
+	}
+	t.Zone = nil
+	t.Building = nil
+	hadRoad := t.Road != nil
+	t.Road = nil
+	t.Rail = nil
+	t.Structure = nil
+	game.markTile(t)
+	game.logLayers(p.X, p.Y, before, ActionBulldoze, pid)
+	if hadRoad {
+		game.roadsChanged()
+		game.rerouteAround(p.X, p.Y)
+	}
+	if before != game.layersAt(p.X, p.Y) {
+		undo := undoEntry{Spent: -refund, Tiles: []undoTile{{p.X, p.Y, before, game.layersAt(p.X, p.Y)}}}
+		if game.bulldozedAt == nil {
+			game.bulldozedAt = map[[2]int]int64{}
+		}
+		game.bulldozedAt[k] = game.Tick
+		for _, o := range others {
+			o.After = game.layersAt(o.X, o.Y)
+			undo.Tiles = append(undo.Tiles, o)
+			game.bulldozedAt[[2]int{o.X, o.Y}] = game.Tick
+		}
+		r.pushUndo(pid, undo)
+	}
+	game.announce(EventBulldozed, struct {
+		X      int      `json:"x"`
+		Y      int      `json:"y"`
+		By     PlayerID `json:"by"`
+		Refund int      `json:"refund,omitempty"`
+		Tiles  [][2]int `json:"tiles,omitempty"` // the whole footprint when a multi-tile structure was demolished
+	}{p.X, p.Y, pid, refund, tiles})
+	return ""
+}
+
+// ================= Admin =================
+
+// adminToken authorizes the admin_* actions, add_bot, remove_bot, set_zoning_buffer and tile_history;
+// CITYSIM_ADMIN_TOKEN unset disables them.
+var adminToken = os.Getenv("CITYSIM_ADMIN_TOKEN")
+
+// checkAdmin rejects an admin action whose payload lacks the admin token. It runs before the
+// action is journaled, so replays apply admin actions without the check.
+func (c *Client) checkAdmin(raw json.RawMessage) string {
+	var p struct {
+		Token string `json:"token"`
+	}
+	if len(raw) > 0 && json.Unmarshal(raw, &p) != nil {
+		return ReasonBadPayload
+	}
+	if adminToken == "" || subtle.ConstantTimeCompare([]byte(p.Token), []byte(adminToken)) != 1 {
+		return ReasonUnauthorized
+	}
+	return ""
+}
+
+// withoutToken strips the admin token from a checked payload so it never reaches the journal.
+func withoutToken(raw json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &fields) != nil {
+		return raw
+	}
+	delete(fields, "token")
+	out, _ := json.Marshal(fields)
+	return out
+}
+
+// adminClearRect demolishes everything in a rectangle, clipped to the map, whoever owns it. No
+// refunds are paid and nothing is pushed to undo.
+func (r *Room) adminClearRect(p AdminClearRectPayload) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	x0, x1 := max(min(p.X0, p.X1), 0), min(max(p.X0, p.X1), game.Width-1)
+	y0, y1 := max(min(p.Y0, p.Y1), 0), min(max(p.Y0, p.Y1), game.Height-1)
+	if x0 > x1 || y0 > y1 {
+		return ReasonOutOfBounds
+	}
+	tiles := []*Tile{}
+	var roads [][2]int
+	// structures reaching out of the rectangle are cleared whole
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if fp := game.structureFootprint(x, y); len(fp) > 1 {
+				for _, c := range fp {
+					if c[0] < x0 || c[0] > x1 || c[1] < y0 || c[1] > y1 {
+						m := game.Tiles[c[1]][c[0]]
+						m.Structure = nil
+						game.markTile(m)
+						game.logTile(c[0], c[1], "structure", "removed", ActionAdminClearRect, "")
+						tiles = append(tiles, m)
+					}
+				}
+			}
+		}
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			t := game.Tiles[y][x]
+			if t.Zone == nil && t.Building == nil && t.Road == nil && t.Rail == nil && t.Structure == nil {
+				continue
+			}
+			if t.Road != nil {
+				roads = append(roads, [2]int{x, y})
+			}
+			before := game.layersAt(x, y)
+			t.Zone, t.Building, t.Road, t.Rail, t.Structure = nil, nil, nil, nil, nil
+			game.markTile(t)
+			game.logLayers(x, y, before, ActionAdminClearRect, "")
+			tiles = append(tiles, t)
+		}
+	}
+	if len(roads) > 0 {
+		game.roadsChanged()
+		for _, c := range roads {
+			game.rerouteAround(c[0], c[1])
+		}
+	}
+	game.announce(EventAreaCleared, struct {
+		X0    int     `json:"x0"`
+		Y0    int     `json:"y0"`
+		X1    int     `json:"x1"`
+		Y1    int     `json:"y1"`
+		Tiles []*Tile `json:"tiles"`
+	}{x0, y0, x1, y1, tiles})
+	return ""
+}
+
+// adminReset replaces the room's game with a freshly generated one and sends every client the new
+// full state. Players and bots stay, back at their starting balances; settings and objectives
+// carry over, and the tick count continues so clients' later syncs still line up.
+func (r *Room) adminReset(p AdminResetPayload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.game
+	seed := old.Seed
+	if p.Seed != nil {
+		seed = *p.Seed
+	}
+	game := newGame(seed)
+	game.Tick = old.Tick
+	game.Speed, game.ZoningBuffer, game.Config, game.EndOnGoals = old.Speed, old.ZoningBuffer, old.Config, old.EndOnGoals
+	for _, o := range old.Objectives {
+		game.Objectives = append(game.Objectives, &Objective{Metric: o.Metric, Target: o.Target})
+	}
+	for id, pl := range old.Players {
+		money := startMoney
+		if pl.Bot {
+			money = botMoney
+		}
+		game.Players[id] = &Player{ID: id, Name: pl.Name, Color: pl.Color, Money: money, Connected: pl.Connected, Bot: pl.Bot, Strategy: pl.Strategy}
+	}
+	game.BotIDs = slices.Clone(old.BotIDs)
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			t.ChangedTick = game.Tick // so a client that misses the full state catches up by state_diff
+		}
+	}
+	game.hub = r.hub
+	r.game = game
+	r.undo = map[PlayerID][]undoEntry{}
+	r.spawnAcc, r.citizenSpawnAcc, r.goodsSpawnAcc = 0, 0, 0
+	payload, _ := json.Marshal(game)
+	b, _ := json.Marshal(Envelope{Type: EventFullState, Payload: payload})
+	msg := broadcastMessage{text: b} // no event type: every client needs the new map whatever it subscribed to
+	if r.hub.binary.Load() > 0 {
+		msg.binary, _ = msgpackFromJSON(b)
+	}
+	r.hub.publish(msg)
+}
+
+// ================= Tile history =================
+
+// tileHistoryLen is how many changes each tile's log keeps; CITYSIM_TILE_HISTORY unset or 0 keeps none.
+var tileHistoryLen = int(envFloat("CITYSIM_TILE_HISTORY", 0))
+
+// TileEvent is one change to a tile's zone, road, rail, structure or building.
+type TileEvent struct {
+	Tick   int64    `json:"tick"`
+	Layer  string   `json:"layer"`  // zone, road, rail, structure or building
+	Change string   `json:"change"` // placed, replaced, removed; buildings also started, completed, abandoning
+	Reason string   `json:"reason"` // the action, "bot", or the simulation cause (construction, an abandon reason, abandoned, fire)
+	Player PlayerID `json:"player,omitempty"`
+}
+
+// tileEvents is a ring buffer of a tile's last tileHistoryLen events.
+type tileEvents struct {
+	events []TileEvent
+	next   int
+}
+
+// list returns the buffered events, oldest first.
+func (l *tileEvents) list() []TileEvent {
+	return append(slices.Clone(l.events[l.next:]), l.events[:l.next]...)
+}
+
+// logTile records a change to the tile at (x, y) when tile history is on.
+func (game *GameState) logTile(x, y int, layer, change, reason string, pid PlayerID) {
+	if tileHistoryLen <= 0 {
+		return
+	}
+	if game.tileLog == nil {
+		game.tileLog = map[[2]int]*tileEvents{}
+	}
+	k := [2]int{x, y}
+	l := game.tileLog[k]
+	if l == nil {
+		l = &tileEvents{}
+		game.tileLog[k] = l
+	}
+	e := TileEvent{Tick: game.Tick, Layer: layer, Change: change, Reason: reason, Player: pid}
+	if len(l.events) < tileHistoryLen {
+		l.events = append(l.events, e)
+		return
+	}
+	l.events[l.next] = e
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// logLayers records every layer of the tile at (x, y) that differs from before.
+func (game *GameState) logLayers(x, y int, before tileLayers, reason string, pid PlayerID) {
+	if tileHistoryLen <= 0 {
+		return
+	}
+	after := game.layersAt(x, y)
+	note := func(layer string, had, has, same bool) {
+		switch {
+		case !had && has:
+			game.logTile(x, y, layer, "placed", reason, pid)
+		case had && !has:
+			game.logTile(x, y, layer, "removed", reason, pid)
+		case had && !same:
+			game.logTile(x, y, layer, "replaced", reason, pid)
+		}
+	}
+	note("zone", before.Zone != nil, after.Zone != nil, before.Zone == after.Zone)
+	note("road", before.Road != nil, after.Road != nil, before.Road == after.Road)
+	note("rail", before.Rail != nil, after.Rail != nil, before.Rail == after.Rail)
+	note("structure", before.Structure != nil, after.Structure != nil, before.Structure == after.Structure)
+	note("building", before.Building != nil, after.Building != nil, before.Building == after.Building)
+}
+
+// sendTileHistory sends the requesting client the logged changes to one tile, oldest first.
+func (c *Client) sendTileHistory(p TileHistoryPayload) string {
+	c.room.mu.RLock()
+	defer c.room.mu.RUnlock()
+	game := c.room.game
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
+	}
+	events := []TileEvent{}
+	if l := game.tileLog[[2]int{p.X, p.Y}]; l != nil {
+		events = l.list()
+	}
+	c.sendEvent(EventTileHistory, struct {
+		X      int         `json:"x"`
+		Y      int         `json:"y"`
+		Events []TileEvent `json:"events"`
+	}{p.X, p.Y, events})
+	return ""
+}
+
+// ================= Undo =================
+
+// undoDepth is how many recent actions each player can undo.
+const undoDepth = 10
+
+// tileLayers is the player-editable content of a tile; comparable, so a changed tile is detected with !=.
+type tileLayers struct {
+	Foliage   string
+	Zone      *Zone
+	Road      *Road
+	Rail      *Rail
+	Structure *Structure
+	Building  *Building
+}
+
+type undoTile struct {
+	X, Y          int
+	Before, After tileLayers
+}
+
+// undoEntry is one reversible action: the tiles it changed and the money it cost (negative for a refund).
+type undoEntry struct {
+	Tiles []undoTile
+	Spent int
+}
+
+func (game *GameState) layersAt(x, y int) tileLayers {
+	t := game.Tiles[y][x]
+	return tileLayers{t.Foliage, t.Zone, t.Road, t.Rail, t.Structure, t.Building}
+}
+
+// placedLayers is the part of tileLayers only players place and remove. Foliage regrows and buildings
+// grow and decay by themselves, so changes there don't stop an undo.
+type placedLayers struct {
+	Zone      *Zone
+	Road      *Road
+	Rail      *Rail
+	Structure *Structure
+}
+
+func (l tileLayers) placed() placedLayers {
+	return placedLayers{l.Zone, l.Road, l.Rail, l.Structure}
+}
+
+// pushUndo records an action for pid, dropping the oldest beyond undoDepth. Callers hold r.mu.
+func (r *Room) pushUndo(pid PlayerID, e undoEntry) {
+	if len(e.Tiles) == 0 {
+		return
+	}
+	h := append(r.undo[pid], e)
+	if len(h) > undoDepth {
+		h = h[len(h)-undoDepth:]
+	}
+	r.undo[pid] = h
+}
+
+// undoLast reverts pid's most recent action, restoring the prior tiles and returning what it cost
+// (or taking back what it refunded). If a placed layer of any tile has changed since, the entry can
+// never apply and is discarded without effect; if the player cannot repay a refund it is kept for
+// later. Foliage and buildings are restored too, whatever became of them in the meantime.
+func (r *Room) undoLast(pid PlayerID) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
+	}
+	h := r.undo[pid]
+	if len(h) == 0 {
+		return ReasonNothingToUndo
+	}
+	e := h[len(h)-1]
+	for _, ut := range e.Tiles {
+		if game.layersAt(ut.X, ut.Y).placed() != ut.After.placed() {
+			r.undo[pid] = h[:len(h)-1]
+			return ReasonTileChanged
+		}
+	}
+	if pl.Money+e.Spent < 0 {
+		return ReasonInsufficientFunds
+	}
+	r.undo[pid] = h[:len(h)-1]
+	pl.Money += e.Spent
+	if e.Spent < 0 { // taking back a bulldoze refund
+		game.budget(pid).Refunds += e.Spent
+	}
+	tiles := make([]*Tile, 0, len(e.Tiles))
+	for _, ut := range e.Tiles {
+		t := game.Tiles[ut.Y][ut.X]
+		b, now := ut.Before, game.layersAt(ut.X, ut.Y)
+		hadRoad := t.Road != nil
+		t.Foliage, t.Zone, t.Road, t.Rail, t.Structure, t.Building = b.Foliage, b.Zone, b.Road, b.Rail, b.Structure, b.Building
+		if hadRoad != (t.Road != nil) {
+			game.roadsChanged()
+		}
+		game.markTile(t)
+		game.homeChanged(t)
+		game.logLayers(ut.X, ut.Y, now, ActionUndo, pid)
+		if hadRoad && t.Road == nil {
+			game.rerouteAround(t.X, t.Y)
+		}
+		tiles = append(tiles, t)
+	}
+	game.announce(EventUndone, struct {
+		Player PlayerID `json:"player"`
+		Tiles  []*Tile  `json:"tiles"`
+	}{pid, tiles})
+	return ""
+}
+
+// ownedCost is the placement cost of each layer of t that pid owns; bulldozing refunds
+// bulldozeRefundPct of it. Grown buildings were never paid for and count nothing.
+func (game *GameState) ownedCost(t *Tile, pid PlayerID) int {
+	base := 0
+	if t.Zone != nil && t.Zone.Owner == pid {
+		base += zoneCostOf(t.Zone.Type)
+	}
+	if t.Road != nil && t.Road.Owner == pid {
+		base += roadCosts[t.Road.Kind]
+	}
+	if t.Rail != nil && t.Rail.Owner == pid {
+		base += railCost
+	}
+	if t.Structure != nil && t.Structure.Owner == pid {
+		base += t.Structure.spec().Cost
+	}
+	cost, _ := game.placementCost(base, t.X, t.Y)
+	return cost
+}
+
+// placementCost is what building something of flat cost base on (x,y) costs, for players and bots
+// alike: elevation and hills add surcharges. ok is false when the tile is too steep to build on.
+func (game *GameState) placementCost(base, x, y int) (cost int, ok bool) {
+	t := game.Tiles[y][x]
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if nx, ny := x+d[0], y+d[1]; game.inBounds(nx, ny) && absInt(game.Tiles[ny][nx].Elevation-t.Elevation) > maxBuildSlope {
+			return 0, false
+		}
+	}
+	pct := 100 + max(t.Elevation, 0)*elevationCostPct
+	if t.Terrain == "hill" {
+		pct += hillCostPct
+	}
+	return base * pct / 100, true
+}
+
+type TickSummary struct {
+	Tick       int64  `json:"tick"`
+	Hour       int    `json:"hour"` // in-game time of day, 0-23
+	Demand     Demand `json:"demand"`
+	Population int    `json:"population"`
+	Employed   int    `json:"employed"`
+	Happiness  int    `json:"happiness"`
+	// Overview counts for dashboards and minimaps
+	Roads         int              `json:"roads"`
+	Zones         map[ZoneType]int `json:"zones"`     // zoned tiles by type
+	Buildings     map[ZoneType]int `json:"buildings"` // finished buildings by type
+	Abandoning    int              `json:"abandoning"`
+	Vehicles      int              `json:"vehicles"`
+	CitizenGroups int              `json:"citizenGroups"`
+	Goods         int              `json:"goods"` // shipments in transit
+	Money         map[PlayerID]int `json:"money"`
+	PowerSupply   int              `json:"powerSupply"` // plant capacity in power units
+	PowerDemand   int              `json:"powerDemand"`
+	// Road health: connected road networks, and the share of finished buildings with no road
+	// path between home and work (homes reaching no job, jobs reaching no home)
+	RoadComponents int     `json:"roadComponents"`
+	Stranded       float64 `json:"stranded"`
+}
+
+type BuildingUpdate struct {
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Building  *Building `json:"building"`
+	Root      [2]int    `json:"root"`             // root tile of the building's footprint, (x,y) itself for single tiles
+	Footprint [][2]int  `json:"footprint"`        // every tile the building covers, root first
+	Decay     float64   `json:"decay,omitempty"`  // abandonment progress, (0,1] while AbandonPhase counts down
+	AtRisk    bool      `json:"atRisk,omitempty"` // idle long enough that abandonment is approaching
+}
+
+// buildingChangeSet collects the coordinates of buildings changed during a tick, de-duplicated and
+// in first-seen order. Producers record coordinates only; snapshot reads the authoritative tile
+// state once at the end of the tick, so later mutations (e.g. an AI bulldoze) are never stale.
+type buildingChangeSet struct {
+	seen  map[[2]int]bool
+	order [][2]int
+}
+
+func newBuildingChangeSet() *buildingChangeSet {
+	return &buildingChangeSet{seen: map[[2]int]bool{}}
+}
+
+func (cs *buildingChangeSet) add(x, y int) {
+	k := [2]int{x, y}
+	if cs.seen[k] {
+		return
+	}
+	cs.seen[k] = true
+	cs.order = append(cs.order, k)
+}
+
+// snapshot builds one update per changed coordinate from the current tile state.
+func (cs *buildingChangeSet) snapshot(game *GameState) []BuildingUpdate {
+	updates := make([]BuildingUpdate, 0, len(cs.order))
+	for _, k := range cs.order {
+		if !game.inBounds(k[0], k[1]) {
+			continue
+		}
+		t := game.Tiles[k[1]][k[0]]
+		game.markTile(t)
+		u := BuildingUpdate{X: k[0], Y: k[1], Building: t.Building}
+		u.Root, u.Footprint = game.footprint(k[0], k[1])
+		if b := t.Building; b != nil {
+			if b.AbandonPhase > 0 {
+				u.Decay = float64(abandonPhaseTicks-b.AbandonPhase+1) / abandonPhaseTicks
+			} else {
+				u.AtRisk = b.IdleTicks > 0 && b.IdleTicks*atRiskDivisor >= game.abandonThreshold(b)
+			}
+		}
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// footprint returns the root tile and every tile of the building at (x,y), root first. A building of
+// Size n > 1 covers n x n tiles with its root, the tile flagged IsRoot, at the top-left; any other
+// tile, built on or not, is its own root and whole footprint.
+func (game *GameState) footprint(x, y int) ([2]int, [][2]int) {
+	single := [2]int{x, y}
+	b := game.Tiles[y][x].Building
+	if b == nil || b.Size <= 1 {
+		return single, [][2]int{single}
+	}
+	for dy := 0; dy < b.Size; dy++ {
+		for dx := 0; dx < b.Size; dx++ {
+			rx, ry := x-dx, y-dy
+			if !game.inBounds(rx, ry) {
+				continue
+			}
+			if r := game.Tiles[ry][rx].Building; r != nil && r.IsRoot && r.Size == b.Size && r.Type == b.Type {
+				tiles := make([][2]int, 0, b.Size*b.Size)
+				for fy := ry; fy < ry+b.Size; fy++ {
+					for fx := rx; fx < rx+b.Size; fx++ {
+						tiles = append(tiles, [2]int{fx, fy})
+					}
+				}
+				return [2]int{rx, ry}, tiles
+			}
+		}
+	}
+	return single, [][2]int{single}
+}
+
+// progressBuildings advances simple construction stages for zones without final buildings.
+func (game *GameState) progressBuildings(changes *buildingChangeSet) {
+	started := false
+	for _, t := range game.tiles().lots {
+		if t.Zone != nil && t.Zone.Type != Reserve && t.Building == nil { // start
+			b := &Building{Type: t.Zone.Type, Stage: 1}
+			t.Building = b
+			game.logTile(t.X, t.Y, "building", "started", "construction", "")
+			changes.add(t.X, t.Y)
+			started = true
+		} else if t.Building != nil && !t.Building.Final && t.Building.Watered { // construction waits for water
+			if t.Building.Stage < 3 {
+				t.Building.Stage++
+			} else {
+				t.Building.Final = true
+				ct := game.unixNow()
+				t.Building.CompletedAt = &ct
+				t.Building.CompletedTick = game.Tick
+				game.homeChanged(t)
+				game.logTile(t.X, t.Y, "building", "completed", "construction", "")
+				if p := game.zoneOwner(t); p != nil {
+					p.Stats.BuildingsBuilt++
+				}
+			}
+			changes.add(t.X, t.Y)
+		}
+	}
+	if started {
+		game.index = nil
+	}
+}
+
+// gameLoop steps the simulation once per second of game time. It polls every speedPollInterval and
+// accumulates wall time scaled by the room speed, so speed changes take effect within one poll.
+func (r *Room) gameLoop() {
+	ticker := time.NewTicker(speedPollInterval)
+	defer ticker.Stop()
+	acc := time.Duration(0)
+	idle := false
+	var idleFor time.Duration
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+		if r.idle() != idle {
+			idle = !idle
+			idleFor = 0
+			if idle {
+				log.Println("room", r.Code, "has no clients; pausing")
+			} else {
+				log.Println("room", r.Code, "resuming")
+			}
+		}
+		if idle { // nothing accumulates, so the tick count picks up where it stopped
+			if idleFor += speedPollInterval; idleFor >= r.idleLimit {
+				idleFor = 0 // reap re-checks, and this retries after another timeout if someone is joining
+				go r.reap()
+			}
+			continue
+		}
+		r.mu.RLock()
+		speed := r.game.Speed
+		r.mu.RUnlock()
+		acc += speedPollInterval * time.Duration(speed)
+		for acc >= time.Second {
+			acc -= time.Second
+			r.guard("tick", func() { r.input(journalEntry{Kind: journalStep}) })
+		}
+	}
+}
+
+const speedPollInterval = 250 * time.Millisecond
+
+// guard runs one loop iteration, logging a panic instead of letting it kill the loop (and the
+// server). Room locks are released by their defers, so the room carries on with the next one.
+func (r *Room) guard(what string, f func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("room %s: %s panicked: %v\n%s", r.Code, what, v, debug.Stack())
+		}
+	}()
+	f()
+}
+
+// idle reports whether no client is connected to the room, in which case its game and traffic
+// loops stand still until one joins.
+func (r *Room) idle() bool {
+	return r.hub.connected.Load() == 0
+}
+
+// validSpeeds are the accepted game speed multipliers; 0 pauses the simulation.
+var validSpeeds = map[int]bool{0: true, 1: true, 2: true, 4: true}
+
+// setZoningBuffer turns the room's zoning buffer rule on or off for all later placements.
+func (r *Room) setZoningBuffer(p SetZoningBufferPayload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.game.ZoningBuffer = p.Enabled
+	r.game.announce(EventRulesChanged, struct {
+		ZoningBuffer bool `json:"zoningBuffer"`
+	}{p.Enabled})
+}
+
+// setSpeed changes the room's game speed. Paused rooms keep their connections and broadcasts alive.
+func (r *Room) setSpeed(p SetSpeedPayload) string {
+	if !validSpeeds[p.Speed] {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.game.Ended {
+		return ReasonRoomEnded
+	}
+	r.game.Speed = p.Speed
+	r.game.announce(EventSpeedChanged, p)
+	return ""
+}
+func (r *Room) stepGame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	if game.Ended {
+		return
+	}
+	// prune expired short-term road protection entries (prevent zoning over very recent roads)
+	if game.JustRoadThisTick == nil {
+		game.JustRoadThisTick = map[[2]int]int64{}
+	} else {
+		for k, exp := range game.JustRoadThisTick {
+			if exp <= game.Tick { // expired
+				delete(game.JustRoadThisTick, k)
+			}
+		}
+	}
+	for k, at := range game.bulldozedAt {
+		if game.Tick+1-at >= bulldozeCooldownTicks {
+			delete(game.bulldozedAt, k)
+		}
+	}
+	game.Tick++
+	adjustDemand(game.rng, &game.Demand) // baseline drift
+	applySeason(game.Tick, &game.Demand)
+	changes := newBuildingChangeSet()
+	game.updateWater(changes)
+	game.updatePower(changes)
+	game.updateTransit()
+	game.updateEducation(changes)
+	game.progressBuildings(changes)
+	game.naturalChange(changes)
+	game.growthTick(changes)
+	game.simulateCitizens()
+	game.reconcileCitizens()
+	game.allocateLaborAndSupplies(changes)
+	// Employment & demand adjustment
+	game.employmentDemandAdjust(changes)
+	game.fireTick(changes)
+	game.regrowFoliage()
+	game.updateLandValue()
+	game.updateHappiness()
+	game.updateCrime()
+	game.economicTick()
+	game.spawnVisitors()
+	game.updatePeakPopulation()
+	if game.Tick%budgetReportTicks == 0 {
+		game.reportBudgets()
+	}
+	game.aiTick()
+	// Snapshot after AI actions (e.g., bulldoze+road) so each tile is sent once with its final state
+	if updates := changes.snapshot(game); len(updates) > 0 {
+		game.announce(EventBuildingUpdate, struct {
+			Updates []BuildingUpdate `json:"updates"`
+		}{updates})
+	}
+	game.flushOwnership()
+	game.recordHistory()
+	// computed here under the write lock: both fill lazy caches, and /summary only holds the read lock
+	game.roadNetworks, game.stranded = len(game.roadNetwork().size), game.strandedFraction()
+	game.announce(EventTick, game.gameSummary())
+	game.checkObjectives()
+	r.recordTickMetrics()
+	if game.Tick%landValueBroadcastTicks == 0 {
+		game.broadcastLandValue()
+		game.broadcastCrime()
+		game.broadcastHeatmap()
+	}
+	game.decayHeat()
+}
+
+// ================= Objectives =================
+
+// Objective is a room goal: Metric reaching Target. A room may have several; each completes on its
+// own, and with EndOnGoals the room ends once all are complete.
+type Objective struct {
+	Metric        string `json:"metric"` // see objectiveMetrics
+	Target        int    `json:"target"`
+	Done          bool   `json:"done,omitempty"`
+	CompletedTick int64  `json:"completedTick,omitempty"`
+}
+
+// objectiveMetrics are the measurable city values objectives can target.
+var objectiveMetrics = map[string]func(game *GameState) int{
+	"population": func(game *GameState) int { return game.Population },
+	"employed":   func(game *GameState) int { return game.Employed },
+	"happiness":  func(game *GameState) int { return game.Happiness },
+	"tax": func(game *GameState) int { // land tax earned by all players
+		n := 0
+		for _, p := range game.Players {
+			n += p.Stats.TaxEarned
+		}
+		return n
+	},
+	"residential": func(game *GameState) int { return game.finalBuildings(Residential) },
+	"commercial":  func(game *GameState) int { return game.finalBuildings(Commercial) },
+	"industrial":  func(game *GameState) int { return game.finalBuildings(Industrial) },
+}
+
+// objectiveSpecs are new rooms' objectives from CITYSIM_OBJECTIVES, comma-separated metric:target
+// pairs such as "population:5000,tax:1000000,commercial:100".
+var objectiveSpecs = parseObjectives(os.Getenv("CITYSIM_OBJECTIVES"))
+
+func parseObjectives(v string) []Objective {
+	var out []Objective
+	for _, spec := range strings.Split(v, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		metric, target, _ := strings.Cut(spec, ":")
+		n, err := strconv.Atoi(target)
+		if _, ok := objectiveMetrics[metric]; !ok || err != nil || n <= 0 {
+			log.Printf("ignoring invalid objective in CITYSIM_OBJECTIVES: %q", spec)
+			continue
+		}
+		out = append(out, Objective{Metric: metric, Target: n})
+	}
+	return out
+}
+
+// finalBuildings counts finished buildings of type z that are not being abandoned.
+func (game *GameState) finalBuildings(z ZoneType) int {
+	n := 0
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			if b := t.Building; b != nil && b.Final && b.AbandonPhase == 0 && b.Type == z {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// checkObjectives completes every objective whose metric has reached its target this tick, and
+// ends the room once all are complete if EndOnGoals is set.
+func (game *GameState) checkObjectives() {
+	if len(game.Objectives) == 0 || game.Ended {
+		return
+	}
+	remaining := 0
+	var completed []*Objective
+	values := map[*Objective]int{}
+	for _, o := range game.Objectives {
+		if o.Done {
+			continue
+		}
+		if v := objectiveMetrics[o.Metric](game); v >= o.Target {
+			o.Done, o.CompletedTick = true, game.Tick
+			completed = append(completed, o)
+			values[o] = v
+		} else {
+			remaining++
+		}
+	}
+	ended := len(completed) > 0 && remaining == 0 && game.EndOnGoals
+	for _, o := range completed {
+		game.announce(EventObjectiveComplete, struct {
+			*Objective
+			Value     int  `json:"value"`
+			Remaining int  `json:"remaining"`
+			Ended     bool `json:"ended,omitempty"`
+		}{o, values[o], remaining, ended})
+	}
+	if ended {
+		game.Ended = true
+		game.Speed = 0
+		game.announce(EventSpeedChanged, SetSpeedPayload{Speed: 0})
+	}
+}
+
+// ================= Replay =================
+
+// With CITYSIM_RECORD_DIR set, each room journals its starting config and every input (game
+// steps, traffic frames, joins, leaves and player actions) in application order to
+// <dir>/<room>.replay.jsonl. ReplayFromFile re-applies a journal to reproduce the room exactly,
+// provided the server runs with the same simulation settings.
+
+const (
+	journalStart  = "start"
+	journalStep   = "step"
+	journalFrame  = "frame"
+	journalJoin   = "join"
+	journalLeave  = "leave"
+	journalAction = "action"
+)
+
+// journalEntry is one line of a replay journal.
+type journalEntry struct {
+	Kind    string          `json:"kind"`
+	At      int64           `json:"at"`                // unix time it was applied; placements are stamped with it
+	Config  *roomConfig     `json:"config,omitempty"`  // start
+	Elapsed time.Duration   `json:"elapsed,omitempty"` // frame: wall time since the previous frame
+	Player  PlayerID        `json:"player,omitempty"`  // join, leave, action
+	Name    string          `json:"name,omitempty"`    // join
+	Money   int             `json:"money,omitempty"`   // join: starting balance
+	Action  string          `json:"action,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func (r *Room) startJournal(cfg roomConfig) error {
+	dir := os.Getenv("CITYSIM_RECORD_DIR")
+	if dir == "" {
+		return nil
+	}
+	f, err := os.Create(filepath.Join(dir, r.Code+".replay.jsonl"))
+	if err != nil {
+		return err
+	}
+	r.journalFile = f
+	r.journal = json.NewEncoder(f)
+	return r.journal.Encode(journalEntry{Kind: journalStart, At: time.Now().Unix(), Config: &cfg})
+}
+
+func (r *Room) stopJournal() {
+	r.seq.Lock()
+	defer r.seq.Unlock()
+	if r.journalFile != nil {
+		r.journalFile.Close()
+		r.journal, r.journalFile = nil, nil
+	}
+}
+
+// input journals and applies one input, returning the Reason an action was rejected. Entries
+// without a time are stamped now.
+func (r *Room) input(e journalEntry) string {
+	r.seq.Lock()
+	defer r.seq.Unlock()
+	if e.At == 0 {
+		e.At = time.Now().Unix()
+	}
+	if r.journal != nil {
+		if err := r.journal.Encode(e); err != nil {
+			log.Println("room", r.Code, "journal write failed:", err)
+		}
+	}
+	r.game.clock = e.At
+	defer func() { r.game.clock = 0 }()
+	switch e.Kind {
+	case journalStep:
+		r.stepGame()
+	case journalFrame:
+		r.trafficFrame(e.Elapsed)
+	case journalJoin:
+		r.playerJoined(e.Player, e.Name, e.Money)
+	case journalLeave:
+		r.playerLeft(e.Player)
+	case journalAction:
+		return r.apply(e.Player, Envelope{Type: e.Action, Payload: e.Payload})
+	}
+	return ""
+}
+
+// unixNow is the time stamped on placements: the current input's time, or the wall clock.
+func (game *GameState) unixNow() int64 {
+	if game.clock != 0 {
+		return game.clock
+	}
+	return time.Now().Unix()
+}
+
+// ReplayFromFile re-runs a journal written under CITYSIM_RECORD_DIR and returns the final state.
+func ReplayFromFile(path string) (*GameState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	var start journalEntry
+	if err := dec.Decode(&start); err != nil {
+		return nil, err
+	}
+	if start.Kind != journalStart || start.Config == nil {
+		return nil, fmt.Errorf("%s: journal does not begin with a start entry", path)
+	}
+	r := newRoomFrom("replay", *start.Config)
+	go r.hub.run()
+	defer r.hub.stop()
+	for {
+		var e journalEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		r.guard("replay", func() { r.input(e) }) // an input that panicked live panics again; carry on as the room did
+	}
+	return r.game, nil
+}
+
+// ================= Binary protocol =================
+
+// binaryEvents are the high-volume broadcasts sent as msgpack to clients that connect with
+// ?format=msgpack; every other event, and full_state aside, stays JSON text.
+var binaryEvents = map[string]bool{EventTrafficUpdate: true, EventTrafficDelta: true}
+
+// msgpackFromJSON re-encodes a JSON document as msgpack with the same structure: objects become
+// maps (keys sorted), integral numbers become ints and other numbers float64.
+func msgpackFromJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, v), nil
+}
+
+func appendMsgpack(out []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(out, 0xc0)
+	case bool:
+		if v {
+			return append(out, 0xc3)
+		}
+		return append(out, 0xc2)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return appendMsgpackInt(out, i)
+		}
+		f, _ := v.Float64()
+		return binary.BigEndian.AppendUint64(append(out, 0xcb), math.Float64bits(f))
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			out = append(out, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			out = append(out, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			out = binary.BigEndian.AppendUint16(append(out, 0xda), uint16(n))
+		default:
+			out = binary.BigEndian.AppendUint32(append(out, 0xdb), uint32(n))
+		}
+		return append(out, v...)
+	case []interface{}:
+		out = appendMsgpackLen(out, len(v), 0x90, 0xdc)
+		for _, e := range v {
+			out = appendMsgpack(out, e)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out = appendMsgpackLen(out, len(v), 0x80, 0xde)
+		for _, k := range keys {
+			out = appendMsgpack(out, k)
+			out = appendMsgpack(out, v[k])
+		}
+		return out
+	}
+	return append(out, 0xc0)
+}
+
+// appendMsgpackLen writes an array or map header: fix is the fixarray/fixmap prefix, code16 the
+// 16-bit form (its 32-bit form follows it).
+func appendMsgpackLen(out []byte, n int, fix, code16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(out, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, code16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(out, code16+1), uint32(n))
+}
+
+func appendMsgpackInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(out, byte(i))
+	case i < 0 && i >= -32:
+		return append(out, byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(out, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(out, 0xd1), uint16(int16(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(out, 0xd2), uint32(int32(i)))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xd3), uint64(i))
+}
+
+// ================= Foliage =================
+
+// foliageGrowChance is the per-tick chance that bare, vacant grass sprouts a bush, and that a
+// bush grows into a tree.
+const foliageGrowChance = 0.0005
+
+// FoliageChange is one tile's new foliage.
+type FoliageChange struct {
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Foliage string `json:"foliage"`
+}
+
+// regrowFoliage lets vacant grass slowly return to bush and then tree, announcing the changed tiles.
+func (game *GameState) regrowFoliage() {
+	var grown []FoliageChange
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			if t.Terrain != "grass" || t.Foliage == "tree" || developed(t) && !reserved(t) || game.rng.Float64() >= foliageGrowChance {
+				continue
+			}
+			if t.Foliage == "" {
+				t.Foliage = "bush"
+			} else {
+				t.Foliage = "tree"
+			}
+			game.markTile(t)
+			grown = append(grown, FoliageChange{t.X, t.Y, t.Foliage})
+		}
+	}
+	if len(grown) > 0 {
+		game.announce(EventFoliage, struct {
+			Tiles []FoliageChange `json:"tiles"`
+		}{grown})
+	}
+}
+
+// ================= History =================
+
+// historyLen is how many ticks of HistorySamples a room keeps.
+const historyLen = 300
+
+// HistorySample is one tick's headline numbers, for charting.
+type HistorySample struct {
+	Tick       int64  `json:"tick"`
+	Population int    `json:"population"`
+	Employed   int    `json:"employed"`
+	Demand     Demand `json:"demand"`
+	Money      int    `json:"money"` // sum of all players' balances
+}
+
+// metricHistory is a ring buffer of the last historyLen samples.
+type metricHistory struct {
+	samples [historyLen]HistorySample
+	next, n int
+}
+
+func (h *metricHistory) add(s HistorySample) {
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % historyLen
+	if h.n < historyLen {
+		h.n++
+	}
+}
+
+// list returns the buffered samples, oldest first.
+func (h *metricHistory) list() []HistorySample {
+	out := make([]HistorySample, 0, h.n)
+	for i := h.next - h.n; i < h.next; i++ {
+		out = append(out, h.samples[(i+historyLen)%historyLen])
+	}
+	return out
+}
+
+func (game *GameState) recordHistory() {
+	money := 0
+	for _, pl := range game.Players {
+		money += pl.Money
+	}
+	game.history.add(HistorySample{Tick: game.Tick, Population: game.Population, Employed: game.Employed, Demand: game.Demand, Money: money})
+}
+
+func (game *GameState) gameSummary() TickSummary {
+	s := TickSummary{Tick: game.Tick, Hour: game.hour(), Demand: game.Demand, Population: game.Population, Employed: game.Employed, Happiness: game.Happiness,
+		Zones: map[ZoneType]int{}, Buildings: map[ZoneType]int{}, Money: make(map[PlayerID]int, len(game.Players)),
+		Vehicles: len(game.Vehicles), CitizenGroups: len(game.CitizenGroups), Goods: len(game.GoodsIC) + len(game.GoodsCC),
+		PowerSupply: game.PowerSupply, PowerDemand: game.PowerDemand,
+		RoadComponents: game.roadNetworks, Stranded: game.stranded}
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			if t.Road != nil {
+				s.Roads++
+			}
+			if t.Zone != nil {
+				s.Zones[t.Zone.Type]++
+			}
+			if b := t.Building; b != nil {
+				if b.AbandonPhase > 0 {
+					s.Abandoning++
+				} else if b.Final {
+					s.Buildings[b.Type]++
+				}
+			}
+		}
+	}
+	for id, pl := range game.Players {
+		s.Money[id] = pl.Money
+	}
+	return s
+}
+
+// employmentDemandAdjust recalculates employment, adjusts demands and triggers out-migration when sustained unemployment.
+func (game *GameState) employmentDemandAdjust(changes *buildingChangeSet) {
+	jobCapacity := 0
+	actualEmployees := 0
+	industrialEmployees := 0
+	commercialEmployees := 0
+	idx := game.tiles()
+	for _, t := range idx.buildings {
+		b := t.Building
+		if b == nil || !b.Final || b.AbandonPhase > 0 {
+			continue
+		}
+		switch b.Type {
+		case Industrial:
+			jobCapacity += game.Config.IndustrialCapacity
+			industrialEmployees += b.Employees
+		case Commercial:
+			jobCapacity += game.Config.CommercialCapacity
+			commercialEmployees += b.Employees
+		}
+		actualEmployees += b.Employees
+	}
+	game.Employed = actualEmployees
+	unemployed := game.Population - actualEmployees
+	if game.Population == 0 {
+		return
+	}
+	ratio := float64(unemployed) / float64(game.Population)
+	// Compute residential capacity & open slots fresh for demand basis
+	resCap := 0
+	resUsed := 0
+	for _, t := range idx.residential {
+		if b := t.Building; b != nil && b.Final && b.Type == Residential && b.AbandonPhase == 0 {
+			resCap += 10
+			resUsed += b.Residents
+		}
+	}
+	openSlots := resCap - resUsed
+	// Residential demand core rule: fewer slots => higher demand.
+	if resCap == 0 {
+		game.Demand.Residential += 8
+	} else if openSlots <= 0 { // full
+		game.Demand.Residential += 6
+	} else if openSlots < 8 {
+		game.Demand.Residential += 3
+	} else if openSlots > 50 {
+		game.Demand.Residential -= 4
+	} else if openSlots > 30 {
+		game.Demand.Residential -= 2
+	}
+
+	// Job-driven adjustments: if many unfilled jobs (capacity >> population), increase residential to attract workers.
+	unfilledJobs := jobCapacity - actualEmployees
+	if unfilledJobs > 0 {
+		// scale bonus with unfilled ratio but cap
+		bonus := unfilledJobs / 10
+		if bonus > 6 {
+			bonus = 6
+		}
+		if bonus > 0 {
+			game.Demand.Residential += bonus
+		}
+	}
+
+	// Unemployment handling: if moderate unemployment, lightly push non-residential, but don't tank residential harshly.
+	if ratio > 0.25 { // noticeable unemployment
+		game.Demand.Industrial += 2
+		game.Demand.Commercial += 1
+		// light out-migration pressure
+		if game.rng.Float64() < ratio*0.1 {
+			removed := 0
+			target := 2 + game.rng.Intn(4)
+			for _, t := range idx.residential {
+				if removed >= target {
+					break
+				}
+				if b := t.Building; b != nil && b.Final && b.Type == Residential && b.Residents > 0 {
+					b.Residents--
+					game.homeChanged(t)
+					removed++
+					changes.add(t.X, t.Y)
+				}
+			}
+		}
+	} else if ratio < 0.05 { // very low unemployment, ease back res demand
+		game.Demand.Residential -= 1
+	}
+	// unhappy households move away, more readily the unhappier they are
+	for _, t := range idx.residential {
+		b := t.Building
+		if b == nil || !b.Final || b.Type != Residential || b.Residents == 0 || t.Happiness >= unhappyThreshold {
+			continue
+		}
+		if game.rng.Float64() < float64(unhappyThreshold-t.Happiness)/200 {
+			b.Residents--
+			game.homeChanged(t)
+			changes.add(t.X, t.Y)
+		}
+	}
+}
+func adjustDemand(rng *rand.Rand, d *Demand) {
+	list := []*int{&d.Residential, &d.Commercial, &d.Industrial}
+	for _, v := range list {
+		*v = clampDemand(*v + rng.Intn(5) - 2)
+	}
+}
+
+func clampDemand(v int) int {
+	return min(max(v, -50), 120)
+}
+
+// Seasons: a year is four seasons of daysPerSeason days. Each demand component follows a cosine
+// of amplitude seasonAmplitude (CITYSIM_SEASON_AMPLITUDE) peaking mid-season: residential in
+// spring, industrial in summer, commercial in winter.
+const (
+	daysPerSeason = 7
+	ticksPerYear  = 4 * daysPerSeason * ticksPerDay
+	// peak of each component in eighths of a year, spring starting at 0
+	residentialPeak = 1
+	industrialPeak  = 3
+	commercialPeak  = 7
+)
+
+var seasonAmplitude = envFloat("CITYSIM_SEASON_AMPLITUDE", 10)
+
+func seasonalOffset(tick int64, peakEighths int) int {
+	phase := 2 * math.Pi * (float64(tick%ticksPerYear)/ticksPerYear - float64(peakEighths)/8)
+	return int(math.Round(seasonAmplitude * math.Cos(phase)))
+}
+
+// applySeason adds the change in each component's seasonal offset since the previous tick, so the
+// seasons swing demand around wherever drift and the economy have put it.
+func applySeason(tick int64, d *Demand) {
+	shift := func(v *int, peak int) {
+		*v = clampDemand(*v + seasonalOffset(tick, peak) - seasonalOffset(tick-1, peak))
+	}
+	shift(&d.Residential, residentialPeak)
+	shift(&d.Commercial, commercialPeak)
+	shift(&d.Industrial, industrialPeak)
+}
+func (game *GameState) simulateCitizens() {
+	// Population = sum of residents in residential buildings
+	pop := 0
+	for _, t := range game.tiles().residential {
+		if b := t.Building; b != nil && b.Final && b.Type == Residential {
+			pop += b.Residents
+		}
+	}
+	game.Population = pop
+	// Employment approximated: total assigned employees (recomputed later)
+}
+
+// Allocation & abandonment
+const (
+	commercialSupplyNeed = 1
+	abandonPhaseTicks    = 3 // ticks spent in black phase before removal
+	atRiskDivisor        = 2 // a building is reported at risk once idle for 1/atRiskDivisor of its threshold
+	maxIndustrialStock   = 8
+	shipmentUnits        = 2 // goods carried by one IC shipment; CC shipments carry 1
+	customersPerSupply   = 4 // commercial customers served per unit of supplies
+	supplySpoilTicks     = 4
+	maxCommuteDistance   = 30 // road tiles; jobs farther than this from any housing go unstaffed
+	shoppingDistance     = 12 // road tiles; a shop's customers are the residents of homes this close
+)
+
+const vitalRate = 0.002 // births and deaths per resident per tick in a city of happiness 50
+
+// naturalChange applies births and deaths. Births join PendingResidents to find housing through
+// growthTick; deaths remove residents from random homes. Happiness above 50 raises births and
+// lowers deaths, below 50 the reverse; expected counts are rounded stochastically.
+func (game *GameState) naturalChange(changes *buildingChangeSet) {
+	if game.Population == 0 {
+		return
+	}
+	h := float64(game.Happiness) / 100
+	count := func(rate float64) int {
+		expected := float64(game.Population) * rate
+		n := int(expected)
+		if game.rng.Float64() < expected-float64(n) {
+			n++
+		}
+		return n
+	}
+	births := count(vitalRate * (0.5 + h))
+	deaths := count(vitalRate * (1.5 - h))
+	for i := 0; i < births; i++ {
+		game.PendingResidents = append(game.PendingResidents, 0)
+	}
+	var homes [][2]int
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			if b := game.Tiles[y][x].Building; b != nil && b.Final && b.Type == Residential && b.Residents > 0 {
+				homes = append(homes, [2]int{x, y})
+			}
+		}
+	}
+	for i := 0; i < deaths && len(homes) > 0; i++ {
+		c := homes[game.rng.Intn(len(homes))]
+		if t := game.Tiles[c[1]][c[0]]; t.Building.Residents > 0 {
+			t.Building.Residents--
+			game.homeChanged(t)
+			changes.add(c[0], c[1])
+		}
+	}
+}
+
+// growthTick: introduce new residents trying to occupy available residential slots. Applicants
+// arrive as inbound citizen groups driving in from a road on the map edge, and only move in when
+// they reach the home; homes with no road to the edge get nobody new.
+func (game *GameState) growthTick(changes *buildingChangeSet) {
+	for _, c := range game.movedIn { // arrivals since the last tick
+		changes.add(c[0], c[1])
+	}
+	game.movedIn = game.movedIn[:0]
+	// spawn a few new applicants each tick; a happy city attracts more
+	newApplicants := max(3+(game.Happiness-50)/happyApplicantStep, 1)
+	for i := 0; i < newApplicants; i++ {
+		game.PendingResidents = append(game.PendingResidents, 0)
+	}
+	// places already taken by groups on their way in
+	inbound := map[[2]int]int{}
+	for _, g := range game.CitizenGroups {
+		if g.State == "inbound" {
+			inbound[[2]int{g.DestX, g.DestY}] += g.Count
+		}
+	}
+	// applicants head for the open home on the most valuable land, waterfront homes counting
+	// extra, earliest tile first on ties; land values change every tick, so the heap is ordered
+	// afresh from the open homes each tick and popped as homes fill
+	open := &homeHeap{}
+	for t := range game.openHomes() {
+		game.homeChanged(t) // drops homes filled, abandoning or demolished since they were filed
+		if b := t.Building; !game.homes[t] || !b.Watered || b.Residents+inbound[[2]int{t.X, t.Y}] >= maxResidents {
+			continue
+		}
+		value := t.LandValue
+		if game.waterfront(t.X, t.Y) {
+			value += waterfrontGrowthBonus
+		}
+		*open = append(*open, openHome{t, value, t.Y*game.Width + t.X})
+	}
+	heap.Init(open)
+	var fromEdge func([2]int) [][2]int
+	moving := map[*Tile]*CitizenGroup{} // this tick's group to each home
+	assignedIdx := map[int]bool{}
+	for idx := range game.PendingResidents {
+		var t *Tile
+		for open.Len() > 0 && t == nil {
+			t = (*open)[0].t
+			if moving[t] != nil {
+				break
+			}
+			if fromEdge == nil {
+				fromEdge = game.edgeRoutes()
+			}
+			var p [][2]int
+			if rx, ry, ok := game.adjacentRoad(t.X, t.Y); ok {
+				p = fromEdge([2]int{rx, ry})
+			}
+			if len(p) == 0 || !game.entityRoom() {
+				heap.Pop(open)
+				t = nil
+				continue
+			}
+			game.citizenSeq++
+			path := append(p[1:], [2]int{t.X, t.Y})
+			g := &CitizenGroup{ID: game.citizenSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: path, State: "inbound", OriginX: p[0][0], OriginY: p[0][1], DestX: t.X, DestY: t.Y}
+			game.CitizenGroups = append(game.CitizenGroups, g)
+			moving[t] = g
+		}
+		if t == nil {
+			break
+		}
+		moving[t].Count++
+		assignedIdx[idx] = true
+		if k := [2]int{t.X, t.Y}; t.Building.Residents+inbound[k]+moving[t].Count >= maxResidents {
+			heap.Pop(open)
+		}
+	}
+	// rebuild pending list with incremented waits for unassigned
+	newPending := make([]int, 0, len(game.PendingResidents))
+	for idx, wait := range game.PendingResidents {
+		if assignedIdx[idx] {
+			continue
+		}
+		wait++
+		if wait > 5 { // leave city
+			continue
+		}
+		newPending = append(newPending, wait)
+	}
+	game.PendingResidents = newPending
+}
+
+// maxResidents is how many residents a home holds.
+const maxResidents = 10
+
+// waterfrontGrowthBonus is added to a waterfront home's land value when newcomers choose a home.
+const waterfrontGrowthBonus = 10
+
+// edgeRoutes searches the road network outward from every road tile on the map edge and returns a
+// func giving the shortest way in from the edge to a road tile, edge tile first, or nil if that
+// road cannot be reached from the edge.
+func (game *GameState) edgeRoutes() func([2]int) [][2]int {
+	prev := map[[2]int][2]int{}
+	var q [][2]int
+	for _, t := range game.tiles().roads {
+		if t.X == 0 || t.Y == 0 || t.X == game.Width-1 || t.Y == game.Height-1 {
+			k := [2]int{t.X, t.Y}
+			prev[k] = k
+			q = append(q, k)
+		}
+	}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if _, seen := prev[n]; seen || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Road == nil || !game.roadStepAllowed(cur[0], cur[1], n[0], n[1]) {
+				continue
+			}
+			prev[n] = cur
+			q = append(q, n)
+		}
+	}
+	return func(to [2]int) [][2]int {
+		if _, ok := prev[to]; !ok {
+			return nil
+		}
+		path := [][2]int{to}
+		for cur := to; prev[cur] != cur; cur = prev[cur] {
+			path = append(path, prev[cur])
+		}
+		slices.Reverse(path)
+		return path
+	}
+}
+
+// openHomes returns the homes that may have room for newcomers, kept across ticks: a home is
+// filed when it is finished, loses a resident or is restored by undo, and dropped when growthTick
+// finds it full, abandoning or gone. The first call after load scans the residential tiles.
+func (game *GameState) openHomes() homeSet {
+	if game.homes == nil || game.fullScan {
+		game.homes = homeSet{}
+		for _, t := range game.tiles().residential {
+			game.homeChanged(t)
+		}
+	}
+	return game.homes
+}
+
+// homeSet is a set of residential tiles.
+type homeSet map[*Tile]bool
+
+// homeChanged files t among the open homes if its building is a finished home with room that is
+// not abandoning, and drops it otherwise.
+func (game *GameState) homeChanged(t *Tile) {
+	if game.homes == nil {
+		return
+	}
+	if b := t.Building; b != nil && b.Final && b.Type == Residential && b.Residents < maxResidents && b.AbandonPhase == 0 {
+		game.homes[t] = true
+	} else {
+		delete(game.homes, t)
+	}
+}
+
+// openHome is a home with room for another resident; value is its appeal to newcomers and order
+// its place in tile scan order.
+type openHome struct {
+	t     *Tile
+	value int
+	order int
+}
+
+// homeHeap orders open homes by value, highest first, then by tile order.
+type homeHeap []openHome
+
+func (h homeHeap) Len() int { return len(h) }
+func (h homeHeap) Less(i, j int) bool {
+	if h[i].value != h[j].value {
+		return h[i].value > h[j].value
+	}
+	return h[i].order < h[j].order
+}
+func (h homeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *homeHeap) Push(x interface{}) { *h = append(*h, x.(openHome)) }
+func (h *homeHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+func (game *GameState) allocateLaborAndSupplies(changes *buildingChangeSet) {
+	type ref struct {
+		b    *Building
+		t    *Tile
+		x, y int
+	}
+	var inds []*Building
+	var comm []*Building
+	var res []*Building
+	refs := []ref{}
+	for _, t := range game.tiles().buildings {
+		if b := t.Building; b != nil && b.Final {
+			refs = append(refs, ref{b, t, t.X, t.Y})
+			switch b.Type {
+			case Industrial:
+				inds = append(inds, b)
+			case Commercial:
+				comm = append(comm, b)
+			case Residential:
+				res = append(res, b)
+			}
+		}
+	}
+	// Commute model: jobs are staffed nearest-first by road distance from occupied housing.
+	// Jobs with no road route from housing within maxCommuteDistance cannot keep workers.
+	commute := game.commuteDistances()
+	jobDist := map[*Building]int{}
+	tooFar := map[*Building]bool{}
+	for _, r := range refs {
+		if r.b.Type == Residential {
+			continue
+		}
+		d, ok := jobCommuteDistance(commute, r.x, r.y)
+		if !ok || d > maxCommuteDistance {
+			tooFar[r.b] = true
+			r.b.Employees = 0
+			continue
+		}
+		jobDist[r.b] = d
+	}
+	// equally distant jobs are visited from a start that rotates every tick, so the round-robin
+	// fills and trims below don't keep favoring the same buildings
+	game.laborRotation++
+	reachable := func(list []*Building) []*Building {
+		out := make([]*Building, 0, len(list))
+		for _, b := range list {
+			if !tooFar[b] {
+				out = append(out, b)
+			}
+		}
+		if len(out) > 0 {
+			k := game.laborRotation % len(out)
+			out = slices.Concat(out[k:], out[:k])
+		}
+		sort.SliceStable(out, func(i, j int) bool { return jobDist[out[i]] < jobDist[out[j]] })
+		return out
+	}
+	inds = reachable(inds)
+	comm = reachable(comm)
+	// Persistent workforce model:
+	// We no longer reset Employees each tick. Instead we adjust toward a target available worker pool
+	// while preserving existing assignments as much as possible. This reduces oscillation and
+	// prevents commercial buildings from being repeatedly starved every tick.
+
+	// Desired available workers: fill up to min(jobCapacity, population) to avoid artificial structural unemployment.
+	jobCapacity := len(inds)*game.Config.IndustrialCapacity + len(comm)*game.Config.CommercialCapacity
+	targetWorkers := jobCapacity
+	if targetWorkers > game.Population {
+		targetWorkers = game.Population
+	}
+	// Count current workers (only active, non-abandoning buildings matter)
+	currentWorkers := 0
+	for _, b := range inds {
+		if b.AbandonPhase == 0 {
+			currentWorkers += b.Employees
+		}
+	}
+	for _, b := range comm {
+		if b.AbandonPhase == 0 {
+			currentWorkers += b.Employees
+		}
+	}
+	// If we have more workers assigned than target, scale down (remove from Commercial first, then Industrial)
+	if currentWorkers > targetWorkers {
+		diff := currentWorkers - targetWorkers
+		for diff > 0 {
+			changed := false
+			// Trim commercial employees first (reverse order for mild fairness rotation)
+			for i := len(comm) - 1; i >= 0 && diff > 0; i-- {
+				b := comm[i]
+				if b.AbandonPhase > 0 || b.Employees == 0 {
+					continue
+				}
+				b.Employees--
+				diff--
+				changed = true
+			}
+			// Then trim industrial
+			for i := len(inds) - 1; i >= 0 && diff > 0; i-- {
+				b := inds[i]
+				if b.AbandonPhase > 0 || b.Employees == 0 {
+					continue
+				}
+				b.Employees--
+				diff--
+				changed = true
+			}
+			if !changed { // nothing to trim
+				break
+			}
+		}
+	} else if currentWorkers < targetWorkers { // Need to add workers
+		diff := targetWorkers - currentWorkers
+		// Step 1: ensure at least 1 worker at each industrial (production base)
+		for _, b := range inds {
+			if diff == 0 {
+				break
+			}
+			if b.AbandonPhase == 0 && b.Employees == 0 && game.Config.IndustrialCapacity > 0 {
+				b.Employees = 1
+				diff--
+			}
+		}
+		// Step 2: ensure at least 1 worker at each commercial (so they can potentially open)
+		for _, b := range comm {
+			if diff == 0 {
+				break
+			}
+			if b.AbandonPhase == 0 && b.Employees == 0 && game.Config.CommercialCapacity > 0 {
+				b.Employees = 1
+				diff--
+			}
+		}
+		// Step 3: fill remaining industrial capacity (prioritize production to curb over-weighted commercial expansion)
+		for diff > 0 {
+			progress := false
+			for _, b := range inds {
+				if diff == 0 {
+					break
+				}
+				if b.AbandonPhase == 0 && b.Employees < game.Config.IndustrialCapacity {
+					b.Employees++
+					diff--
+					progress = true
+				}
+			}
+			// Step 4: then fill commercial capacity round-robin style
+			for _, b := range comm {
+				if diff == 0 {
+					break
+				}
+				if b.AbandonPhase == 0 && b.Employees < game.Config.CommercialCapacity {
+					b.Employees++
+					diff--
+					progress = true
+				}
+			}
+			if !progress || diff == 0 {
+				break
+			}
+		}
+	}
+	// industrial production proportional to employees (1 good per fully staffed 4, so employees/4 rounded up minimal 1 if any)
+	// goods wait in industrial stock until an IC shipment carries them to a shop (see spawnGoodsShipments);
+	// each level of workforce education adds a good per tick
+	for _, r := range refs {
+		if b := r.b; b.Type == Industrial {
+			b.EducationLevel = game.workforceEducation(r.x, r.y)
+		}
+	}
+	for _, b := range inds {
+		if b.Employees > 0 && !game.blackedOut(b) {
+			gain := b.Employees / game.Config.IndustrialCapacity
+			if gain == 0 {
+				gain = 1
+			}
+			gain += b.EducationLevel
+			b.Stock = min(b.Stock+gain, maxIndustrialStock)
+		}
+	}
+	// a staffed shop uses one unit per customersPerSupply shoppers on its tile, and stock spoils
+	// at one unit every supplySpoilTicks, so a shop no shipment reaches eventually runs dry
+	for _, r := range refs {
+		if b := r.b; b.Type == Commercial && b.Employees > 0 {
+			used := r.t.Citizens / customersPerSupply
+			if game.Tick%supplySpoilTicks == 0 {
+				used++
+			}
+			b.Supplies = max(b.Supplies-used, 0)
+		}
+	}
+	customers := game.shopCustomers()
+	// homes with newcomers on the way are not left empty
+	expected := map[[2]int]bool{}
+	for _, g := range game.CitizenGroups {
+		if g.State == "inbound" {
+			expected[[2]int{g.DestX, g.DestY}] = true
+		}
+	}
+	// evaluate abandonment criteria & phases
+	for _, r := range refs {
+		b := r.b
+		if b.AbandonPhase > 0 { // countdown
+			b.AbandonPhase--
+			if b.AbandonPhase == 0 { // remove now
+				if p := game.zoneOwner(r.t); p != nil {
+					p.Stats.BuildingsLost++
+				}
+				r.t.Building = nil
+				game.logTile(r.x, r.y, "building", "removed", "abandoned", "")
+				if !abandonKeepsZone[b.Type] {
+					r.t.Zone = nil
+					game.logTile(r.x, r.y, "zone", "removed", "abandoned", "")
+				}
+				game.index = nil
+			}
+			changes.add(r.x, r.y)
+			continue
+		}
+		// determine active criteria (new logic with extended commercial threshold)
+		var failing bool
+		switch b.Type {
+		case Residential:
+			failing = b.Residents == 0 && !expected[[2]int{r.x, r.y}]
+		case Industrial:
+			failing = (b.Employees == 0)
+		case Commercial:
+			open := (b.Employees >= 1 && b.Supplies >= commercialSupplyNeed && customers[b] >= game.Config.CommercialCustomerNeed && !game.blackedOut(b))
+			failing = !open
+		}
+		// high crime speeds decline and stops a failing building from recovering
+		crimeHit := r.t.Crime >= highCrime
+		// a building still settling in after completion doesn't count an outage toward abandonment
+		settling := game.Tick-b.CompletedTick < int64(game.Config.AbandonGraceTicks)
+		if failing && !settling {
+			b.IdleTicks++
+			if crimeHit {
+				b.IdleTicks++
+			}
+		} else if !failing && !crimeHit {
+			b.IdleTicks = 0
+		}
+		if b.IdleTicks >= game.abandonThreshold(b) {
+			b.IdleTicks = 0
+			b.AbandonPhase = abandonPhaseTicks
+			b.AbandonReason = abandonReason(b, tooFar[b])
+			game.logTile(r.x, r.y, "building", "abandoning", b.AbandonReason, "")
+		}
+		changes.add(r.x, r.y)
+	}
+}
+
+// abandonThreshold is how many idle ticks start b's abandonment.
+func (game *GameState) abandonThreshold(b *Building) int {
+	if b.Type == Commercial {
+		return game.Config.AbandonTriggerTicksBase * game.Config.CommercialAbandonFactor
+	}
+	return game.Config.AbandonTriggerTicksBase
+}
+
+// Abandonment reasons reported on Building.AbandonReason
+const (
+	AbandonNoResidents   = "noResidents"
+	AbandonNoWorkers     = "noWorkers"
+	AbandonNotViable     = "notViable" // commercial lacking staff, supplies or customers
+	AbandonCommuteTooFar = "commuteTooFar"
+)
+
+func abandonReason(b *Building, tooFar bool) string {
+	switch {
+	case b.Type == Residential:
+		return AbandonNoResidents
+	case tooFar:
+		return AbandonCommuteTooFar
+	case b.Type == Industrial:
+		return AbandonNoWorkers
+	default:
+		return AbandonNotViable
+	}
+}
+
+// commuteDistances runs a multi-source BFS over the road network starting from every road tile
+// adjacent to occupied housing, returning the road distance of each reachable road tile. Like
+// roadPath, it only steps with the flow of one-way roads.
+func (game *GameState) commuteDistances() map[[2]int]int {
+	dist := map[[2]int]int{}
+	q := [][2]int{}
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			b := game.Tiles[y][x].Building
+			if b == nil || !b.Final || b.Type != Residential || b.Residents == 0 {
+				continue
+			}
+			for _, d := range dirs {
+				nx, ny := x+d[0], y+d[1]
+				key := [2]int{nx, ny}
+				if !game.inBounds(nx, ny) || game.Tiles[ny][nx].Road == nil {
+					continue
+				}
+				if _, seen := dist[key]; !seen {
+					dist[key] = 0
+					q = append(q, key)
+				}
+			}
+		}
+	}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		for _, d := range dirs {
+			nx, ny := cur[0]+d[0], cur[1]+d[1]
+			key := [2]int{nx, ny}
+			if !game.inBounds(nx, ny) || game.Tiles[ny][nx].Road == nil || !game.roadStepAllowed(cur[0], cur[1], nx, ny) {
+				continue
+			}
+			if _, seen := dist[key]; !seen {
+				dist[key] = dist[cur] + 1
+				q = append(q, key)
+			}
+		}
+	}
+	return dist
+}
+
+// shopCustomers counts each finished shop's customers: the residents of every home with an access
+// road within shoppingDistance road steps of one of the shop's access roads, driving with the flow
+// of one-way roads.
+func (game *GameState) shopCustomers() map[*Building]int {
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	access := func(x, y int) [][2]int {
+		var out [][2]int
+		for _, d := range dirs {
+			if nx, ny := x+d[0], y+d[1]; game.inBounds(nx, ny) && game.Tiles[ny][nx].Road != nil {
+				out = append(out, [2]int{nx, ny})
+			}
+		}
+		return out
+	}
+	homesByRoad := map[[2]int][]*Building{}
+	for _, t := range game.tiles().residential {
+		if b := t.Building; b != nil && b.Final && b.Type == Residential && b.Residents > 0 {
+			for _, r := range access(t.X, t.Y) {
+				homesByRoad[r] = append(homesByRoad[r], b)
+			}
+		}
+	}
+	customers := map[*Building]int{}
+	for _, g := range game.CitizenGroups { // visitors at the shop
+		if g.State == "shopping" {
+			if b := game.Tiles[g.DestY][g.DestX].Building; b != nil {
+				customers[b] += g.Count
+			}
+		}
+	}
+	for _, t := range game.tiles().commercial {
+		shop := t.Building
+		if shop == nil || !shop.Final || shop.Type != Commercial {
+			continue
+		}
+		dist := map[[2]int]int{}
+		q := access(t.X, t.Y)
+		for _, r := range q {
+			dist[r] = 0
+		}
+		counted := map[*Building]bool{}
+		for len(q) > 0 {
+			cur := q[0]
+			q = q[1:]
+			for _, home := range homesByRoad[cur] {
+				if !counted[home] {
+					counted[home] = true
+					customers[shop] += home.Residents
+				}
+			}
+			if dist[cur] == shoppingDistance {
+				continue
+			}
+			for _, d := range dirs {
+				n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+				if _, seen := dist[n]; seen || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Road == nil || !game.roadStepAllowed(n[0], n[1], cur[0], cur[1]) {
+					continue
+				}
+				dist[n] = dist[cur] + 1
+				q = append(q, n)
+			}
+		}
+	}
+	return customers
+}
+
+// jobCommuteDistance returns the shortest commute distance to the building at (x,y) via any adjacent road.
+func jobCommuteDistance(commute map[[2]int]int, x, y int) (int, bool) {
+	best, found := 0, false
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for _, d := range dirs {
+		if v, ok := commute[[2]int{x + d[0], y + d[1]}]; ok && (!found || v < best) {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
+
+func (game *GameState) economicTick() {
+	income := game.Employed/10 + game.Population/20
+	// educated industrial workers earn more
+	skilled := 0
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			if b := game.Tiles[y][x].Building; b != nil && b.Final && b.Type == Industrial {
+				skilled += b.Employees * b.EducationLevel
+			}
+		}
+	}
+	income += skilled / 10
+	for _, p := range game.Players {
+		p.Money += income
+		game.budget(p.ID).Income += income
+	}
+	// Interest is charged even when it drives money negative; a player in the red cannot borrow
+	// or build until income catches up, so overborrowing leads toward bankruptcy.
+	for _, p := range game.Players {
+		if p.Debt > 0 {
+			interest := int(math.Ceil(float64(p.Debt) * p.InterestRate))
+			p.Money -= interest
+			game.budget(p.ID).Interest -= interest
+		}
+	}
+	// Trade settles once per tick: export income and import costs since the last one
+	for pid, amount := range game.Trade {
+		if p := game.Players[pid]; p != nil {
+			p.Money += amount
+			game.budget(pid).Trade += amount
+		}
+	}
+	clear(game.Trade)
+	// Tourism: each airport earns its owner a share of the city's population
+	for _, c := range game.airports() {
+		if p := game.Players[game.Tiles[c[1]][c[0]].Structure.Owner]; p != nil {
+			tourism := game.Population / tourismDivisor
+			p.Money += tourism
+			game.budget(p.ID).Tourism += tourism
+		}
+	}
+	// Land tax: occupied housing on valuable land pays its zone owner extra
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			b := t.Building
+			if b == nil || !b.Final || b.Type != Residential || b.Residents == 0 || t.Zone == nil {
+				continue
+			}
+			if p := game.Players[t.Zone.Owner]; p != nil {
+				tax := b.Residents * t.LandValue / landTaxDivisor
+				p.Money += tax
+				game.budget(p.ID).LandTax += tax
+				p.Stats.TaxEarned += tax
+			}
+		}
+	}
+	// Structure upkeep
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			st := game.Tiles[y][x].Structure
+			if st == nil {
+				continue
+			}
+			if p := game.Players[st.Owner]; p != nil && st.spec().Upkeep > 0 {
+				upkeep := st.spec().Upkeep
+				p.Money -= upkeep
+				game.budget(p.ID).Maintenance -= upkeep
+			}
+		}
+	}
+}
+
+// zoneOwner is the player owning t's zone, if any.
+func (game *GameState) zoneOwner(t *Tile) *Player {
+	if t.Zone == nil {
+		return nil
+	}
+	return game.Players[t.Zone.Owner]
+}
+
+// updatePeakPopulation raises each player's PeakPopulation to the residents now housed on their zones.
+func (game *GameState) updatePeakPopulation() {
+	pop := map[*Player]int{}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if b := t.Building; b != nil && b.Final && b.Type == Residential {
+				if p := game.zoneOwner(t); p != nil {
+					pop[p] += b.Residents
+				}
+			}
+		}
+	}
+	for p, n := range pop {
+		p.Stats.PeakPopulation = max(p.Stats.PeakPopulation, n)
+	}
+}
+
+// Leaderboard score weights
+const (
+	scorePerResident = 1  // of PeakPopulation
+	scoreTaxDivisor  = 10 // one point per this much TaxEarned
+	scorePerBuilding = 5
+	scorePerLoss     = 10 // taken off per building lost to abandonment
+)
+
+func (s PlayerStats) score() int {
+	return s.PeakPopulation*scorePerResident + s.TaxEarned/scoreTaxDivisor + s.BuildingsBuilt*scorePerBuilding - s.BuildingsLost*scorePerLoss
+}
+
+// LeaderboardEntry is one player's rank, score and stats.
+type LeaderboardEntry struct {
+	Rank  int         `json:"rank"`
+	Score int         `json:"score"`
+	Stats PlayerStats `json:"stats"`
+	PlayerInfo
+}
+
+// leaderboard ranks every player, bots included, by score, breaking ties by name then id.
+func (game *GameState) leaderboard() []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(game.Players))
+	for _, p := range game.Players {
+		entries = append(entries, LeaderboardEntry{Score: p.Stats.score(), Stats: p.Stats, PlayerInfo: p.info()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// sendLeaderboard sends the requesting client the ranked players.
+func (c *Client) sendLeaderboard() {
+	c.room.mu.RLock()
+	entries := c.room.game.leaderboard()
+	c.room.mu.RUnlock()
+	c.sendEvent(EventLeaderboard, struct {
+		Players []LeaderboardEntry `json:"players"`
+	}{entries})
+}
+
+// Budget breaks down a player's money flows since the last report. Expenses are negative, so
+// the fields sum to Net, the change in money excluding construction spending and loans.
+type Budget struct {
+	Income      int `json:"income"`  // city income shared by every player
+	LandTax     int `json:"landTax"` // from housing on the player's zones
+	Trade       int `json:"trade"`   // exports less imports
+	Tourism     int `json:"tourism"` // airport visitor spending
+	Maintenance int `json:"maintenance"`
+	Interest    int `json:"interest"`
+	Refunds     int `json:"refunds"` // from bulldozing
+	Net         int `json:"net"`
+}
+
+const budgetReportTicks = ticksPerDay
+
+func (game *GameState) budget(pid PlayerID) *Budget {
+	if game.Budgets == nil {
+		game.Budgets = map[PlayerID]*Budget{}
+	}
+	b := game.Budgets[pid]
+	if b == nil {
+		b = &Budget{}
+		game.Budgets[pid] = b
+	}
+	return b
+}
+
+// reportBudgets announces every player's budget for the period and starts a new one.
+func (game *GameState) reportBudgets() {
+	for _, b := range game.Budgets {
+		b.Net = b.Income + b.LandTax + b.Trade + b.Tourism + b.Maintenance + b.Interest + b.Refunds
+	}
+	game.announce(EventBudgetReport, struct {
+		Tick    int64                `json:"tick"`
+		Ticks   int                  `json:"ticks"` // length of the period covered
+		Players map[PlayerID]*Budget `json:"players"`
+	}{game.Tick, budgetReportTicks, game.Budgets})
+	game.Budgets = nil
+}
+
+// ================= Water =================
+const waterLossTicks = 3 // an unwatered home loses a resident every this many ticks
+
+// developed reports whether a tile carries anything water pipes can follow; foliage doesn't grow
+// on such tiles and bots don't build there.
+func developed(t *Tile) bool {
+	return t.Road != nil || t.Rail != nil || t.Zone != nil || t.Building != nil || t.Structure != nil
+}
+
+// reserved reports whether t is zoned as a reserve.
+func reserved(t *Tile) bool {
+	return t.Zone != nil && t.Zone.Type == Reserve
+}
+
+// waterCoverage returns the tiles reached by water: from each water tower, up to its Radius steps
+// through orthogonally connected developed tiles.
+func (game *GameState) waterCoverage() map[[2]int]bool {
+	watered := map[[2]int]bool{}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			st := game.Tiles[y][x].Structure
+			if st == nil || !structureSpecs[st.Type].Water {
+				continue
+			}
+			radius := structureSpecs[st.Type].Radius
+			dist := map[[2]int]int{{x, y}: 0}
+			q := [][2]int{{x, y}}
+			for len(q) > 0 {
+				cur := q[0]
+				q = q[1:]
+				watered[cur] = true
+				if dist[cur] == radius {
+					continue
+				}
+				for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+					if _, seen := dist[n]; seen || !game.inBounds(n[0], n[1]) || !developed(game.Tiles[n[1]][n[0]]) {
+						continue
+					}
+					dist[n] = dist[cur] + 1
+					q = append(q, n)
+				}
+			}
+		}
+	}
+	return watered
+}
+
+// updateWater refreshes Building.Watered and lets unwatered homes lose residents over time.
+// Construction and new residents both wait for water (see progressBuildings and growthTick).
+func (game *GameState) updateWater(changes *buildingChangeSet) {
+	watered := game.waterCoverage()
+	thirsty := game.Tick%waterLossTicks == 0
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			b := game.Tiles[y][x].Building
+			if b == nil {
+				continue
+			}
+			if w := watered[[2]int{x, y}]; b.Watered != w {
+				b.Watered = w
+				changes.add(x, y)
+			}
+			if thirsty && !b.Watered && b.Type == Residential && b.Residents > 0 {
+				b.Residents--
+				game.homeChanged(game.Tiles[y][x])
+				changes.add(x, y)
+			}
+		}
+	}
+}
+
+// ================= Power =================
+const (
+	powerBuildingLoad     = 1  // power units every finished building draws
+	powerOccupantsPerUnit = 2  // plus one unit per this many residents or employees
+	powerHappinessPenalty = 20 // happiness lost by a browned-out home
+)
+
+// powerLoad is the power units b draws.
+func powerLoad(b *Building) int {
+	return powerBuildingLoad + (b.Residents+b.Employees)/powerOccupantsPerUnit
+}
+
+// blackedOut reports whether b suffers the no-power penalties: it is browned out, which in a city
+// without plants every building is. Browned-out homes lose happiness, industry stops producing and
+// shops close.
+func (game *GameState) blackedOut(b *Building) bool {
+	return !b.Powered
+}
+
+// updatePower totals plant capacity against the load of finished buildings and powers them nearest
+// plant first; once demand outgrows supply, the buildings furthest from any plant are browned out.
+func (game *GameState) updatePower(changes *buildingChangeSet) {
+	type consumer struct {
+		x, y, dist int
+		b          *Building
+	}
+	var plants [][2]int
+	supply := 0
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			if st := game.Tiles[y][x].Structure; st != nil && st.Type == "power_plant" {
+				plants = append(plants, [2]int{x, y})
+				supply += st.Capacity
+			}
+		}
+	}
+	var consumers []consumer
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			b := game.Tiles[y][x].Building
+			if b == nil {
+				continue
+			}
+			dist := -1
+			for _, p := range plants {
+				if d := absInt(p[0]-x) + absInt(p[1]-y); dist < 0 || d < dist {
+					dist = d
+				}
+			}
+			consumers = append(consumers, consumer{x, y, dist, b})
+		}
+	}
+	sort.SliceStable(consumers, func(i, j int) bool { return consumers[i].dist < consumers[j].dist })
+	demand, used, brownout := 0, 0, false
+	for _, c := range consumers {
+		powered := false
+		if c.b.Final && c.b.AbandonPhase == 0 {
+			load := powerLoad(c.b)
+			demand += load
+			if !brownout && len(plants) > 0 && used+load <= supply {
+				used += load
+				powered = true
+			} else {
+				brownout = true
+			}
+		}
+		if c.b.Powered != powered {
+			c.b.Powered = powered
+			changes.add(c.x, c.y)
+		}
+	}
+	game.PowerSupply, game.PowerDemand = supply, demand
+}
+
+// ================= Education =================
+const (
+	maxEducation    = 3
+	educationTicks  = 10 // ticks per step of education gained under a school or lost without one
+	workforceRadius = 10 // manhattan distance from which an industry draws its workers
+)
+
+// updateEducation moves each residential building's EducationLevel one step toward maxEducation
+// every educationTicks while a school covers it, and one step back toward 0 while none does.
+func (game *GameState) updateEducation(changes *buildingChangeSet) {
+	if game.Tick%educationTicks != 0 {
+		return
+	}
+	schooled := map[*Tile]bool{}
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, _ int) {
+		if spec.Education {
+			schooled[t] = true
+		}
+	})
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			b := t.Building
+			if b == nil || !b.Final || b.Type != Residential {
+				continue
+			}
+			level := b.EducationLevel
+			if schooled[t] {
+				level = min(level+1, maxEducation)
+			} else {
+				level = max(level-1, 0)
+			}
+			if level != b.EducationLevel {
+				b.EducationLevel = level
+				changes.add(x, y)
+			}
+		}
+	}
+}
+
+// workforceEducation is the resident-weighted average education of housing within workforceRadius of (x,y).
+func (game *GameState) workforceEducation(x, y int) int {
+	residents, weighted := 0, 0
+	for dy := -workforceRadius; dy <= workforceRadius; dy++ {
+		for dx := -workforceRadius; dx <= workforceRadius; dx++ {
+			nx, ny := x+dx, y+dy
+			if absInt(dx)+absInt(dy) > workforceRadius || !game.inBounds(nx, ny) {
+				continue
+			}
+			if b := game.Tiles[ny][nx].Building; b != nil && b.Final && b.Type == Residential {
+				residents += b.Residents
+				weighted += b.Residents * b.EducationLevel
+			}
+		}
+	}
+	if residents == 0 {
+		return 0
+	}
+	return weighted / residents
+}
+
+// ================= Happiness =================
+const (
+	unhappyThreshold   = 30 // housing below this loses residents in employmentDemandAdjust
+	happyApplicantStep = 15 // each this many points of city happiness above 50 adds a growth applicant
+	serviceHappiness   = 5  // per service (water, fire cover, schooling) a home enjoys
+)
+
+// updateHappiness scores each occupied home 0..100 from land value, pollution, the city's
+// employment rate, road distance to the nearest job and service coverage, and sets the
+// citywide Happiness to the resident-weighted average.
+func (game *GameState) updateHappiness() {
+	employment := 0.0
+	if game.Population > 0 {
+		employment = float64(game.Employed) / float64(game.Population)
+	}
+	jobDist := game.jobDistances()
+	fire := game.fireCoverage()
+	total, residents := 0, 0
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			b := t.Building
+			if b == nil || !b.Final || b.Type != Residential || b.Residents == 0 {
+				t.Happiness = 0
+				continue
+			}
+			h := 50 + (t.LandValue-landValueBase)/2 - t.Pollution/2 + int((employment-0.5)*40)
+			commute := -1
+			for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				if v, ok := jobDist[[2]int{x + d[0], y + d[1]}]; ok && (commute < 0 || v < commute) {
+					commute = v
+				}
+			}
+			if commute < 0 {
+				h -= 15
+			} else {
+				h -= min(commute/3, 15)
+			}
+			for _, served := range []bool{b.Watered, fire[t], b.EducationLevel > 0} {
+				if served {
+					h += serviceHappiness
+				}
+			}
+			if game.blackedOut(b) {
+				h -= powerHappinessPenalty
+			}
+			t.Happiness = min(max(h, 1), 100) // 0 is reserved for "no one lives here"
+			total += t.Happiness * b.Residents
+			residents += b.Residents
+		}
+	}
+	game.Happiness = 0
+	if residents > 0 {
+		game.Happiness = total / residents
+	}
+}
+
+// jobDistances runs a multi-source BFS over roads from every road tile serving a job building,
+// returning each reachable road tile's distance to the nearest job. It searches backwards from the
+// jobs, so a step is taken only if a commuter could drive it towards them.
+func (game *GameState) jobDistances() map[[2]int]int {
+	dist := map[[2]int]int{}
+	q := [][2]int{}
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			b := game.Tiles[y][x].Building
+			if b == nil || !b.Final || b.Type == Residential {
+				continue
+			}
+			for _, d := range dirs {
+				key := [2]int{x + d[0], y + d[1]}
+				if _, seen := dist[key]; seen || !game.inBounds(key[0], key[1]) || game.Tiles[key[1]][key[0]].Road == nil {
+					continue
+				}
+				dist[key] = 0
+				q = append(q, key)
+			}
+		}
+	}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		for _, d := range dirs {
+			key := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if _, seen := dist[key]; seen || !game.inBounds(key[0], key[1]) || game.Tiles[key[1]][key[0]].Road == nil || !game.roadStepAllowed(key[0], key[1], cur[0], cur[1]) {
+				continue
+			}
+			dist[key] = dist[cur] + 1
+			q = append(q, key)
+		}
+	}
+	return dist
+}
+
+// ================= Crime =================
+const (
+	crimeUnemployment     = 40 // crime on built tiles at 100% unemployment, before other factors
+	highCrime             = 60 // at or above this, buildings decline faster (allocateLaborAndSupplies)
+	crimeLandValueDivisor = 4  // crime/this is taken off land value
+)
+
+// updateCrime sets crime on every built tile from the city's unemployment rate plus the tile's
+// unhappiness (the city's, for non-housing), less police station coverage.
+func (game *GameState) updateCrime() {
+	unemployment := 0.0
+	if game.Population > 0 {
+		unemployment = float64(max(game.Population-game.Employed, 0)) / float64(game.Population)
+	}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if t.Building == nil || !t.Building.Final {
+				t.Crime = 0
+				continue
+			}
+			happiness := game.Happiness
+			if t.Happiness > 0 {
+				happiness = t.Happiness
+			}
+			t.Crime = int(unemployment*crimeUnemployment) + max(50-happiness, 0)
+		}
+	}
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, falloff int) {
+		if spec.CrimeCut > 0 {
+			t.Crime -= spec.CrimeCut * falloff / (spec.Radius + 1)
+		}
+	})
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			t.Crime = min(max(t.Crime, 0), 100)
+		}
+	}
+}
+
+// broadcastCrime sends the crime grid as rows (y-major) of values, alongside the land-value layer.
+func (game *GameState) broadcastCrime() {
+	grid := make([][]int, game.Height)
+	for y := 0; y < game.Height; y++ {
+		row := make([]int, game.Width)
+		for x := 0; x < game.Width; x++ {
+			row[x] = game.Tiles[y][x].Crime
+		}
+		grid[y] = row
+	}
+	game.announce(EventCrime, struct {
+		Tick   int64   `json:"tick"`
+		Values [][]int `json:"values"`
+	}{game.Tick, grid})
+}
+
+// ================= Traffic Heatmap =================
+
+// The traffic heatmap is cumulative traffic per tile: every vehicle, shipment or citizen reaching a
+// tile adds to it, and it decays by heatDecay each tick, so it settles where traffic is steady and
+// shows the corridors that are busy day after day rather than right now.
+const heatDecay = 0.9
+
+// addHeat records w travellers reaching tile c.
+func (game *GameState) addHeat(c [2]int, w float64) {
+	if !game.inBounds(c[0], c[1]) {
+		return
+	}
+	if game.heat == nil {
+		game.heat = make([][]float64, game.Height)
+		for y := range game.heat {
+			game.heat[y] = make([]float64, game.Width)
+		}
+	}
+	game.heat[c[1]][c[0]] += w
+}
+
+// decayHeat fades the heatmap once per tick, dropping intensities too small to show.
+func (game *GameState) decayHeat() {
+	for _, row := range game.heat {
+		for x, v := range row {
+			if v *= heatDecay; v < 0.5 {
+				v = 0
+			}
+			row[x] = v
+		}
+	}
+}
+
+// broadcastHeatmap sends the heatmap as rows (y-major) of intensities rounded to whole numbers.
+func (game *GameState) broadcastHeatmap() {
+	grid := make([][]int, game.Height)
+	for y := 0; y < game.Height; y++ {
+		row := make([]int, game.Width)
+		if game.heat != nil {
+			for x := 0; x < game.Width; x++ {
+				row[x] = int(math.Round(game.heat[y][x]))
+			}
+		}
+		grid[y] = row
+	}
+	game.announce(EventTrafficHeatmap, struct {
+		Tick   int64   `json:"tick"`
+		Values [][]int `json:"values"`
+	}{game.Tick, grid})
+}
+
+// ================= Fire =================
+const (
+	fireIgnitionChance = 0.02 // per tick, that one randomly chosen building catches fire
+	fireSpreadChance   = 0.08 // per tick, per burning building and adjacent building
+	fireBurnTicks      = 6    // a fire still burning after this many ticks destroys the building
+	fireCoverEffect    = 4    // coverage divides ignition and spread by this
+)
+
+// Fire phases reported in disaster events
+const (
+	FireStarted      = "started"
+	FireSpread       = "spread"
+	FireExtinguished = "extinguished"
+	FireDestroyed    = "destroyed"
+)
+
+type DisasterEvent struct {
+	Kind  string `json:"kind"`
+	Phase string `json:"phase"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+}
+
+// fireCoverage marks the tiles within reach of a fire station.
+func (game *GameState) fireCoverage() map[*Tile]bool {
+	covered := map[*Tile]bool{}
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, _ int) {
+		if spec.FireCover {
+			covered[t] = true
+		}
+	})
+	return covered
+}
+
+// fireTick may start a fire on a random building, then advances every fire already burning: each
+// is put out if a fire engine has arrived, otherwise it may spread to adjacent buildings and
+// destroys its building after fireBurnTicks. Burning buildings with no engine on the way get one
+// dispatched from the nearest connected fire station. Changes go out as one disaster event.
+func (game *GameState) fireTick(changes *buildingChangeSet) {
+	covered := game.fireCoverage()
+	chance := func(p float64, t *Tile) float64 {
+		if covered[t] {
+			return p / fireCoverEffect
+		}
+		return p
+	}
+	var built, burning []*Tile
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if b := t.Building; b != nil && b.Final {
+				built = append(built, t)
+				if b.OnFire > 0 {
+					burning = append(burning, t)
+				}
+			}
+		}
+	}
+	events := []DisasterEvent{}
+	ignite := func(t *Tile, phase string) {
+		t.Building.OnFire = 1
+		changes.add(t.X, t.Y)
+		events = append(events, DisasterEvent{Kind: "fire", Phase: phase, X: t.X, Y: t.Y})
+	}
+	arrived := game.arrivedResponders
+	game.arrivedResponders = nil
+	for _, t := range burning {
+		if slices.Contains(arrived, [2]int{t.X, t.Y}) {
+			t.Building.OnFire = 0
+			changes.add(t.X, t.Y)
+			events = append(events, DisasterEvent{Kind: "fire", Phase: FireExtinguished, X: t.X, Y: t.Y})
+			continue
+		}
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			nx, ny := t.X+d[0], t.Y+d[1]
+			if !game.inBounds(nx, ny) {
+				continue
+			}
+			n := game.Tiles[ny][nx]
+			if b := n.Building; b != nil && b.Final && b.OnFire == 0 && game.rng.Float64() < chance(fireSpreadChance, n) {
+				ignite(n, FireSpread)
+			}
+		}
+		if b := t.Building; b.OnFire >= fireBurnTicks {
+			t.Building = nil
+			game.markTile(t)
+			game.logTile(t.X, t.Y, "building", "removed", "fire", "")
+			events = append(events, DisasterEvent{Kind: "fire", Phase: FireDestroyed, X: t.X, Y: t.Y})
+		} else {
+			b.OnFire++
+		}
+		changes.add(t.X, t.Y)
+	}
+	if len(built) > 0 {
+		t := built[game.rng.Intn(len(built))]
+		if t.Building != nil && t.Building.OnFire == 0 && game.rng.Float64() < chance(fireIgnitionChance, t) {
+			ignite(t, FireStarted)
+		}
+	}
+	game.dispatchResponders(VehicleFire, "fire_station", func(b *Building) bool { return b.OnFire > 0 })
+	if len(events) > 0 {
+		game.announce(EventDisaster, struct {
+			Events []DisasterEvent `json:"events"`
+		}{events})
+	}
+}
+
+// ================= Emergency Services =================
+const emergencySpeed = 3.0 // responders ignore congestion
+
+// dispatchResponders sends a vehicle of kind from the nearest station (by road path length) to
+// every building needing it that has no responder already on the way. Incidents no station can
+// reach by road get nothing and are left to worsen.
+func (game *GameState) dispatchResponders(kind, station string, needs func(*Building) bool) {
+	enRoute := map[[2]int]bool{}
+	for _, v := range game.Vehicles {
+		if v.Kind == kind {
+			enRoute[v.Target] = true
+		}
+	}
+	var stations [][2]int
+	var incidents [][2]int
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if t.Structure != nil && t.Structure.Type == station {
+				if rx, ry, ok := game.adjacentRoad(x, y); ok {
+					stations = append(stations, [2]int{rx, ry})
+				}
+			}
+			if b := t.Building; b != nil && needs(b) && !enRoute[[2]int{x, y}] {
+				incidents = append(incidents, [2]int{x, y})
+			}
+		}
+	}
+	for _, inc := range incidents {
+		ix, iy, ok := game.adjacentRoad(inc[0], inc[1])
+		if !ok {
+			continue
+		}
+		var best [][2]int
+		for _, st := range stations {
+			if p := game.roadPath(st, [2]int{ix, iy}, 400); len(p) > 0 && (best == nil || len(p) < len(best)) {
+				best = p
+			}
+		}
+		if best == nil {
+			continue
+		}
+		game.vehicleSeq++
+		game.Vehicles = append(game.Vehicles, &Vehicle{ID: game.vehicleSeq, X: float64(best[0][0]), Y: float64(best[0][1]), Path: best[1:], Kind: kind, Target: inc})
+	}
+}
+
+// ================= Land Value =================
+const (
+	landValueBase           = 40
+	landValueMax            = 100
+	landValueRadius         = 3
+	reserveLandValue        = 2  // per unit of neighbor weight, as for water
+	waterfrontLandValue     = 10 // extra for a tile orthogonally beside water
+	landValueBroadcastTicks = 10 // emit the land-value layer every N ticks
+	landTaxDivisor          = 100
+	pollutionRadius         = 4
+	industrialPollution     = 16 // pollution at the source tile, fading with distance
+)
+
+// updatePollution recomputes per-tile pollution emitted by active industrial buildings and coal plants.
+func (game *GameState) updatePollution() {
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			game.Tiles[y][x].Pollution = 0
+		}
+	}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			b := game.Tiles[y][x].Building
+			if b == nil || !b.Final || b.Type != Industrial || b.AbandonPhase > 0 {
+				continue
+			}
+			for dy := -pollutionRadius; dy <= pollutionRadius; dy++ {
+				for dx := -pollutionRadius; dx <= pollutionRadius; dx++ {
+					nx, ny := x+dx, y+dy
+					if !game.inBounds(nx, ny) {
+						continue
+					}
+					d := absInt(dx) + absInt(dy)
+					if d > pollutionRadius {
+						continue
+					}
+					game.Tiles[ny][nx].Pollution += industrialPollution * (pollutionRadius + 1 - d) / (pollutionRadius + 1)
+				}
+			}
+		}
+	}
+	// Polluting structures (coal plants) add theirs before greenery absorbs any
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, falloff int) {
+		t.Pollution += spec.Pollution * falloff / (spec.Radius + 1)
+	})
+	// Greenery structures (parks) absorb nearby pollution
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, falloff int) {
+		if spec.PollutionCut == 0 {
+			return
+		}
+		t.Pollution -= spec.PollutionCut * falloff / (spec.Radius + 1)
+		if t.Pollution < 0 {
+			t.Pollution = 0
+		}
+	})
+}
+
+// forEachStructureEffect calls fn for every tile within the radius of each placed structure with
+// a non-zero radius. falloff is Radius+1 at the structure tile and 1 at the edge.
+func (game *GameState) forEachStructureEffect(fn func(t *Tile, spec structureSpec, falloff int)) {
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			st := game.Tiles[y][x].Structure
+			if st == nil {
+				continue
+			}
+			spec := st.spec()
+			if spec.Radius == 0 {
+				continue
+			}
+			for dy := -spec.Radius; dy <= spec.Radius; dy++ {
+				for dx := -spec.Radius; dx <= spec.Radius; dx++ {
+					nx, ny := x+dx, y+dy
+					d := absInt(dx) + absInt(dy)
+					if d > spec.Radius || !game.inBounds(nx, ny) {
+						continue
+					}
+					fn(game.Tiles[ny][nx], spec, spec.Radius+1-d)
+				}
+			}
+		}
+	}
+}
+
+// updateLandValue recomputes pollution and then per-tile land value: nearby water, greenery and road
+// access raise it, pollution and directly adjacent industry lower it.
+func (game *GameState) updateLandValue() {
+	game.updatePollution()
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			game.Tiles[y][x].LandValue = game.landValueAt(x, y)
+		}
+	}
+	game.forEachStructureEffect(func(t *Tile, spec structureSpec, falloff int) {
+		if spec.LandValueBonus == 0 || t.Terrain == "water" {
+			return
+		}
+		t.LandValue = clampLandValue(t.LandValue + spec.LandValueBonus*falloff/(spec.Radius+1))
+	})
+}
+
+func clampLandValue(v int) int {
+	if v < 0 {
+		return 0
+	} else if v > landValueMax {
+		return landValueMax
+	}
+	return v
+}
+
+func (game *GameState) landValueAt(x, y int) int {
+	t := game.Tiles[y][x]
+	if t.Terrain == "water" {
+		return 0
+	}
+	v := landValueBase
+	roadAccess := false
+	for dy := -landValueRadius; dy <= landValueRadius; dy++ {
+		for dx := -landValueRadius; dx <= landValueRadius; dx++ {
+			nx, ny := x+dx, y+dy
+			d := absInt(dx) + absInt(dy)
+			if d == 0 || d > landValueRadius || !game.inBounds(nx, ny) {
+				continue
+			}
+			n := game.Tiles[ny][nx]
+			weight := landValueRadius + 1 - d // closer neighbors matter more
+			if n.Terrain == "water" {
+				v += 2 * weight
+			}
+			if n.Foliage != "" {
+				v += weight
+			}
+			if reserved(n) {
+				v += reserveLandValue * weight
+			}
+			if d == 1 {
+				if n.Road != nil {
+					roadAccess = true
+				}
+				if n.Building != nil && n.Building.Type == Industrial && n.Building.AbandonPhase == 0 {
+					v -= 10
+				}
+			}
+		}
+	}
+	if roadAccess {
+		v += 10
+	}
+	if game.waterfront(x, y) {
+		v += waterfrontLandValue
+	}
+	return clampLandValue(v - t.Pollution - t.Crime/crimeLandValueDivisor)
+}
+
+// waterfront reports whether (x,y) lies orthogonally beside water.
+func (game *GameState) waterfront(x, y int) bool {
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if nx, ny := x+d[0], y+d[1]; game.inBounds(nx, ny) && game.Tiles[ny][nx].Terrain == "water" {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastLandValue sends the land-value grid as rows (y-major) of values.
+func (game *GameState) broadcastLandValue() {
+	grid := make([][]int, game.Height)
+	for y := 0; y < game.Height; y++ {
+		row := make([]int, game.Width)
+		for x := 0; x < game.Width; x++ {
+			row[x] = game.Tiles[y][x].LandValue
+		}
+		grid[y] = row
+	}
+	game.announce(EventLandValue, struct {
+		Tick   int64   `json:"tick"`
+		Values [][]int `json:"values"`
+	}{game.Tick, grid})
+}
+
+const vehicleSpeed = 2.0
+const truckSpeed = 1.4
+const citizenSpeed = 1.5
+const goodsSpeed = 2.4
+
+const (
+	congestionThreshold        = 2    // vehicles a road tile carries before slowing down
+	highwayCongestionThreshold = 5    // highways carry more before slowing down
+	minCongestionFactor        = 0.25 // floor on the congestion speed multiplier
+	highwaySpeedFactor         = 2.0  // vehicle and goods speed multiplier on highway tiles
+	highwayStepCost            = 0.5  // A* cost of a highway tile relative to a local road
+	truckCongestionWeight      = 2    // a truck occupies as much road as this many cars
+	goodsPerTruck              = 2    // active goods shipments that keep one truck on the road
+)
+
+// ================= Entity Limits =================
+
+// Caps on moving entities are set for the default 64x64 map and scale with map area, so small maps
+// stay light and large ones cannot balloon. On top of them, vehicles, goods and citizen groups
+// together never exceed entityCeiling: the area-scaled maxEntitiesPerMap, and never more than
+// maxEntities (CITYSIM_MAX_ENTITIES) whatever the map size. Emergency responders are exempt so an
+// incident is never left unanswered.
+const (
+	capReferenceArea  = 64 * 64
+	maxCars           = 120
+	maxTrucks         = 40
+	maxGoods          = 300
+	maxCitizenGroups  = 200
+	maxEntitiesPerMap = 1000
+)
+
+var maxEntities = int(envFloat("CITYSIM_MAX_ENTITIES", 2000))
+
+// areaCap scales a cap set for the reference map to this map's area, keeping at least one.
+func (game *GameState) areaCap(n int) int {
+	return max(n*game.Width*game.Height/capReferenceArea, 1)
+}
+
+// entityCeiling is the most vehicles, goods and citizen groups the room holds at once.
+func (game *GameState) entityCeiling() int {
+	return min(game.areaCap(maxEntitiesPerMap), maxEntities)
+}
+
+// entityCount is the number of vehicles, goods and citizen groups in the room.
+func (game *GameState) entityCount() int {
+	return len(game.Vehicles) + len(game.GoodsIC) + len(game.GoodsCC) + len(game.CitizenGroups)
+}
+
+// entityRoom reports whether another entity fits under the ceiling.
+func (game *GameState) entityRoom() bool {
+	return game.entityCount() < game.entityCeiling()
+}
+
+func (r *Room) trafficLoop() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if !r.idle() { // the idle time is skipped, not made up in the first frame after
+			r.guard("traffic frame", func() { r.input(journalEntry{Kind: journalFrame, Elapsed: now.Sub(last)}) })
+		}
+		last = now
+	}
+}
+
+// trafficFrame advances vehicles, citizens and goods by elapsed wall time and broadcasts them.
+func (r *Room) trafficFrame(elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	// scale wall time by game speed; paused rooms neither move nor spawn
+	dt := elapsed.Seconds() * float64(game.Speed)
+	frame := 100 * time.Millisecond * time.Duration(game.Speed)
+	game.updateCongestion()
+	game.updateTraffic(dt)
+	game.updateCitizens(dt)
+	game.syncCommuterCars()
+	game.updateGoods(dt)
+	// loops (not ifs) so faster speeds spawn as often per game second as 1x does
+	r.spawnAcc += frame
+	for r.spawnAcc >= time.Second {
+		r.spawnAcc -= time.Second
+		game.spawnVehicles()
+	}
+	r.citizenSpawnAcc += frame
+	for r.citizenSpawnAcc >= 200*time.Millisecond { // much faster citizen spawning
+		r.citizenSpawnAcc -= 200 * time.Millisecond
+		game.spawnCitizenGroups()
+	}
+	r.goodsSpawnAcc += frame
+	for r.goodsSpawnAcc >= 1500*time.Millisecond { // spawn goods roughly every 1.5s
+		r.goodsSpawnAcc -= 1500 * time.Millisecond
+		game.spawnGoodsShipments()
+	}
+	game.broadcastTraffic()
+	game.announceRoadNetwork(elapsed)
+	r.recordTrafficMetrics()
+}
+func (game *GameState) updateTraffic(dt float64) {
+	if len(game.Vehicles) == 0 {
+		return
+	}
+	kept := game.Vehicles[:0]
+	for _, v := range game.Vehicles {
+		v.Age += dt
+		if v.GroupID != 0 { // positioned by syncCommuterCars
+			kept = append(kept, v)
+			continue
+		}
+		var remain float64
+		switch {
+		case emergencyVehicle(v.Kind):
+			remain = emergencySpeed * dt * game.roadSpeedFactor(v.X, v.Y)
+		case v.Kind == VehicleTruck:
+			remain = truckSpeed * dt * game.congestionFactor(v.X, v.Y) * game.roadSpeedFactor(v.X, v.Y)
+		case v.Kind == VehicleBus:
+			remain = busSpeed * dt * game.congestionFactor(v.X, v.Y) * game.roadSpeedFactor(v.X, v.Y)
+		default:
+			remain = vehicleSpeed * dt * game.congestionFactor(v.X, v.Y) * game.roadSpeedFactor(v.X, v.Y)
+		}
+		blocked, rerouted := false, false
+		for remain > 0 && v.PathIndex < len(v.Path) {
+			tgt := v.Path[v.PathIndex]
+			if !game.pathStepAllowed(v.X, v.Y, tgt) { // road changed under us (e.g. made one-way)
+				if !rerouted && v.Kind != VehicleBus && game.repath(&v.X, &v.Y, &v.Path, &v.PathIndex) {
+					rerouted = true
+					continue
+				}
+				blocked = true
+				break
+			}
+			tx, ty := float64(tgt[0]), float64(tgt[1])
+			dx, dy := tx-v.X, ty-v.Y
+			dist := abs(dx) + abs(dy)
+			if dist <= remain {
+				v.from, v.fromDir = tgt, [2]float64{axisStep(dx), axisStep(dy)}
+				v.X, v.Y = tx, ty
+				v.PathIndex++
+				game.addHeat(tgt, 1)
+				remain -= dist
+				if v.Kind == VehicleBus { // buses loop forever, stopping for riders on the way
+					v.PathIndex %= len(v.Path)
+					game.busAt(v, tgt)
+				}
+			} else {
+				if dx != 0 {
+					v.X += remain * sign(dx)
+				} else if dy != 0 {
+					v.Y += remain * sign(dy)
+				}
+				remain = 0
+			}
+		}
+		if !blocked && v.PathIndex < len(v.Path) {
+			_, _, v.Heading = game.vehiclePose(v)
+			kept = append(kept, v)
+		} else if !blocked && emergencyVehicle(v.Kind) {
+			game.arrivedResponders = append(game.arrivedResponders, v.Target)
+		}
+	}
+	game.Vehicles = kept
+}
+
+// vehicleFadeIn is how many game seconds a new vehicle takes to fade in on the client.
+const vehicleFadeIn = 0.5
+
+// vehiclePose is where to draw v and which way it faces, in degrees clockwise from east (y grows
+// south). Vehicles move tile to tile in straight lines; for drawing, each turn is rounded into a
+// quadratic curve from the middle of the edge the vehicle enters the corner tile by to the middle of
+// the edge it leaves by, so it neither cuts through the tile's corner nor snaps its heading.
+// Commuter cars follow their group and keep the heading syncCommuterCars gives them.
+func (game *GameState) vehiclePose(v *Vehicle) (x, y, heading float64) {
+	if v.GroupID != 0 || v.PathIndex >= len(v.Path) {
+		return v.X, v.Y, v.Heading
+	}
+	next := v.Path[v.PathIndex]
+	nx, ny := float64(next[0]), float64(next[1])
+	in := [2]float64{axisStep(nx - v.X), axisStep(ny - v.Y)}
+	if in == ([2]float64{}) {
+		return v.X, v.Y, v.Heading
+	}
+	// leaving the corner tile just reached
+	if d := abs(v.X-float64(v.from[0])) + abs(v.Y-float64(v.from[1])); d < 0.5 && turns(v.fromDir, in) {
+		return cornerPose(v.from, v.fromDir, in, 0.5+d)
+	}
+	// approaching a corner at next
+	if dist := abs(nx-v.X) + abs(ny-v.Y); dist <= 0.5 {
+		i := v.PathIndex + 1
+		if v.Kind == VehicleBus {
+			i %= len(v.Path)
+		}
+		if i < len(v.Path) {
+			after := v.Path[i]
+			out := [2]float64{axisStep(float64(after[0] - next[0])), axisStep(float64(after[1] - next[1]))}
+			if turns(in, out) {
+				return cornerPose(next, in, out, 0.5-dist)
+			}
+		}
+	}
+	return v.X, v.Y, math.Atan2(in[1], in[0]) * 180 / math.Pi
+}
+
+// turns reports whether moving in then out, both axis directions, is a 90 degree turn.
+func turns(in, out [2]float64) bool {
+	return in[0]*out[1]-in[1]*out[0] != 0 && (in[0] == 0 || in[1] == 0) && (out[0] == 0 || out[1] == 0)
+}
+
+// cornerPose is the point at t (0..1) along the curve rounding the corner tile c from direction in
+// to direction out, and its heading.
+func cornerPose(c [2]int, in, out [2]float64, t float64) (x, y, heading float64) {
+	cx, cy := float64(c[0]), float64(c[1])
+	x0, y0 := cx-in[0]/2, cy-in[1]/2
+	x2, y2 := cx+out[0]/2, cy+out[1]/2
+	u := 1 - t
+	x = u*u*x0 + 2*u*t*cx + t*t*x2
+	y = u*u*y0 + 2*u*t*cy + t*t*y2
+	heading = math.Atan2(u*in[1]+t*out[1], u*in[0]+t*out[0]) * 180 / math.Pi
+	return x, y, heading
+}
+
+// axisStep is the sign of v, 0 for 0.
+func axisStep(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// updateCongestion counts vehicles occupying each road tile for this traffic frame, trucks and
+// buses counting truckCongestionWeight times.
+func (game *GameState) updateCongestion() {
+	c := make(map[[2]int]int, len(game.Vehicles))
+	for _, v := range game.Vehicles {
+		w := 1
+		if v.Kind == VehicleTruck || v.Kind == VehicleBus {
+			w = truckCongestionWeight
+		}
+		c[[2]int{int(v.X + 0.5), int(v.Y + 0.5)}] += w
+	}
+	game.Congestion = c
+}
+
+// congestionFactor returns the speed multiplier for an entity at (x,y): 1 on free-flowing tiles,
+// falling inversely with the vehicle count once it exceeds congestionThreshold.
+func (game *GameState) congestionFactor(x, y float64) float64 {
+	tx, ty := int(x+0.5), int(y+0.5)
+	n := game.Congestion[[2]int{tx, ty}]
+	threshold := congestionThreshold
+	if game.inBounds(tx, ty) {
+		if r := game.Tiles[ty][tx].Road; r != nil && r.Kind == RoadHighway {
+			threshold = highwayCongestionThreshold
+		}
+	}
+	if n <= threshold {
+		return 1
+	}
+	f := float64(threshold) / float64(n)
+	if f < minCongestionFactor {
+		f = minCongestionFactor
+	}
+	return f
+}
+
+// pathStepAllowed checks one-way rules for an entity at (x,y) heading to the next path tile.
+// Only whole-tile steps between two road tiles are checked; partial progress is already committed.
+func (game *GameState) pathStepAllowed(x, y float64, tgt [2]int) bool {
+	fx, fy := int(x+0.5), int(y+0.5)
+	if float64(fx) != x || float64(fy) != y || absInt(tgt[0]-fx)+absInt(tgt[1]-fy) != 1 {
+		return true
+	}
+	if !game.inBounds(fx, fy) || !game.inBounds(tgt[0], tgt[1]) || game.Tiles[fy][fx].Road == nil || game.Tiles[tgt[1]][tgt[0]].Road == nil {
+		return true
+	}
+	return game.roadStepAllowed(fx, fy, tgt[0], tgt[1])
+}
+
+// Commuter cars: with commuterCars (CITYSIM_COMMUTER_CARS, default on) every citizen group on
+// its way to or from work drives a car linked by GroupID, and random cars are cut back to a small
+// ambient baseline of one per ambientCarShare residents, at most maxAmbientCars.
+var commuterCars = os.Getenv("CITYSIM_COMMUTER_CARS") != "0"
+
+const (
+	ambientCarShare = 100
+	maxAmbientCars  = 30
+)
+
+// syncCommuterCars moves each commuter car to its group, removes cars whose group has arrived,
+// gone or takes the bus, and adds cars for groups that just set off.
+func (game *GameState) syncCommuterCars() {
+	if !commuterCars {
+		return
+	}
+	travelling := make(map[int64]*CitizenGroup)
+	for _, g := range game.CitizenGroups {
+		if (g.State == "outbound" || g.State == "return" || g.State == "inbound" || g.State == "visiting") && g.Transit == nil {
+			travelling[g.ID] = g
+		}
+	}
+	kept := game.Vehicles[:0]
+	for _, v := range game.Vehicles {
+		if v.GroupID == 0 {
+			kept = append(kept, v)
+			continue
+		}
+		if g := travelling[v.GroupID]; g != nil {
+			if g.X != v.X || g.Y != v.Y {
+				v.Heading = math.Atan2(g.Y-v.Y, g.X-v.X) * 180 / math.Pi
+			}
+			v.X, v.Y = g.X, g.Y
+			kept = append(kept, v)
+			delete(travelling, v.GroupID)
+		}
+	}
+	game.Vehicles = kept
+	for _, g := range game.CitizenGroups { // in group order, so IDs are deterministic
+		if travelling[g.ID] != nil && game.entityRoom() {
+			game.vehicleSeq++
+			game.Vehicles = append(game.Vehicles, &Vehicle{ID: game.vehicleSeq, X: g.X, Y: g.Y, Kind: VehicleCar, GroupID: g.ID})
+		}
+	}
+}
+
+// spawnVehicles tops up cars in proportion to population and trucks in proportion to the goods
+// shipments in flight. Cars drive between random roads; trucks between industrial and commercial
+// access roads.
+func (game *GameState) spawnVehicles() {
+	cars, trucks := 0, 0
+	for _, v := range game.Vehicles {
+		switch {
+		case v.Kind == VehicleTruck:
+			trucks++
+		case v.GroupID != 0: // commuter cars are not topped up here
+		case v.Kind == VehicleCar, v.Kind == "":
+			cars++
+		}
+	}
+	carTarget := min(game.Population/25, game.areaCap(maxCars))
+	if commuterCars {
+		carTarget = min(game.Population/ambientCarShare, game.areaCap(maxAmbientCars))
+	}
+	carDeficit := carTarget - cars
+	roadGoods := len(game.GoodsCC)
+	for _, s := range game.GoodsIC {
+		if !s.Train {
+			roadGoods++
+		}
+	}
+	truckDeficit := min(roadGoods/goodsPerTruck, game.areaCap(maxTrucks)) - trucks
+	if carDeficit <= 0 && truckDeficit <= 0 {
+		return
+	}
+	idx := game.tiles()
+	roads := make([][2]int, 0, len(idx.roads))
+	depots := make([][2]int, 0) // roads serving industry or commerce
+	for _, t := range idx.roads {
+		roads = append(roads, [2]int{t.X, t.Y})
+	}
+	for _, t := range idx.buildings {
+		if b := t.Building; b != nil && b.Final && (b.Type == Industrial || b.Type == Commercial) {
+			if rx, ry, ok := game.adjacentRoad(t.X, t.Y); ok {
+				depots = append(depots, [2]int{rx, ry})
+			}
+		}
+	}
+	spawn := func(kind string, ends [][2]int, n int) {
+		if len(ends) < 2 {
+			return
+		}
+		for i := 0; i < min(n, 8) && game.entityRoom(); i++ {
+			a := ends[game.rng.Intn(len(ends))]
+			b := ends[game.rng.Intn(len(ends))]
+			if a == b {
+				continue
+			}
+			path := game.roadPath(a, b, 200)
+			if len(path) < 2 {
+				continue
+			}
+			game.vehicleSeq++
+			v := &Vehicle{ID: game.vehicleSeq, X: float64(path[0][0]), Y: float64(path[0][1]), Path: path[1:], Kind: kind}
+			game.Vehicles = append(game.Vehicles, v)
+		}
+	}
+	spawn(VehicleCar, roads, carDeficit)
+	spawn(VehicleTruck, depots, truckDeficit)
+}
+
+// TrafficEntity is one moving thing in a traffic update.
+type TrafficEntity struct {
+	ID      int64   `json:"id"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Kind    string  `json:"kind,omitempty"`    // vehicles, and "train" for goods moving by rail
+	GroupID int64   `json:"groupId,omitempty"` // commuter cars: the citizen group inside
+	Heading float64 `json:"heading,omitempty"` // vehicles: degrees clockwise from east, 0 when omitted
+	FadeIn  float64 `json:"fadeIn,omitempty"`  // vehicles: fraction of the spawn fade-in left, 1 = just spawned
+}
+
+// TrafficCongestion is a road tile shared by more than one vehicle.
+type TrafficCongestion struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Count int `json:"count"`
+}
+
+// Delta traffic (CITYSIM_TRAFFIC_DELTA=1): between keyframes, which are ordinary traffic updates
+// sent every trafficKeyframeEvery frames, a traffic_delta lists per entity class only what spawned,
+// what moved more than trafficDeltaEpsilon tiles since it was last sent, and the ids that despawned.
+// Frames where nothing changed are not sent at all.
+var trafficDelta = os.Getenv("CITYSIM_TRAFFIC_DELTA") == "1"
+
+const (
+	trafficKeyframeEvery = 50 // 5s at 10 Hz
+	trafficDeltaEpsilon  = 0.05
+)
+
+// TrafficClassDelta is the change in one entity class since the previous traffic frame.
+type TrafficClassDelta struct {
+	Spawned   []TrafficEntity `json:"spawned,omitempty"`
+	Moved     []TrafficEntity `json:"moved,omitempty"`
+	Despawned []int64         `json:"despawned,omitempty"`
+}
+
+func (d TrafficClassDelta) empty() bool {
+	return len(d.Spawned) == 0 && len(d.Moved) == 0 && len(d.Despawned) == 0
+}
+
+// trafficDiff compares one class with the positions last sent, updating sent to match.
+func trafficDiff(cur []TrafficEntity, sent map[int64]TrafficEntity) TrafficClassDelta {
+	var d TrafficClassDelta
+	seen := make(map[int64]bool, len(cur))
+	for _, e := range cur {
+		seen[e.ID] = true
+		prev, ok := sent[e.ID]
+		switch {
+		case !ok:
+			d.Spawned = append(d.Spawned, e)
+		case abs(e.X-prev.X)+abs(e.Y-prev.Y) > trafficDeltaEpsilon:
+			d.Moved = append(d.Moved, e)
+		default:
+			continue
+		}
+		sent[e.ID] = e
+	}
+	for id := range sent {
+		if !seen[id] {
+			d.Despawned = append(d.Despawned, id)
+			delete(sent, id)
+		}
+	}
+	slices.Sort(d.Despawned)
+	return d
+}
+
+// broadcastTraffic sends every moving entity to all clients. Once a frame with nothing moving has
+// been sent, identical idle frames are skipped until something moves or a group comes or goes.
+func (game *GameState) broadcastTraffic() {
+	idle := len(game.Vehicles) == 0 && len(game.GoodsIC) == 0 && len(game.GoodsCC) == 0
+	for _, g := range game.CitizenGroups {
+		if g.State != "working" && g.State != "shopping" {
+			idle = false
+			break
+		}
+	}
+	if idle && game.trafficIdleGroups == len(game.CitizenGroups) {
+		return
+	}
+	game.trafficIdleGroups = -1
+	if idle {
+		game.trafficIdleGroups = len(game.CitizenGroups)
+	}
+	out := make([]TrafficEntity, len(game.Vehicles))
+	for i, v := range game.Vehicles {
+		kind := v.Kind
+		if kind == "" {
+			kind = VehicleCar
+		}
+		x, y, heading := game.vehiclePose(v)
+		out[i] = TrafficEntity{ID: v.ID, X: x, Y: y, Kind: kind, GroupID: v.GroupID, Heading: heading, FadeIn: max(1-v.Age/vehicleFadeIn, 0)}
+	}
+	goodsIC := make([]TrafficEntity, len(game.GoodsIC))
+	for i, g := range game.GoodsIC {
+		goodsIC[i] = TrafficEntity{ID: g.ID, X: g.X, Y: g.Y}
+		if g.Train {
+			goodsIC[i].Kind = "train"
+		}
+	}
+	goodsCC := make([]TrafficEntity, len(game.GoodsCC))
+	for i, g := range game.GoodsCC {
+		goodsCC[i] = TrafficEntity{ID: g.ID, X: g.X, Y: g.Y}
+	}
+	// Citizens: include all; workers shown at destination tile center
+	citAll := make([]TrafficEntity, 0, len(game.CitizenGroups))
+	for _, g := range game.CitizenGroups {
+		if g.State == "working" || g.State == "shopping" {
+			// snap to destination tile center (x+0.5,y+0.5)
+			citAll = append(citAll, TrafficEntity{ID: g.ID, X: float64(g.DestX) + 0.5, Y: float64(g.DestY) + 0.5})
+		} else {
+			citAll = append(citAll, TrafficEntity{ID: g.ID, X: g.X, Y: g.Y})
+		}
+	}
+	// Congestion: only tiles shared by more than one vehicle, so the UI can color busy roads
+	congestion := make([]TrafficCongestion, 0)
+	for k, n := range game.Congestion {
+		if n > 1 {
+			congestion = append(congestion, TrafficCongestion{X: k[0], Y: k[1], Count: n})
+		}
+	}
+	if trafficDelta {
+		classes := [][]TrafficEntity{out, goodsIC, goodsCC, citAll}
+		game.trafficFrame++
+		keyframe := game.trafficFrame%trafficKeyframeEvery == 0
+		if keyframe || game.trafficSent == nil { // the full update below replaces everything sent
+			game.trafficSent = make([]map[int64]TrafficEntity, len(classes))
+			for i := range game.trafficSent {
+				game.trafficSent[i] = map[int64]TrafficEntity{}
+			}
+		}
+		deltas := make([]TrafficClassDelta, len(classes))
+		changed := false
+		for i, cur := range classes {
+			deltas[i] = trafficDiff(cur, game.trafficSent[i])
+			changed = changed || !deltas[i].empty()
+		}
+		if !keyframe {
+			if changed {
+				game.announce(EventTrafficDelta, struct {
+					TS         int64               `json:"ts"`
+					Vehicles   TrafficClassDelta   `json:"vehicles"`
+					GoodsIC    TrafficClassDelta   `json:"goodsIC"`
+					GoodsCC    TrafficClassDelta   `json:"goodsCC"`
+					Citizens   TrafficClassDelta   `json:"citizens"`
+					Congestion []TrafficCongestion `json:"congestion"`
+				}{time.Now().UnixNano(), deltas[0], deltas[1], deltas[2], deltas[3], congestion})
+			}
+			return
+		}
+	}
+	game.announce(EventTrafficUpdate, struct {
+		TS         int64               `json:"ts"`
+		Vehicles   []TrafficEntity     `json:"vehicles"`
+		GoodsIC    []TrafficEntity     `json:"goodsIC"`
+		GoodsCC    []TrafficEntity     `json:"goodsCC"`
+		Citizens   []TrafficEntity     `json:"citizens"`
+		Congestion []TrafficCongestion `json:"congestion"`
+	}{time.Now().UnixNano(), out, goodsIC, goodsCC, citAll, congestion})
+}
+
+// roadPath finds the cheapest road route from start to goal using A*. Highway tiles cost less to
+// traverse than local roads, so routes prefer highways when the detour pays off. limit caps the
+// number of tiles discovered before giving up.
+func (game *GameState) roadPath(start, goal [2]int, limit int) [][2]int {
+	if start == goal {
+		return [][2]int{start}
+	}
+	heuristic := func(p [2]int) float64 {
+		return float64(absInt(p[0]-goal[0])+absInt(p[1]-goal[1])) * highwayStepCost
+	}
+	open := &pathHeap{{pos: start, f: heuristic(start)}}
+	prev := map[[2]int][2]int{}
+	cost := map[[2]int]float64{start: 0}
+	closed := map[[2]int]bool{}
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for open.Len() > 0 && len(prev) < limit {
+		cur := heap.Pop(open).(pathItem).pos
+		if cur == goal {
+			break
+		}
+		if closed[cur] {
+			continue
+		}
+		closed[cur] = true
+		for _, d := range dirs {
+			nx, ny := cur[0]+d[0], cur[1]+d[1]
+			if !game.inBounds(nx, ny) {
+				continue
+			}
+			if game.Tiles[ny][nx].Road == nil || !game.roadStepAllowed(cur[0], cur[1], nx, ny) {
+				continue
+			}
+			key := [2]int{nx, ny}
+			c := cost[cur] + roadStepCost(game.Tiles[ny][nx].Road)
+			if old, seen := cost[key]; !seen || c < old {
+				cost[key] = c
+				prev[key] = cur
+				heap.Push(open, pathItem{pos: key, f: c + heuristic(key)})
+			}
+		}
+	}
+	if _, ok := prev[goal]; !ok {
+		return [][2]int{}
+	}
+	path := make([][2]int, 0)
+	cur := goal
+	for cur != start {
+		path = append(path, cur)
+		cur = prev[cur]
+	}
+	path = append(path, start)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+type pathItem struct {
+	pos [2]int
+	f   float64
+}
+
+// pathHeap is a min-heap of A* frontier entries ordered by estimated total cost.
+type pathHeap []pathItem
+
+func (h pathHeap) Len() int            { return len(h) }
+func (h pathHeap) Less(i, j int) bool  { return h[i].f < h[j].f }
+func (h pathHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x interface{}) { *h = append(*h, x.(pathItem)) }
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// roadStepCost is the A* cost of entering a road tile.
+func roadStepCost(r *Road) float64 {
+	if r.Kind == RoadHighway {
+		return highwayStepCost
+	}
+	return 1
+}
+
+// roadSpeedFactor returns the speed multiplier for the road under (x,y).
+func (game *GameState) roadSpeedFactor(x, y float64) float64 {
+	tx, ty := int(x+0.5), int(y+0.5)
+	if game.inBounds(tx, ty) {
+		if r := game.Tiles[ty][tx].Road; r != nil && r.Kind == RoadHighway {
+			return highwaySpeedFactor
+		}
+	}
+	return 1
+}
+
+// roadStepAllowed reports whether moving one tile from (fx,fy) to (tx,ty) is permitted by one-way
+// roads: neither the tile being left nor the one being entered may point against the move.
+// Crossing a one-way road perpendicular to its direction is allowed.
+func (game *GameState) roadStepAllowed(fx, fy, tx, ty int) bool {
+	step := [2]int{tx - fx, ty - fy}
+	against := func(x, y int) bool {
+		if !game.inBounds(x, y) {
+			return false
+		}
+		r := game.Tiles[y][x].Road
+		if r == nil || r.Direction == DirNone {
+			return false
+		}
+		v := roadDirectionVectors[r.Direction]
+		return v[0] == -step[0] && v[1] == -step[1]
+	}
+	return !against(fx, fy) && !against(tx, ty)
+}
+
+func (game *GameState) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < game.Width && y < game.Height
+}
+func (game *GameState) announce(t string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	env := Envelope{Type: t, Payload: payload}
+	b, _ := json.Marshal(env)
+	msg := broadcastMessage{text: b, event: t}
+	if binaryEvents[t] && game.hub.binary.Load() > 0 {
+		msg.binary, _ = msgpackFromJSON(b)
+	}
+	game.hub.publish(msg)
+}
+
+// subscribe limits the broadcast events the client receives to p.Events, or lifts the limit when
+// p.Events is null. Replies and other events sent to the client alone always arrive.
+func (c *Client) subscribe(p SubscribePayload) {
+	var events map[string]bool
+	if p.Events != nil {
+		events = make(map[string]bool, len(p.Events))
+		for _, e := range p.Events {
+			events[e] = true
+		}
+	}
+	select {
+	case c.room.hub.subscribe <- subscription{c, events}:
+	case <-c.room.hub.done:
+	}
+}
+
+// sendEvent delivers an event to this client only, via its room hub.
+func (c *Client) sendEvent(t string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	env := Envelope{Type: t, Payload: payload}
+	b, _ := json.Marshal(env)
+	c.sendDirect(b)
+}
+
+// sendDirect queues an encoded message for this client alone. The hub hands it to the client's send
+// channel or, if that is full, drops the client, so callers never block on a slow connection.
+func (c *Client) sendDirect(b []byte) {
+	select {
+	case c.room.hub.direct <- directMessage{client: c, msg: b}:
+	case <-c.room.hub.done:
+	}
+}
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+func sign(v float64) float64 {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
+
+// ================= Day / Night =================
+const (
+	ticksPerDay    = 24 // one in-game hour per tick
+	morningStart   = 6
+	morningEnd     = 10 // exclusive
+	workEndHour    = 17
+	nightStart     = 22
+	nightEnd       = 5    // exclusive
+	nightGoodsRate = 0.25 // share of goods spawns that still happen at night
+)
+
+func (game *GameState) hour() int {
+	return int(game.Tick % ticksPerDay)
+}
+
+func isNight(hour int) bool {
+	return hour >= nightStart || hour < nightEnd
+}
+
+// commuteRate is the chance a citizen spawn attempt goes ahead at the given hour: everyone heads out
+// in the morning, some during the day, few in the evening and almost nobody at night.
+func commuteRate(hour int) float64 {
+	switch {
+	case hour >= morningStart && hour < morningEnd:
+		return 1
+	case isNight(hour):
+		return 0.05
+	case hour < workEndHour:
+		return 0.4
+	default:
+		return 0.15
+	}
+}
+
+// workShift is how long (in game seconds, one tick each) a group arriving now stays at work: until
+// workEndHour during the working day, so commuters head home together in the evening, otherwise
+// a short 5-15 second visit.
+func (game *GameState) workShift() float64 {
+	if h := game.hour(); h >= nightEnd && h < workEndHour {
+		return float64(workEndHour-h) + game.rng.Float64()*3
+	}
+	return 5 + game.rng.Float64()*10
+}
+
+// ================= Citizens Simulation =================
+type CitizenGroup struct {
+	ID               int64
+	Count            int
+	X, Y             float64
+	Path             [][2]int
+	PathIndex        int
+	State            string  // outbound, working, return; inbound for newcomers moving in from the map edge; visiting, shopping for airport visitors
+	Timer            float64 // work timer seconds
+	OriginX, OriginY int
+	DestX, DestY     int
+	// legacy fields for color & lane replaced earlier were removed; add minimal stuck tracking
+	LastX, LastY float64
+	StuckTicks   int
+	Transit      *TransitTrip // set while the trip goes by bus
+}
+
+// ================= Tourism =================
+const (
+	tourismDivisor    = 20 // an airport earns population/tourismDivisor a tick
+	visitorsPerGroup  = 4
+	visitorPopulation = 200 // an airport sends one visitor group a tick per this many residents
+	maxVisitorGroups  = 3   // per airport per tick
+	visitorShopping   = 6.0 // seconds a visitor group stays at a shop
+	visitorRouteLimit = 400
+)
+
+// airports lists the root tile of every airport.
+func (game *GameState) airports() [][2]int {
+	var out [][2]int
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			if t.Structure != nil && t.Structure.Type == "airport" {
+				out = append(out, [2]int{t.X, t.Y})
+			}
+		}
+	}
+	return out
+}
+
+// spawnVisitors sends visitor groups from each airport's access road to random open shops, one group
+// per visitorPopulation residents up to maxVisitorGroups a tick. Visitors come and go by air: they
+// arrive at the airport, shop for a while, and leave the city from the shop.
+func (game *GameState) spawnVisitors() {
+	airports := game.airports()
+	if len(airports) == 0 || game.Population < visitorPopulation {
+		return
+	}
+	var shops [][2]int
+	for _, t := range game.tiles().commercial {
+		if b := t.Building; b != nil && b.Final && b.Type == Commercial && b.AbandonPhase == 0 {
+			shops = append(shops, [2]int{t.X, t.Y})
+		}
+	}
+	if len(shops) == 0 {
+		return
+	}
+	groups := min(game.Population/visitorPopulation, maxVisitorGroups)
+	for _, a := range airports {
+		start, ok := [2]int{}, false
+		for _, c := range game.structureFootprint(a[0], a[1]) {
+			if rx, ry, found := game.adjacentRoad(c[0], c[1]); found {
+				start, ok = [2]int{rx, ry}, true
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for i := 0; i < groups && game.entityRoom(); i++ {
+			shop := shops[game.rng.Intn(len(shops))]
+			rx, ry, ok := game.adjacentRoad(shop[0], shop[1])
+			if !ok {
+				continue
 			}
+			p := game.roadPath(start, [2]int{rx, ry}, visitorRouteLimit)
+			if len(p) == 0 {
+				continue
+			}
+			game.citizenSeq++
+			path := append(p[1:], shop)
+			game.CitizenGroups = append(game.CitizenGroups, &CitizenGroup{ID: game.citizenSeq, Count: visitorsPerGroup, X: float64(start[0]), Y: float64(start[1]), Path: path, State: "visiting", OriginX: a[0], OriginY: a[1], DestX: shop[0], DestY: shop[1]})
 		}
 	}
-	game.Population = pop
-	// Employment approximated: total assigned employees (recomputed later)
 }
 
-// Allocation & abandonment
+// ================= Transit =================
 const (
-	industrialCapacity      = 4
-	commercialCapacity      = 2
-	commercialSupplyNeed    = 1
-	commercialCustomerNeed  = 5
-	abandonTriggerTicksBase = 5 // base trigger for R & I
-	commercialAbandonFactor = 3 // commercial takes 3x longer
-	abandonPhaseTicks       = 3 // ticks spent in black phase before removal
-	maxCommercialSupplies   = 8
+	busSpeed       = 1.6
+	busCapacity    = 20 // riders per bus
+	stopsPerBus    = 2  // a route runs one bus per this many stops, at least one
+	busWalkDist    = 4  // manhattan tiles a commuter walks between a building and a stop's road
+	busRouteLength = 2000
 )
 
-// growthTick: introduce new residents trying to occupy available residential slots.
-func growthTick() []BuildingUpdate {
-	updates := []BuildingUpdate{}
-	// spawn a few new applicants each tick
-	newApplicants := 3
-	for i := 0; i < newApplicants; i++ {
-		game.PendingResidents = append(game.PendingResidents, 0)
-	}
-	assignedIdx := map[int]bool{}
-	for idx, wait := range game.PendingResidents {
-		_ = wait
-		// find a residential building with space
-		var target *Building
-		var tx, ty int
-		for y := 0; y < game.Height && target == nil; y++ {
-			for x := 0; x < game.Width; x++ {
-				b := game.Tiles[y][x].Building
-				if b != nil && b.Final && b.Type == Residential && b.Residents < 10 && b.AbandonPhase == 0 {
-					target = b
-					tx = x
-					ty = y
-					break
-				}
+// BusRoute is a loop of roads through the bus stops of one road network. Stops are the stops'
+// access roads in visiting order; Path is the closed loop starting at the first stop.
+type BusRoute struct {
+	ID    int64
+	Stops [][2]int
+	Path  [][2]int
+}
+
+// TransitTrip is a commuter's bus ride from the stop at Board to the stop at Alight.
+type TransitTrip struct {
+	Route         int64
+	Board, Alight [2]int
+	Bus           int64 // bus ridden, 0 while walking to or waiting at Board
+	Alighted      bool  // off the bus, walking the rest of the way
+}
+
+// busStopRoads lists the access road of every bus stop, in tile order and without repeats.
+func (game *GameState) busStopRoads() [][2]int {
+	var stops [][2]int
+	seen := map[[2]int]bool{}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			st := game.Tiles[y][x].Structure
+			if st == nil || st.Type != "bus_stop" {
+				continue
+			}
+			if rx, ry, ok := game.adjacentRoad(x, y); ok && !seen[[2]int{rx, ry}] {
+				seen[[2]int{rx, ry}] = true
+				stops = append(stops, [2]int{rx, ry})
 			}
-		}
-		if target != nil {
-			target.Residents++
-			updates = append(updates, BuildingUpdate{X: tx, Y: ty, Building: target})
-			assignedIdx[idx] = true
 		}
 	}
-	// rebuild pending list with incremented waits for unassigned
-	newPending := make([]int, 0, len(game.PendingResidents))
-	for idx, wait := range game.PendingResidents {
-		if assignedIdx[idx] {
-			continue
-		}
-		wait++
-		if wait > 5 { // leave city
-			continue
+	return stops
+}
+
+// routesValid reports whether every route still runs on roads it may drive.
+func (game *GameState) routesValid() bool {
+	for _, rt := range game.busRoutes {
+		for i, p := range rt.Path {
+			n := rt.Path[(i+1)%len(rt.Path)]
+			if game.Tiles[p[1]][p[0]].Road == nil || (p != n && !game.roadStepAllowed(p[0], p[1], n[0], n[1])) {
+				return false
+			}
 		}
-		newPending = append(newPending, wait)
 	}
-	game.PendingResidents = newPending
-	return updates
+	return true
 }
 
-func allocateLaborAndSupplies() []BuildingUpdate {
-	type ref struct {
-		b    *Building
-		t    *Tile
-		x, y int
+// updateTransit rebuilds the bus routes when stops or their roads changed, then keeps each route
+// running its share of buses. Buses on withdrawn routes are removed; their riders and waiting
+// commuters continue by road (see updateCitizens).
+func (game *GameState) updateTransit() {
+	stops := game.busStopRoads()
+	if !slices.Equal(stops, game.busStops) || !game.routesValid() {
+		game.busStops = stops
+		game.busRoutes = game.buildBusRoutes(stops)
 	}
-	var inds []*Building
-	var comm []*Building
-	var res []*Building
-	refs := []ref{}
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
-			t := game.Tiles[y][x]
-			if b := t.Building; b != nil && b.Final {
-				refs = append(refs, ref{b, t, x, y})
-				switch b.Type {
-				case Industrial:
-					inds = append(inds, b)
-				case Commercial:
-					comm = append(comm, b)
-				case Residential:
-					res = append(res, b)
-				}
+	count := map[int64]int{}
+	kept := game.Vehicles[:0]
+	for _, v := range game.Vehicles {
+		if v.Kind == VehicleBus {
+			if game.busRoute(v.Route) == nil {
+				continue
 			}
+			count[v.Route]++
 		}
+		kept = append(kept, v)
 	}
-	// Persistent workforce model:
-	// We no longer reset Employees each tick. Instead we adjust toward a target available worker pool
-	// while preserving existing assignments as much as possible. This reduces oscillation and
-	// prevents commercial buildings from being repeatedly starved every tick.
-
-	// Desired available workers: fill up to min(jobCapacity, population) to avoid artificial structural unemployment.
-	jobCapacity := len(inds)*industrialCapacity + len(comm)*commercialCapacity
-	targetWorkers := jobCapacity
-	if targetWorkers > game.Population {
-		targetWorkers = game.Population
+	game.Vehicles = kept
+	for _, rt := range game.busRoutes {
+		want := max(len(rt.Stops)/stopsPerBus, 1)
+		for i := count[rt.ID]; i < want && game.entityRoom(); i++ { // spread new buses around the loop
+			at := i * len(rt.Path) / want
+			game.vehicleSeq++
+			game.Vehicles = append(game.Vehicles, &Vehicle{ID: game.vehicleSeq, X: float64(rt.Path[at][0]), Y: float64(rt.Path[at][1]),
+				Path: rt.Path, PathIndex: (at + 1) % len(rt.Path), Kind: VehicleBus, Route: rt.ID})
+		}
 	}
-	// Count current workers (only active, non-abandoning buildings matter)
-	currentWorkers := 0
-	for _, b := range inds {
-		if b.AbandonPhase == 0 {
-			currentWorkers += b.Employees
+}
+
+// buildBusRoutes makes one loop per road network holding two or more stops, visiting them
+// nearest-first from the first stop found.
+func (game *GameState) buildBusRoutes(stops [][2]int) []*BusRoute {
+	comp := map[[2]int]int{}
+	for i, c := range game.roadComponents() {
+		for _, t := range c {
+			comp[t] = i
 		}
 	}
-	for _, b := range comm {
-		if b.AbandonPhase == 0 {
-			currentWorkers += b.Employees
+	groups := map[int][][2]int{}
+	var order []int
+	for _, s := range stops {
+		c := comp[s]
+		if _, ok := groups[c]; !ok {
+			order = append(order, c)
 		}
+		groups[c] = append(groups[c], s)
 	}
-	// If we have more workers assigned than target, scale down (remove from Commercial first, then Industrial)
-	if currentWorkers > targetWorkers {
-		diff := currentWorkers - targetWorkers
-		for diff > 0 {
-			changed := false
-			// Trim commercial employees first (reverse order for mild fairness rotation)
-			for i := len(comm) - 1; i >= 0 && diff > 0; i-- {
-				b := comm[i]
-				if b.AbandonPhase > 0 || b.Employees == 0 {
-					continue
-				}
-				b.Employees--
-				diff--
-				changed = true
-			}
-			// Then trim industrial
-			for i := len(inds) - 1; i >= 0 && diff > 0; i-- {
-				b := inds[i]
-				if b.AbandonPhase > 0 || b.Employees == 0 {
-					continue
-				}
-				b.Employees--
-				diff--
-				changed = true
-			}
-			if !changed { // nothing to trim
-				break
-			}
+	var routes []*BusRoute
+	for _, c := range order {
+		pending := groups[c]
+		if len(pending) < 2 {
+			continue
 		}
-	} else if currentWorkers < targetWorkers { // Need to add workers
-		diff := targetWorkers - currentWorkers
-		// Step 1: ensure at least 1 worker at each industrial (production base)
-		for _, b := range inds {
-			if diff == 0 {
-				break
-			}
-			if b.AbandonPhase == 0 && b.Employees == 0 && industrialCapacity > 0 {
-				b.Employees = 1
-				diff--
+		visit := [][2]int{pending[0]}
+		pending = slices.Clone(pending[1:])
+		for len(pending) > 0 {
+			last, best := visit[len(visit)-1], 0
+			for i, s := range pending {
+				if absInt(s[0]-last[0])+absInt(s[1]-last[1]) < absInt(pending[best][0]-last[0])+absInt(pending[best][1]-last[1]) {
+					best = i
+				}
 			}
+			visit = append(visit, pending[best])
+			pending = slices.Delete(pending, best, best+1)
 		}
-		// Step 2: ensure at least 1 worker at each commercial (so they can potentially open)
-		for _, b := range comm {
-			if diff == 0 {
+		loop := [][2]int{visit[0]}
+		ok := true
+		for i, s := range visit {
+			seg := game.roadPath(s, visit[(i+1)%len(visit)], busRouteLength)
+			if len(seg) == 0 {
+				ok = false
 				break
 			}
-			if b.AbandonPhase == 0 && b.Employees == 0 && commercialCapacity > 0 {
-				b.Employees = 1
-				diff--
-			}
+			loop = append(loop, seg[1:]...)
 		}
-		// Step 3: fill remaining industrial capacity (prioritize production to curb over-weighted commercial expansion)
-		for diff > 0 {
-			progress := false
-			for _, b := range inds {
-				if diff == 0 {
-					break
-				}
-				if b.AbandonPhase == 0 && b.Employees < industrialCapacity {
-					b.Employees++
-					diff--
-					progress = true
-				}
-			}
-			// Step 4: then fill commercial capacity round-robin style
-			for _, b := range comm {
-				if diff == 0 {
-					break
-				}
-				if b.AbandonPhase == 0 && b.Employees < commercialCapacity {
-					b.Employees++
-					diff--
-					progress = true
-				}
-			}
-			if !progress || diff == 0 {
-				break
-			}
+		if !ok || len(loop) < 3 {
+			continue
 		}
+		game.routeSeq++
+		routes = append(routes, &BusRoute{ID: game.routeSeq, Stops: visit, Path: loop[:len(loop)-1]})
 	}
-	// industrial production proportional to employees (1 good per fully staffed 4, so employees/4 rounded up minimal 1 if any)
-	produced := 0
-	for _, b := range inds {
-		if b.Employees > 0 {
-			// accumulate produced units
-			gain := b.Employees / industrialCapacity
-			if b.Employees > 0 && gain == 0 {
-				gain = 1
-			}
-			produced += gain
+	return routes
+}
+
+func (game *GameState) busRoute(id int64) *BusRoute {
+	for _, rt := range game.busRoutes {
+		if rt.ID == id {
+			return rt
 		}
 	}
-	// distribute to commercial supplies
-	if produced > 0 && len(comm) > 0 {
-		for produced > 0 {
-			progress := false
-			for _, b := range comm {
-				if b.Supplies < maxCommercialSupplies {
-					b.Supplies++
-					produced--
-					progress = true
-					if produced == 0 {
-						break
-					}
-				}
-			}
-			if !progress {
-				break
-			}
+	return nil
+}
+
+func (game *GameState) busesByID() map[int64]*Vehicle {
+	buses := map[int64]*Vehicle{}
+	for _, v := range game.Vehicles {
+		if v.Kind == VehicleBus {
+			buses[v.ID] = v
 		}
 	}
-	// estimate customers: total residents
-	customerPool := 0
-	for _, r := range res {
-		customerPool += r.Residents
-	}
-	// evaluate abandonment criteria & phases
-	updates := []BuildingUpdate{}
-	for _, r := range refs {
-		b := r.b
-		if b.AbandonPhase > 0 { // countdown
-			b.AbandonPhase--
-			if b.AbandonPhase == 0 { // remove now
-				r.t.Building = nil
-				r.t.Zone = nil
-				updates = append(updates, BuildingUpdate{X: r.x, Y: r.y, Building: nil})
-				continue
-			} else {
-				updates = append(updates, BuildingUpdate{X: r.x, Y: r.y, Building: b})
-				continue
-			}
-		}
-		// determine active criteria (new logic with extended commercial threshold)
-		var failing bool
-		switch b.Type {
-		case Residential:
-			failing = (b.Residents == 0)
-		case Industrial:
-			failing = (b.Employees == 0)
-		case Commercial:
-			open := (b.Employees >= 1 && b.Supplies >= commercialSupplyNeed && customerPool >= commercialCustomerNeed)
-			failing = !open
-		}
-		if failing {
-			b.IdleTicks++
-		} else {
-			b.IdleTicks = 0
+	return buses
+}
+
+// nearestStop is the stop of rt closest to the building at b within busWalkDist, if any.
+func nearestStop(rt *BusRoute, b [2]int) ([2]int, bool) {
+	best, bestDist := [2]int{}, -1
+	for _, s := range rt.Stops {
+		if d := absInt(s[0]-b[0]) + absInt(s[1]-b[1]); d <= busWalkDist && (bestDist < 0 || d < bestDist) {
+			best, bestDist = s, d
 		}
-		threshold := abandonTriggerTicksBase
-		if b.Type == Commercial {
-			threshold = abandonTriggerTicksBase * commercialAbandonFactor
+	}
+	return best, bestDist >= 0
+}
+
+// planTransit finds a bus route with stops near both the from and to buildings and room for
+// another rider, returning the trip and the walk from the road at start to the boarding stop.
+func (game *GameState) planTransit(start, from, to [2]int) (*TransitTrip, [][2]int) {
+	for _, rt := range game.busRoutes {
+		board, ok1 := nearestStop(rt, from)
+		alight, ok2 := nearestStop(rt, to)
+		if !ok1 || !ok2 || board == alight || game.transitRiders(rt.ID) >= game.routeCapacity(rt.ID) {
+			continue
 		}
-		if b.IdleTicks >= threshold {
-			b.IdleTicks = 0
-			b.AbandonPhase = abandonPhaseTicks
+		walk := game.roadPath(start, board, 400)
+		if len(walk) == 0 {
+			continue
 		}
-		updates = append(updates, BuildingUpdate{X: r.x, Y: r.y, Building: b})
+		return &TransitTrip{Route: rt.ID, Board: board, Alight: alight}, walk
 	}
-	return updates
+	return nil, nil
 }
-func economicTick() {
-	income := game.Employed/10 + game.Population/20
-	for _, p := range game.Players {
-		p.Money += income
+
+// transitRiders counts commuters riding or bound for a bus on route id.
+func (game *GameState) transitRiders(id int64) int {
+	n := 0
+	for _, g := range game.CitizenGroups {
+		if tr := g.Transit; tr != nil && tr.Route == id && !tr.Alighted {
+			n += g.Count
+		}
 	}
+	return n
 }
 
-const vehicleSpeed = 2.0
-const citizenSpeed = 1.5
-const goodsSpeed = 2.4
-
-func trafficLoop() {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-	last := time.Now()
-	spawnAcc := time.Duration(0)
-	citizenSpawnAcc := time.Duration(0)
-	goodsSpawnAcc := time.Duration(0)
-	for range ticker.C {
-		now := time.Now()
-		dt := now.Sub(last).Seconds()
-		last = now
-		gameMu.Lock()
-		updateTraffic(dt)
-		updateCitizens(dt)
-		updateGoods(dt)
-		spawnAcc += 100 * time.Millisecond
-		if spawnAcc >= time.Second {
-			spawnAcc -= time.Second
-			spawnVehicles()
-		}
-		citizenSpawnAcc += 100 * time.Millisecond
-		if citizenSpawnAcc >= 200*time.Millisecond { // much faster citizen spawning
-			citizenSpawnAcc -= 200 * time.Millisecond
-			spawnCitizenGroups()
-		}
-		goodsSpawnAcc += 100 * time.Millisecond
-		if goodsSpawnAcc >= 1500*time.Millisecond { // spawn goods roughly every 1.5s
-			goodsSpawnAcc -= 1500 * time.Millisecond
-			spawnGoodsShipments()
-		}
-		broadcastTraffic()
-		gameMu.Unlock()
-	}
-}
-func updateTraffic(dt float64) {
-	if len(game.Vehicles) == 0 {
-		return
-	}
-	move := vehicleSpeed * dt
-	kept := game.Vehicles[:0]
+func (game *GameState) routeCapacity(id int64) int {
+	n := 0
 	for _, v := range game.Vehicles {
-		remain := move
-		for remain > 0 && v.PathIndex < len(v.Path) {
-			tgt := v.Path[v.PathIndex]
-			tx, ty := float64(tgt[0]), float64(tgt[1])
-			dx, dy := tx-v.X, ty-v.Y
-			dist := abs(dx) + abs(dy)
-			if dist <= remain {
-				v.X, v.Y = tx, ty
-				v.PathIndex++
-				remain -= dist
-			} else {
-				if dx != 0 {
-					v.X += remain * sign(dx)
-				} else if dy != 0 {
-					v.Y += remain * sign(dy)
-				}
-				remain = 0
-			}
-		}
-		if v.PathIndex < len(v.Path) {
-			kept = append(kept, v)
+		if v.Kind == VehicleBus && v.Route == id {
+			n += busCapacity
 		}
 	}
-	game.Vehicles = kept
+	return n
 }
-func spawnVehicles() {
-	desired := game.Population / 25
-	if desired > 120 {
-		desired = 120
-	}
-	deficit := desired - len(game.Vehicles)
-	if deficit <= 0 {
+
+// busAt lets riders off and waiting commuters on as bus v reaches road tile at.
+func (game *GameState) busAt(v *Vehicle, at [2]int) {
+	rt := game.busRoute(v.Route)
+	if rt == nil || !slices.Contains(rt.Stops, at) {
 		return
 	}
-	if deficit > 8 {
-		deficit = 8
-	}
-	roads := make([][2]int, 0)
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
-			if game.Tiles[y][x].Road != nil {
-				roads = append(roads, [2]int{x, y})
-			}
+	riders := 0
+	for _, g := range game.CitizenGroups {
+		tr := g.Transit
+		if tr == nil || tr.Bus != v.ID {
+			continue
 		}
-	}
-	if len(roads) < 2 {
-		return
-	}
-	for i := 0; i < deficit; i++ {
-		a := roads[rand.Intn(len(roads))]
-		b := roads[rand.Intn(len(roads))]
-		if a == b {
+		if tr.Alight != at {
+			riders += g.Count
 			continue
 		}
-		path := roadPath(a, b, 200)
-		if len(path) < 2 {
+		tx, ty := g.DestX, g.DestY
+		if g.State == "return" {
+			tx, ty = g.OriginX, g.OriginY
+		}
+		rx, ry, _ := game.adjacentRoad(tx, ty)
+		walk := game.roadPath(at, [2]int{rx, ry}, 400)
+		if len(walk) == 0 { // stay on for another lap
+			riders += g.Count
+			continue
+		}
+		g.X, g.Y = float64(at[0]), float64(at[1])
+		g.Path = append(walk, [2]int{tx, ty})
+		g.PathIndex = 0
+		tr.Bus, tr.Alighted = 0, true
+	}
+	for _, g := range game.CitizenGroups {
+		tr := g.Transit
+		if tr == nil || tr.Bus != 0 || tr.Alighted || tr.Route != v.Route || tr.Board != at || g.PathIndex < len(g.Path) || riders+g.Count > busCapacity {
 			continue
 		}
-		vehicleSeq++
-		v := &Vehicle{ID: vehicleSeq, X: float64(path[0][0]), Y: float64(path[0][1]), Path: path[1:]}
-		game.Vehicles = append(game.Vehicles, v)
+		tr.Bus = v.ID
+		riders += g.Count
 	}
 }
-func broadcastTraffic() {
-	type V struct {
-		ID int64   `json:"id"`
-		X  float64 `json:"x"`
-		Y  float64 `json:"y"`
+
+// ================= Rail =================
+const (
+	railCost      = 40
+	trainSpeed    = 5.0 // tiles per second; goods by road move at goodsSpeed
+	trainCapacity = 8   // goods units one train carries
+)
+
+func (r *Room) placeRail(pid PlayerID, p PlaceRailPayload) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	pl := game.Players[pid]
+	if pl == nil {
+		return ReasonNoPlayer
 	}
-	out := make([]V, len(game.Vehicles))
-	for i, v := range game.Vehicles {
-		out[i] = V{ID: v.ID, X: v.X, Y: v.Y}
+	if !game.inBounds(p.X, p.Y) {
+		return ReasonOutOfBounds
 	}
-	goodsIC := make([]V, len(game.GoodsIC))
-	for i, g := range game.GoodsIC {
-		goodsIC[i] = V{ID: g.ID, X: g.X, Y: g.Y}
+	t := game.Tiles[p.Y][p.X]
+	if t.Zone != nil || t.Road != nil || t.Rail != nil || t.Structure != nil || t.Building != nil {
+		return ReasonOccupied
 	}
-	goodsCC := make([]V, len(game.GoodsCC))
-	for i, g := range game.GoodsCC {
-		goodsCC[i] = V{ID: g.ID, X: g.X, Y: g.Y}
+	if t.Terrain == "water" {
+		return ReasonUnbuildable
 	}
-	// Citizens: include all; workers shown at destination tile center
-	citAll := make([]V, 0, len(game.CitizenGroups))
-	for _, g := range game.CitizenGroups {
-		if g.State == "working" {
-			// snap to destination tile center (x+0.5,y+0.5)
-			citAll = append(citAll, V{ID: g.ID, X: float64(g.DestX) + 0.5, Y: float64(g.DestY) + 0.5})
-		} else {
-			citAll = append(citAll, V{ID: g.ID, X: g.X, Y: g.Y})
+	cost, ok := game.placementCost(railCost, p.X, p.Y)
+	if !ok {
+		return ReasonTooSteep
+	}
+	if pl.Money < cost {
+		return ReasonInsufficientFunds
+	}
+	before := game.layersAt(p.X, p.Y)
+	pl.Money -= cost
+	t.Foliage = ""
+	t.Rail = &Rail{Owner: pid, PlacedAt: game.unixNow()}
+	game.markTile(t)
+	game.logLayers(p.X, p.Y, before, ActionPlaceRail, pid)
+	r.pushUndo(pid, undoEntry{Spent: cost, Tiles: []undoTile{{p.X, p.Y, before, game.layersAt(p.X, p.Y)}}})
+	game.announce(EventRailPlaced, struct {
+		X    int   `json:"x"`
+		Y    int   `json:"y"`
+		Rail *Rail `json:"rail"`
+	}{p.X, p.Y, t.Rail})
+	return ""
+}
+
+func (game *GameState) adjacentRail(x, y int) ([2]int, bool) {
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		nx, ny := x+d[0], y+d[1]
+		if game.inBounds(nx, ny) && game.Tiles[ny][nx].Rail != nil {
+			return [2]int{nx, ny}, true
 		}
 	}
-	announce(EventTrafficUpdate, struct {
-		TS       int64 `json:"ts"`
-		Vehicles []V   `json:"vehicles"`
-		GoodsIC  []V   `json:"goodsIC"`
-		GoodsCC  []V   `json:"goodsCC"`
-		Citizens []V   `json:"citizens"`
-	}{time.Now().UnixNano(), out, goodsIC, goodsCC, citAll})
+	return [2]int{}, false
 }
-func roadPath(start, goal [2]int, limit int) [][2]int {
+
+// railPath is roadPath for trains: the shortest route from start to goal over rail tiles only,
+// or an empty path if there is none within limit explored tiles.
+func (game *GameState) railPath(start, goal [2]int, limit int) [][2]int {
 	if start == goal {
 		return [][2]int{start}
 	}
-	type node struct{ x, y int }
-	q := []node{{start[0], start[1]}}
-	prev := map[[2]int][2]int{}
-	vis := map[[2]int]bool{start: true}
-	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	prev := map[[2]int][2]int{start: start}
+	q := [][2]int{start}
 	for len(q) > 0 && len(prev) < limit {
 		cur := q[0]
 		q = q[1:]
-		if cur.x == goal[0] && cur.y == goal[1] {
+		if cur == goal {
 			break
 		}
-		for _, d := range dirs {
-			nx, ny := cur.x+d[0], cur.y+d[1]
-			if !inBounds(nx, ny) {
-				continue
-			}
-			if game.Tiles[ny][nx].Road == nil {
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if _, seen := prev[n]; seen || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Rail == nil {
 				continue
 			}
-			key := [2]int{nx, ny}
-			if !vis[key] {
-				vis[key] = true
-				prev[key] = [2]int{cur.x, cur.y}
-				q = append(q, node{nx, ny})
-			}
+			prev[n] = cur
+			q = append(q, n)
 		}
 	}
 	if _, ok := prev[goal]; !ok {
 		return [][2]int{}
 	}
-	path := make([][2]int, 0)
-	cur := goal
-	for cur != start {
-		path = append(path, cur)
+	path := [][2]int{goal}
+	for cur := goal; cur != start; {
 		cur = prev[cur]
+		path = append(path, cur)
 	}
-	path = append(path, start)
-	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-		path[i], path[j] = path[j], path[i]
-	}
+	slices.Reverse(path)
 	return path
 }
 
-func inBounds(x, y int) bool { return x >= 0 && y >= 0 && x < game.Width && y < game.Height }
-func announce(t string, data interface{}) {
-	payload, _ := json.Marshal(data)
-	env := Envelope{Type: t, Payload: payload}
-	b, _ := json.Marshal(env)
-	hub.broadcast <- b
-}
-func abs(v float64) float64 {
-	if v < 0 {
-		return -v
-	}
-	return v
-}
-func sign(v float64) float64 {
-	if v < 0 {
-		return -1
+// railRoute is the rail path between the tracks beside buildings a and b, if both have one.
+func (game *GameState) railRoute(a, b [2]int) [][2]int {
+	ra, ok1 := game.adjacentRail(a[0], a[1])
+	rb, ok2 := game.adjacentRail(b[0], b[1])
+	if !ok1 || !ok2 {
+		return nil
 	}
-	return 1
+	return game.railPath(ra, rb, 4000)
 }
 
-// ================= Citizens Simulation =================
-type CitizenGroup struct {
-	ID               int64
-	Count            int
-	X, Y             float64
-	Path             [][2]int
-	PathIndex        int
-	State            string  // outbound, working, return
-	Timer            float64 // work timer seconds
-	OriginX, OriginY int
-	DestX, DestY     int
-	// legacy fields for color & lane replaced earlier were removed; add minimal stuck tracking
-	LastX, LastY float64
-	StuckTicks   int
+// railEdgeRoute is the rail path from the track beside building c to the nearest rail tile on the
+// map border, if c's line reaches one.
+func (game *GameState) railEdgeRoute(c [2]int) ([][2]int, bool) {
+	start, ok := game.adjacentRail(c[0], c[1])
+	if !ok {
+		return nil, false
+	}
+	seen := map[[2]int]bool{start: true}
+	q := [][2]int{start}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		if cur[0] == 0 || cur[1] == 0 || cur[0] == game.Width-1 || cur[1] == game.Height-1 {
+			p := game.railPath(start, cur, 4000)
+			return p, len(p) > 1
+		}
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if seen[n] || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Rail == nil {
+				continue
+			}
+			seen[n] = true
+			q = append(q, n)
+		}
+	}
+	return nil, false
 }
 
 // ================= Goods Shipments =================
@@ -1213,10 +6970,14 @@ type GoodShipment struct {
 	X, Y      float64
 	Path      [][2]int
 	PathIndex int
-	Kind      string // "IC" or "CC"
+	Kind      string   // "IC", "CC", "EX" (export to the map edge) or "IM" (import from it)
+	ToX, ToY  int      // receiving commercial building, or the edge road for exports
+	Units     int      // supplies delivered on arrival
+	Owner     PlayerID // zone owner credited for an export or charged for an import
+	Train     bool     // carried by rail rather than by road
 }
 
-func updateGoods(dt float64) {
+func (game *GameState) updateGoods(dt float64) {
 	if len(game.GoodsIC) == 0 && len(game.GoodsCC) == 0 {
 		return
 	}
@@ -1224,15 +6985,32 @@ func updateGoods(dt float64) {
 	advance := func(src []*GoodShipment) []*GoodShipment {
 		kept := src[:0]
 		for _, s := range src {
-			remain := move
+			remain := move * game.roadSpeedFactor(s.X, s.Y)
+			if s.Train {
+				remain = trainSpeed * dt
+			}
+			blocked, rerouted := false, false
 			for remain > 0 && s.PathIndex < len(s.Path) {
 				tgt := s.Path[s.PathIndex]
+				if s.Train && game.Tiles[tgt[1]][tgt[0]].Rail == nil {
+					blocked = true
+					break
+				}
+				if !s.Train && !game.pathStepAllowed(s.X, s.Y, tgt) { // made one-way under us: find another way
+					if !rerouted && game.repath(&s.X, &s.Y, &s.Path, &s.PathIndex) {
+						rerouted = true
+						continue
+					}
+					blocked = true
+					break
+				}
 				tx, ty := float64(tgt[0]), float64(tgt[1])
 				dx, dy := tx-s.X, ty-s.Y
 				dist := abs(dx) + abs(dy)
 				if dist <= remain {
 					s.X, s.Y = tx, ty
 					s.PathIndex++
+					game.addHeat(tgt, 1)
 					remain -= dist
 				} else {
 					if dx != 0 {
@@ -1243,8 +7021,22 @@ func updateGoods(dt float64) {
 					remain = 0
 				}
 			}
+			if blocked { // no way on: the goods are lost
+				continue
+			}
 			if s.PathIndex < len(s.Path) { // still traveling
 				kept = append(kept, s)
+				continue
+			}
+			if s.Kind == "EX" {
+				game.addTrade(s.Owner, s.Units*exportPrice)
+				continue
+			}
+			if b := game.Tiles[s.ToY][s.ToX].Building; b != nil && b.Type == Commercial {
+				b.Supplies = min(b.Supplies+s.Units, game.Config.MaxCommercialSupplies)
+				if s.Kind == "IM" {
+					game.addTrade(s.Owner, -s.Units*importPrice)
+				}
 			}
 		}
 		return kept
@@ -1253,83 +7045,201 @@ func updateGoods(dt float64) {
 	game.GoodsCC = advance(game.GoodsCC)
 }
 
-func spawnGoodsShipments() { // cap total
-	if len(game.GoodsIC)+len(game.GoodsCC) > 300 {
-		return
+const (
+	exportPrice = 15 // paid to the industrial zone owner per unit reaching the map edge
+	importPrice = 10 // charged to the commercial zone owner per imported unit
+)
+
+func (game *GameState) addTrade(pid PlayerID, amount int) {
+	if game.Trade == nil {
+		game.Trade = map[PlayerID]int{}
 	}
-	inds := make([][2]int, 0)
-	comm := make([][2]int, 0)
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
-			t := game.Tiles[y][x]
-			if t.Building != nil && t.Building.Final {
-				switch t.Building.Type {
-				case Industrial:
-					inds = append(inds, [2]int{x, y})
-				case Commercial:
-					comm = append(comm, [2]int{x, y})
-				}
+	game.Trade[pid] += amount
+}
+
+// shipmentBoundFor reports whether an IC or import shipment is already heading to shop c.
+func (game *GameState) shipmentBoundFor(c [2]int) bool {
+	for _, s := range game.GoodsIC {
+		if s.Kind != "EX" && s.ToX == c[0] && s.ToY == c[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// edgeRoute finds the road tile on the map border nearest (by road steps) to building c and returns
+// the road path from c to it when outbound, or from it to c otherwise.
+func (game *GameState) edgeRoute(c [2]int, outbound bool) ([][2]int, bool) {
+	rx, ry, ok := game.adjacentRoad(c[0], c[1])
+	if !ok {
+		return nil, false
+	}
+	start := [2]int{rx, ry}
+	seen := map[[2]int]bool{start: true}
+	q := [][2]int{start}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		if cur[0] == 0 || cur[1] == 0 || cur[0] == game.Width-1 || cur[1] == game.Height-1 {
+			var p [][2]int
+			if outbound {
+				p = game.roadPath(start, cur, 400)
+			} else {
+				p = game.roadPath(cur, start, 400)
 			}
+			return p, len(p) > 0
+		}
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if seen[n] || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Road == nil {
+				continue
+			}
+			seen[n] = true
+			q = append(q, n)
+		}
+	}
+	return nil, false
+}
+
+func (game *GameState) spawnGoodsShipments() {
+	room := func() bool {
+		return len(game.GoodsIC)+len(game.GoodsCC) < game.areaCap(maxGoods) && game.entityRoom()
+	}
+	if !room() {
+		return
+	}
+	if isNight(game.hour()) && game.rng.Float64() >= nightGoodsRate {
+		return
+	}
+	idx := game.tiles()
+	inds := make([][2]int, 0, len(idx.industrial))
+	comm := make([][2]int, 0, len(idx.commercial))
+	for _, t := range idx.industrial {
+		if t.Building != nil && t.Building.Final && t.Building.Type == Industrial {
+			inds = append(inds, [2]int{t.X, t.Y})
 		}
 	}
-	if len(inds) > 0 && len(comm) > 0 { // spawn IC
+	for _, t := range idx.commercial {
+		if t.Building != nil && t.Building.Final && t.Building.Type == Commercial {
+			comm = append(comm, [2]int{t.X, t.Y})
+		}
+	}
+	building := func(c [2]int) *Building { return game.Tiles[c[1]][c[0]].Building }
+	if len(inds) > 0 && len(comm) > 0 && room() { // spawn IC: ship industrial stock to a shop with room
 		for tries := 0; tries < 3; tries++ {
-			a := inds[rand.Intn(len(inds))]
-			b := comm[rand.Intn(len(comm))]
-			ax, ay, ok1 := adjacentRoad(a[0], a[1])
-			bx, by, ok2 := adjacentRoad(b[0], b[1])
+			a := inds[game.rng.Intn(len(inds))]
+			b := comm[game.rng.Intn(len(comm))]
+			if building(a).Stock == 0 || building(b).Supplies >= game.Config.MaxCommercialSupplies {
+				continue
+			}
+			if p := game.railRoute(a, b); len(p) >= 2 { // rail-linked: one train carries a bigger load
+				game.goodsSeq++
+				units := min(building(a).Stock, trainCapacity)
+				building(a).Stock -= units
+				game.GoodsIC = append(game.GoodsIC, &GoodShipment{ID: game.goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "IC", ToX: b[0], ToY: b[1], Units: units, Train: true})
+				break
+			}
+			ax, ay, ok1 := game.adjacentRoad(a[0], a[1])
+			bx, by, ok2 := game.adjacentRoad(b[0], b[1])
 			if !ok1 || !ok2 {
 				continue
 			}
-			p := roadPath([2]int{ax, ay}, [2]int{bx, by}, 400)
+			p := game.roadPath([2]int{ax, ay}, [2]int{bx, by}, 400)
 			if len(p) < 2 {
 				continue
 			}
-			goodsSeq++
-			s := &GoodShipment{ID: goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "IC"}
+			game.goodsSeq++
+			units := min(building(a).Stock, shipmentUnits)
+			building(a).Stock -= units
+			s := &GoodShipment{ID: game.goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "IC", ToX: b[0], ToY: b[1], Units: units}
 			game.GoodsIC = append(game.GoodsIC, s)
 			break
 		}
 	}
-	if len(comm) > 1 { // spawn CC
+	spawnEdge := func(kind string, c [2]int, units int) bool {
+		if !room() {
+			return false
+		}
+		p, ok := game.edgeRoute(c, kind == "EX")
+		if !ok {
+			return false
+		}
+		to := c
+		if kind == "EX" {
+			to = p[len(p)-1]
+		}
+		var owner PlayerID
+		if z := game.Tiles[c[1]][c[0]].Zone; z != nil {
+			owner = z.Owner
+		}
+		game.goodsSeq++
+		game.GoodsIC = append(game.GoodsIC, &GoodShipment{ID: game.goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: kind, ToX: to[0], ToY: to[1], Units: units, Owner: owner})
+		return true
+	}
+	// export: stock backed up for want of a local buyer leaves by rail if the industry's line reaches
+	// the map edge, else by the nearest edge road
+	if len(inds) > 0 && room() {
+		a := inds[game.rng.Intn(len(inds))]
+		if ba := building(a); ba.Stock >= maxIndustrialStock || len(comm) == 0 && ba.Stock > 0 {
+			if p, ok := game.railEdgeRoute(a); ok {
+				units := min(ba.Stock, trainCapacity)
+				ba.Stock -= units
+				var owner PlayerID
+				if z := game.Tiles[a[1]][a[0]].Zone; z != nil {
+					owner = z.Owner
+				}
+				to := p[len(p)-1]
+				game.goodsSeq++
+				game.GoodsIC = append(game.GoodsIC, &GoodShipment{ID: game.goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "EX", ToX: to[0], ToY: to[1], Units: units, Owner: owner, Train: true})
+			} else if units := min(ba.Stock, shipmentUnits); spawnEdge("EX", a, units) {
+				ba.Stock -= units
+			}
+		}
+	}
+	// import: with no local industry, a low shop with nothing already on the way restocks from the edge
+	if len(inds) == 0 && len(comm) > 0 {
+		b := comm[game.rng.Intn(len(comm))]
+		if building(b).Supplies < game.Config.MaxCommercialSupplies/2 && !game.shipmentBoundFor(b) {
+			spawnEdge("IM", b, shipmentUnits)
+		}
+	}
+	if len(comm) > 1 && room() { // spawn CC: move a unit from a well-stocked shop to a poorer one
 		for tries := 0; tries < 3; tries++ {
-			a := comm[rand.Intn(len(comm))]
-			b := comm[rand.Intn(len(comm))]
-			if a == b {
+			a := comm[game.rng.Intn(len(comm))]
+			b := comm[game.rng.Intn(len(comm))]
+			if a == b || building(a).Supplies-building(b).Supplies < 2 {
 				continue
 			}
-			ax, ay, ok1 := adjacentRoad(a[0], a[1])
-			bx, by, ok2 := adjacentRoad(b[0], b[1])
+			ax, ay, ok1 := game.adjacentRoad(a[0], a[1])
+			bx, by, ok2 := game.adjacentRoad(b[0], b[1])
 			if !ok1 || !ok2 {
 				continue
 			}
-			p := roadPath([2]int{ax, ay}, [2]int{bx, by}, 400)
+			p := game.roadPath([2]int{ax, ay}, [2]int{bx, by}, 400)
 			if len(p) < 2 {
 				continue
 			}
-			goodsSeq++
-			s := &GoodShipment{ID: goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "CC"}
+			game.goodsSeq++
+			building(a).Supplies--
+			s := &GoodShipment{ID: game.goodsSeq, X: float64(p[0][0]), Y: float64(p[0][1]), Path: p[1:], Kind: "CC", ToX: b[0], ToY: b[1], Units: 1}
 			game.GoodsCC = append(game.GoodsCC, s)
 			break
 		}
 	}
 }
 
-func spawnCitizenGroups() {
+func (game *GameState) spawnCitizenGroups() {
 	// collect residential and job tiles once per call
 	res := make([][2]int, 0)
 	jobs := make([][2]int, 0)
-	for y := 0; y < game.Height; y++ {
-		for x := 0; x < game.Width; x++ {
-			t := game.Tiles[y][x]
-			if t.Building != nil && t.Building.Final {
-				if t.Building.Type == Residential {
-					if t.Building.Residents > 0 { // only if someone lives here
-						res = append(res, [2]int{x, y})
-					}
-				} else if t.Building.Type == Commercial || t.Building.Type == Industrial {
-					jobs = append(jobs, [2]int{x, y})
+	for _, t := range game.tiles().buildings {
+		if t.Building != nil && t.Building.Final {
+			if t.Building.Type == Residential {
+				if t.Building.Residents > 0 { // only if someone lives here
+					res = append(res, [2]int{t.X, t.Y})
 				}
+			} else if t.Building.Type == Commercial || t.Building.Type == Industrial {
+				jobs = append(jobs, [2]int{t.X, t.Y})
 			}
 		}
 	}
@@ -1345,9 +7255,10 @@ func spawnCitizenGroups() {
 	if targetActive < 20 {
 		targetActive = 20
 	}
+	targetActive = min(targetActive, game.areaCap(maxCitizenGroups))
 	active := 0
 	for _, g := range game.CitizenGroups {
-		if g.State != "working" {
+		if g.State == "outbound" || g.State == "return" {
 			active += g.Count
 		}
 	}
@@ -1360,26 +7271,46 @@ func spawnCitizenGroups() {
 	if needed < maxBurst {
 		maxBurst = needed
 	}
-	for i := 0; i < maxBurst; i++ {
-		// random pair
-		r := res[rand.Intn(len(res))]
-		j := jobs[rand.Intn(len(jobs))]
-		orx, ory, ok1 := adjacentRoad(r[0], r[1])
-		drx, dry, ok2 := adjacentRoad(j[0], j[1])
-		if !ok1 || !ok2 {
+	// commuters already bound for or at each job
+	load := map[[2]int]int{}
+	for _, g := range game.CitizenGroups {
+		if g.State == "outbound" || g.State == "working" {
+			load[[2]int{g.DestX, g.DestY}] += g.Count
+		}
+	}
+	leave := commuteRate(game.hour())
+	for i := 0; i < maxBurst && game.entityRoom(); i++ {
+		if game.rng.Float64() >= leave { // fewer people set out outside the morning rush
+			continue
+		}
+		// random home, nearest job with room for another commuter
+		r := res[game.rng.Intn(len(res))]
+		orx, ory, ok1 := game.adjacentRoad(r[0], r[1])
+		if !ok1 {
+			continue
+		}
+		j, ok2 := game.nearestOpenJob([2]int{orx, ory}, jobs, load)
+		if !ok2 {
 			continue
 		}
-		roadPathSeg := roadPath([2]int{orx, ory}, [2]int{drx, dry}, 400)
+		drx, dry, _ := game.adjacentRoad(j[0], j[1])
+		roadPathSeg := game.roadPath([2]int{orx, ory}, [2]int{drx, dry}, 400)
 		if len(roadPathSeg) == 0 {
 			continue
 		}
 		path := make([][2]int, 0, len(roadPathSeg)+2)
 		path = append(path, [2]int{r[0], r[1]})
-		path = append(path, roadPathSeg...)
-		path = append(path, [2]int{j[0], j[1]})
-		citizenSeq++
+		// near a bus route covering both ends, walk to the stop and ride instead of driving
+		transit, walk := game.planTransit([2]int{orx, ory}, r, j)
+		if transit != nil {
+			path = append(path, walk...)
+		} else {
+			path = append(path, roadPathSeg...)
+			path = append(path, [2]int{j[0], j[1]})
+		}
+		game.citizenSeq++
 		count := 1
-		g := &CitizenGroup{ID: citizenSeq, Count: count, X: float64(path[0][0]), Y: float64(path[0][1]), Path: path[1:], State: "outbound", OriginX: r[0], OriginY: r[1], DestX: j[0], DestY: j[1]}
+		g := &CitizenGroup{ID: game.citizenSeq, Count: count, X: float64(path[0][0]), Y: float64(path[0][1]), Path: path[1:], State: "outbound", OriginX: r[0], OriginY: r[1], DestX: j[0], DestY: j[1], Transit: transit}
 		// decrement origin residents only if available
 		if tile := game.Tiles[r[1]][r[0]]; tile.Citizens > 0 {
 			tile.Citizens -= 1
@@ -1388,14 +7319,57 @@ func spawnCitizenGroups() {
 			}
 		}
 		game.CitizenGroups = append(game.CitizenGroups, g)
+		load[j] += count
+	}
+}
+
+// jobSlots is how many commuters a job building takes, matching the labor model's capacities.
+func (game *GameState) jobSlots(b *Building) int {
+	if b.Type == Industrial {
+		return game.Config.IndustrialCapacity
+	}
+	return game.Config.CommercialCapacity
+}
+
+// nearestOpenJob returns the job tile whose access road is fewest road steps from start, skipping
+// abandoning jobs and those whose load has reached jobSlots. Ties go to the earlier job in jobs.
+func (game *GameState) nearestOpenJob(start [2]int, jobs [][2]int, load map[[2]int]int) ([2]int, bool) {
+	dist := map[[2]int]int{start: 0}
+	q := [][2]int{start}
+	for len(q) > 0 {
+		cur := q[0]
+		q = q[1:]
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if _, seen := dist[n]; seen || !game.inBounds(n[0], n[1]) || game.Tiles[n[1]][n[0]].Road == nil || !game.roadStepAllowed(cur[0], cur[1], n[0], n[1]) {
+				continue
+			}
+			dist[n] = dist[cur] + 1
+			q = append(q, n)
+		}
+	}
+	best, bestDist := [2]int{}, -1
+	for _, j := range jobs {
+		b := game.Tiles[j[1]][j[0]].Building
+		if b.AbandonPhase > 0 || load[j] >= game.jobSlots(b) {
+			continue
+		}
+		rx, ry, ok := game.adjacentRoad(j[0], j[1])
+		if !ok {
+			continue
+		}
+		if d, ok := dist[[2]int{rx, ry}]; ok && (bestDist < 0 || d < bestDist) {
+			best, bestDist = j, d
+		}
 	}
+	return best, bestDist >= 0
 }
 
-func adjacentRoad(x, y int) (int, int, bool) {
+func (game *GameState) adjacentRoad(x, y int) (int, int, bool) {
 	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
 	for _, d := range dirs {
 		nx, ny := x+d[0], y+d[1]
-		if !inBounds(nx, ny) {
+		if !game.inBounds(nx, ny) {
 			continue
 		}
 		if game.Tiles[ny][nx].Road != nil {
@@ -1405,83 +7379,187 @@ func adjacentRoad(x, y int) (int, int, bool) {
 	return 0, 0, false
 }
 
-func updateCitizens(dt float64) {
+// rerouteAround re-paths every vehicle, road shipment and commuter whose remaining route crosses
+// the road tile (x,y), which was just removed. Vehicles and goods with no other way are removed
+// (goods are lost, as when blocked); buses are withdrawn for updateTransit to replace on the
+// rebuilt route; commuters who cannot reroute go straight home.
+func (game *GameState) rerouteAround(x, y int) {
+	gone := [2]int{x, y}
+	crosses := func(path [][2]int, from int) bool {
+		return from < len(path) && slices.Contains(path[from:], gone)
+	}
+	repath := func(px, py *float64, path *[][2]int, idx *int) bool {
+		return [2]int{int(*px + 0.5), int(*py + 0.5)} != gone && game.repath(px, py, path, idx)
+	}
+	vehicles := game.Vehicles[:0]
+	for _, v := range game.Vehicles {
+		if v.GroupID == 0 && crosses(v.Path, v.PathIndex) && (v.Kind == VehicleBus || !repath(&v.X, &v.Y, &v.Path, &v.PathIndex)) {
+			continue
+		}
+		vehicles = append(vehicles, v)
+	}
+	game.Vehicles = vehicles
+	goods := func(src []*GoodShipment) []*GoodShipment {
+		kept := src[:0]
+		for _, s := range src {
+			if !s.Train && crosses(s.Path, s.PathIndex) && !repath(&s.X, &s.Y, &s.Path, &s.PathIndex) {
+				continue
+			}
+			kept = append(kept, s)
+		}
+		return kept
+	}
+	game.GoodsIC = goods(game.GoodsIC)
+	game.GoodsCC = goods(game.GoodsCC)
+	groups := game.CitizenGroups[:0]
+	for _, g := range game.CitizenGroups {
+		if g.State != "working" && crosses(g.Path, g.PathIndex) && !game.rerouteGroup(g) {
+			game.returnCitizensHome(g)
+			continue
+		}
+		groups = append(groups, g)
+	}
+	game.CitizenGroups = groups
+}
+
+// repath snaps an entity to the tile it is on and routes it by road to the end of its path, the
+// way it was going; it reports false, leaving the entity as it was, when no route exists.
+func (game *GameState) repath(px, py *float64, path *[][2]int, idx *int) bool {
+	cur := [2]int{int(*px + 0.5), int(*py + 0.5)}
+	p := game.roadPath(cur, (*path)[len(*path)-1], 400)
+	if len(p) < 2 {
+		return false
+	}
+	*px, *py = float64(cur[0]), float64(cur[1])
+	*path, *idx = p[1:], 0
+	return true
+}
+
+// rerouteGroup sends g by road to where it is heading: home on the way back, else its destination.
+func (game *GameState) rerouteGroup(g *CitizenGroup) bool {
+	if g.State == "return" {
+		return game.reroute(g, g.OriginX, g.OriginY)
+	}
+	return game.reroute(g, g.DestX, g.DestY)
+}
+
+// reroute sends g by road from its current position to the building at (tx,ty), dropping any bus
+// trip, and reports whether a route exists.
+func (game *GameState) reroute(g *CitizenGroup, tx, ty int) bool {
+	cx, cy := int(g.X+0.5), int(g.Y+0.5)
+	// find road near current
+	crx, cry := cx, cy
+	if !(game.inBounds(crx, cry) && game.Tiles[cry][crx].Road != nil) {
+		rrx, rry, ok := game.adjacentRoad(cx, cy)
+		if !ok {
+			return false
+		}
+		crx, cry = rrx, rry
+	}
+	orx, ory, ok1 := game.adjacentRoad(tx, ty)
+	if !ok1 {
+		return false
+	}
+	p := game.roadPath([2]int{crx, cry}, [2]int{orx, ory}, 400)
+	if len(p) == 0 {
+		return false
+	}
+	// build full path excluding current tile coordinate
+	full := make([][2]int, 0, len(p)+1)
+	full = append(full, p...)
+	full = append(full, [2]int{tx, ty})
+	g.Path = full
+	g.PathIndex = 0
+	g.Transit = nil
+	return true
+}
+
+func (game *GameState) updateCitizens(dt float64) {
 	if len(game.CitizenGroups) == 0 {
 		return
 	}
 	speed := citizenSpeed * dt
+	buses := game.busesByID()
 	kept := game.CitizenGroups[:0]
 	for _, g := range game.CitizenGroups {
-		// Recovery: if destination/origin lost mid-transit, redirect
-		if g.State == "outbound" || g.State == "return" {
+		// Recovery: if destination/origin lost mid-trip or during the shift, redirect. Groups that
+		// cannot be rerouted are returned home directly, and dissolve only when they have no home left.
+		if g.State == "outbound" || g.State == "return" || g.State == "working" {
 			originTile := game.Tiles[g.OriginY][g.OriginX]
 			destTile := game.Tiles[g.DestY][g.DestX]
 			originValid := originTile.Building != nil && originTile.Building.Final
 			destValid := destTile.Building != nil && destTile.Building.Final
-			// helper to find open residential home if both invalid
-			findOpenResidential := func() (int, int, bool) {
-				for y := 0; y < game.Height; y++ {
-					for x := 0; x < game.Width; x++ {
-						if b := game.Tiles[y][x].Building; b != nil && b.Final && b.Type == Residential && b.Residents < 10 {
-							return x, y, true
-						}
-					}
+			if g.State != "return" && !destValid { // lost destination
+				if g.State == "working" { // sent home from the demolished job
+					destTile.Citizens = max(destTile.Citizens-g.Count, 0)
 				}
-				return 0, 0, false
-			}
-			rebuildPath := func(tx, ty int) bool {
-				cx, cy := int(g.X+0.5), int(g.Y+0.5)
-				// find road near current
-				crx, cry := cx, cy
-				if !(inBounds(crx, cry) && game.Tiles[cry][crx].Road != nil) {
-					rrx, rry, ok := adjacentRoad(cx, cy)
-					if !ok {
-						return false
-					}
-					crx, cry = rrx, rry
-				}
-				orx, ory, ok1 := adjacentRoad(tx, ty)
-				if !ok1 {
-					return false
-				}
-				p := roadPath([2]int{crx, cry}, [2]int{orx, ory}, 400)
-				if len(p) == 0 {
-					return false
-				}
-				// build full path excluding current tile coordinate
-				full := make([][2]int, 0, len(p)+1)
-				full = append(full, p...)
-				full = append(full, [2]int{tx, ty})
-				g.Path = full
-				g.PathIndex = 0
-				return true
-			}
-			if g.State == "outbound" && !destValid { // lost destination
-				if originValid { // go back home
-					if !rebuildPath(g.OriginX, g.OriginY) {
-						continue
-					}
-					g.State = "return"
-				} else { // both gone - resettle
-					nx, ny, ok := findOpenResidential()
+				if !originValid { // both gone - resettle
+					nx, ny, ok := game.openResidential()
 					if !ok {
 						continue
-					} // drop if nowhere to go
+					} // dissolve if nowhere to go
 					g.OriginX, g.OriginY = nx, ny
-					if !rebuildPath(nx, ny) {
-						continue
-					}
-					g.State = "return"
+				}
+				g.State = "return"
+				if !game.reroute(g, g.OriginX, g.OriginY) {
+					game.returnCitizensHome(g)
+					continue
 				}
 			} else if g.State == "return" && !originValid { // lost origin while returning
-				nx, ny, ok := findOpenResidential()
+				nx, ny, ok := game.openResidential()
 				if !ok {
 					continue
 				}
 				g.OriginX, g.OriginY = nx, ny
-				if !rebuildPath(nx, ny) {
+				if !game.reroute(g, nx, ny) {
+					game.returnCitizensHome(g)
+					continue
+				}
+			}
+		}
+		// visitors whose shop closes leave the city
+		if g.State == "visiting" || g.State == "shopping" {
+			if b := game.Tiles[g.DestY][g.DestX].Building; b == nil || !b.Final || b.Type != Commercial {
+				continue
+			}
+		}
+		if g.State == "shopping" {
+			if g.Timer -= dt; g.Timer > 0 {
+				kept = append(kept, g)
+			} else { // done; fly home
+				dest := game.Tiles[g.DestY][g.DestX]
+				dest.Citizens = max(dest.Citizens-g.Count, 0)
+			}
+			continue
+		}
+		// newcomers whose home is gone look for another, or give up and leave the city
+		if g.State == "inbound" {
+			if b := game.Tiles[g.DestY][g.DestX].Building; b == nil || !b.Final || b.Type != Residential {
+				nx, ny, ok := game.openResidential()
+				if !ok || !game.reroute(g, nx, ny) {
 					continue
 				}
+				g.DestX, g.DestY = nx, ny
+			}
+		}
+		// at the stop: ride along with the bus, or wait for one while the route runs
+		if tr := g.Transit; tr != nil && !tr.Alighted && g.PathIndex >= len(g.Path) && (g.State == "outbound" || g.State == "return") {
+			if bus := buses[tr.Bus]; bus != nil {
+				g.X, g.Y = bus.X, bus.Y
+				kept = append(kept, g)
+				continue
+			}
+			if tr.Bus == 0 && game.busRoute(tr.Route) != nil {
+				kept = append(kept, g)
+				continue
+			}
+			tx, ty := g.DestX, g.DestY // bus or route withdrawn: go on by road
+			if g.State == "return" {
+				tx, ty = g.OriginX, g.OriginY
+			}
+			if !game.reroute(g, tx, ty) {
+				game.returnCitizensHome(g)
+				continue
 			}
 		}
 		prevX, prevY := g.X, g.Y
@@ -1490,13 +7568,19 @@ func updateCitizens(dt float64) {
 			if g.Timer <= 0 { // start return trip
 				// build return path (reverse) origin path: current position is at destination tile
 				// path back: destination adjacent road -> ... -> origin adjacent road -> origin tile
-				drx, dry, ok2 := adjacentRoad(g.DestX, g.DestY)
-				orx, ory, ok1 := adjacentRoad(g.OriginX, g.OriginY)
+				drx, dry, ok2 := game.adjacentRoad(g.DestX, g.DestY)
+				orx, ory, ok1 := game.adjacentRoad(g.OriginX, g.OriginY)
 				if ok1 && ok2 {
-					roadSeg := roadPath([2]int{drx, dry}, [2]int{orx, ory}, 400)
+					roadSeg := game.roadPath([2]int{drx, dry}, [2]int{orx, ory}, 400)
 					revPath := make([][2]int, 0, len(roadSeg)+2)
-					revPath = append(revPath, roadSeg...)
-					revPath = append(revPath, [2]int{g.OriginX, g.OriginY})
+					if tr, walk := game.planTransit([2]int{drx, dry}, [2]int{g.DestX, g.DestY}, [2]int{g.OriginX, g.OriginY}); tr != nil {
+						revPath = append(revPath, walk...) // to the stop; the rest of the way is by bus
+						g.Transit = tr
+					} else {
+						revPath = append(revPath, roadSeg...)
+						revPath = append(revPath, [2]int{g.OriginX, g.OriginY})
+						g.Transit = nil
+					}
 					g.Path = revPath
 					g.PathIndex = 0
 					g.State = "return"
@@ -1506,7 +7590,9 @@ func updateCitizens(dt float64) {
 					if destTile.Citizens < 0 {
 						destTile.Citizens = 0
 					}
-				} else { // can't find path back -> drop group
+				} else { // can't find path back -> teleport home rather than lose them
+					log.Printf("citizens: group %d has no route home from (%d,%d); returning %d to (%d,%d)", g.ID, g.DestX, g.DestY, g.Count, g.OriginX, g.OriginY)
+					game.returnCitizensHome(g)
 					continue
 				}
 			} else {
@@ -1515,15 +7601,25 @@ func updateCitizens(dt float64) {
 			}
 		}
 		if g.PathIndex < len(g.Path) {
-			remain := speed
+			remain := speed * game.congestionFactor(g.X, g.Y) // commuters are held up on busy roads too
+			blocked, rerouted := false, false
 			for remain > 0 && g.PathIndex < len(g.Path) {
 				tgt := g.Path[g.PathIndex]
+				if !game.pathStepAllowed(g.X, g.Y, tgt) { // made one-way under us
+					if !rerouted && game.rerouteGroup(g) {
+						rerouted = true
+						continue
+					}
+					blocked = true
+					break
+				}
 				tx, ty := float64(tgt[0]), float64(tgt[1])
 				dx, dy := tx-g.X, ty-g.Y
 				dist := abs(dx) + abs(dy)
 				if dist <= remain {
 					g.X, g.Y = tx, ty
 					g.PathIndex++
+					game.addHeat(tgt, float64(g.Count))
 					remain -= dist
 				} else {
 					if dx != 0 {
@@ -1534,6 +7630,10 @@ func updateCitizens(dt float64) {
 					remain = 0
 				}
 			}
+			if blocked { // no way on by road
+				game.returnCitizensHome(g)
+				continue
+			}
 		}
 		// stuck detection: if not working and has remaining path but hasn't moved for many cycles -> drop
 		if g.State != "working" && g.PathIndex < len(g.Path) {
@@ -1543,14 +7643,17 @@ func updateCitizens(dt float64) {
 				g.StuckTicks = 0
 			}
 			if g.StuckTicks > 40 { // ~4s real time
+				game.returnCitizensHome(g)
 				continue // do not keep
 			}
 		}
 		// arrival handling
 		if g.PathIndex >= len(g.Path) {
-			if g.State == "outbound" { // arrived at destination
+			if tr := g.Transit; tr != nil && !tr.Alighted { // reached the stop; wait for the bus
+				kept = append(kept, g)
+			} else if g.State == "outbound" { // arrived at destination
 				g.State = "working"
-				g.Timer = 5 + rand.Float64()*10 // 5-15 seconds
+				g.Timer = game.workShift()
 				destTile := game.Tiles[g.DestY][g.DestX]
 				// If destination is commercial with zero supplies and zero employees, citizens give up and leave city (do not add to tile)
 				if destTile.Building != nil && destTile.Building.Type == Commercial && destTile.Building.Supplies == 0 && destTile.Building.Employees == 0 {
@@ -1563,6 +7666,19 @@ func updateCitizens(dt float64) {
 				originTile := game.Tiles[g.OriginY][g.OriginX]
 				originTile.Citizens += g.Count
 				// group finished; not kept
+			} else if g.State == "visiting" { // visitors shop, joining the shop's customers
+				g.State = "shopping"
+				g.Timer = visitorShopping
+				game.Tiles[g.DestY][g.DestX].Citizens += g.Count
+				kept = append(kept, g)
+			} else if g.State == "inbound" { // newcomers move in; any the home has no room for leave
+				home := game.Tiles[g.DestY][g.DestX]
+				if b := home.Building; b != nil && b.Final && b.Type == Residential && b.Residents < maxResidents {
+					n := min(g.Count, maxResidents-b.Residents)
+					b.Residents += n
+					home.Citizens += n
+					game.movedIn = append(game.movedIn, [2]int{g.DestX, g.DestY})
+				}
 			} else {
 				kept = append(kept, g)
 			}
@@ -1573,49 +7689,209 @@ func updateCitizens(dt float64) {
 	game.CitizenGroups = kept
 }
 
-// ================= AI BOT =================
-const (
-	aiActionInterval    = 4    // act more frequently
-	aiZoneAttempts      = 2    // zoning still conservative
-	aiRoadExtendChance  = 0.9  // most cycles attempt a road push
-	aiZoneAfterRoadBias = 0.35 // slightly lower zoning after road (focus on corridor first)
-	aiMaxRoadAttempts   = 3    // try multiple successive extensions per action
-)
+// openResidential returns the first finished home, in tile order, with room for another resident.
+func (game *GameState) openResidential() (int, int, bool) {
+	for _, t := range game.tiles().residential {
+		if b := t.Building; b != nil && b.Final && b.Type == Residential && b.Residents < maxResidents {
+			return t.X, t.Y, true
+		}
+	}
+	return 0, 0, false
+}
 
-func createBotLocked() {
-	if game.BotID != "" {
-		return
+// reconcileCitizens recomputes every tile's Citizens from the authoritative counts, undoing any
+// drift from the incremental updates made as groups travel: a home holds its residents minus those
+// out on a trip, a workplace holds the groups working there, and any other tile holds nobody.
+func (game *GameState) reconcileCitizens() {
+	out := map[[2]int]int{}
+	working := map[[2]int]int{}
+	for _, g := range game.CitizenGroups {
+		if g.State == "shopping" {
+			working[[2]int{g.DestX, g.DestY}] += g.Count
+		}
+		if g.State == "inbound" || g.State == "visiting" || g.State == "shopping" { // not residents
+			continue
+		}
+		out[[2]int{g.OriginX, g.OriginY}] += g.Count
+		if g.State == "working" {
+			working[[2]int{g.DestX, g.DestY}] += g.Count
+		}
+	}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			k := [2]int{x, y}
+			switch b := t.Building; {
+			case b == nil:
+				t.Citizens = 0
+			case b.Type == Residential:
+				t.Citizens = max(b.Residents-out[k], 0)
+			default:
+				t.Citizens = working[k]
+			}
+		}
 	}
-	id := PlayerID(uuid.New().String())
-	game.Players[id] = &Player{ID: id, Name: "Planner", Money: 50000}
-	game.BotID = id
-	log.Println("AI bot created", id)
 }
 
-func aiTick() {
-	if game.BotID == "" {
+// returnCitizensHome ends a group's trip without it travelling, moving its citizens off the
+// destination tile (if working there) and back onto the origin tile so the population is kept.
+// Newcomers and visitors have no home here and simply leave the city.
+func (game *GameState) returnCitizensHome(g *CitizenGroup) {
+	if g.State == "inbound" || g.State == "visiting" {
 		return
 	}
-	if game.Tick-game.AILastAction < aiActionInterval {
+	if g.State == "working" {
+		destTile := game.Tiles[g.DestY][g.DestX]
+		destTile.Citizens = max(destTile.Citizens-g.Count, 0)
+	}
+	game.Tiles[g.OriginY][g.OriginX].Citizens += g.Count
+}
+
+// ================= AI BOT =================
+// botProfile tunes how a planner bot splits its effort between roads and zones.
+type botProfile struct {
+	ZoneAttempts      int              // zone placements tried per action
+	RoadExtendChance  float64          // chance each action pushes the road network
+	ZoneAfterRoadBias float64          // chance of also zoning in an action that built road
+	RoadAttempts      int              // successive road extensions per push
+	ZoneBias          map[ZoneType]int // added to each zone type's demand score
+}
+
+// botProfiles are the selectable bot strategies; "balanced" is the original planner.
+var botProfiles = map[string]botProfile{
+	"balanced":            {ZoneAttempts: 2, RoadExtendChance: 0.9, ZoneAfterRoadBias: 0.35, RoadAttempts: 3, ZoneBias: map[ZoneType]int{Commercial: 5}},
+	"road-heavy":          {ZoneAttempts: 1, RoadExtendChance: 1, ZoneAfterRoadBias: 0.2, RoadAttempts: 6, ZoneBias: map[ZoneType]int{Commercial: 5}},
+	"residential-focused": {ZoneAttempts: 3, RoadExtendChance: 0.7, ZoneAfterRoadBias: 0.6, RoadAttempts: 2, ZoneBias: map[ZoneType]int{Residential: 15}},
+}
+
+// maxBots caps the planner bots in a room.
+const maxBots = 8
+
+// botStrategies lists the bots each new room starts with, one per entry of the comma-separated
+// CITYSIM_BOTS (default "balanced"; "none" starts without bots). Unknown names are skipped.
+var botStrategies = parseBotStrategies(os.Getenv("CITYSIM_BOTS"))
+
+func parseBotStrategies(v string) []string {
+	if v == "" {
+		return []string{"balanced"}
+	}
+	if v == "none" {
+		return nil
+	}
+	var out []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := botProfiles[name]; !ok {
+			log.Printf("ignoring unknown bot strategy in CITYSIM_BOTS: %q", name)
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func (game *GameState) createBotLocked(strategy string, money int) {
+	id := PlayerID(uuid.Must(uuid.NewRandomFromReader(game.rng)).String()) // from the game RNG, so replays match
+	name := "Planner"
+	if n := len(game.BotIDs); n > 0 {
+		name = fmt.Sprintf("Planner %d", n+1)
+	}
+	game.Players[id] = &Player{ID: id, Name: name, Money: money, Bot: true, Strategy: strategy}
+	game.BotIDs = append(game.BotIDs, id)
+	log.Println("AI bot created", id, strategy)
+}
+
+// addBot starts another planner bot in the room.
+func (r *Room) addBot(p AddBotPayload) string {
+	if p.Strategy == "" {
+		p.Strategy = "balanced"
+	}
+	if _, ok := botProfiles[p.Strategy]; !ok {
+		return ReasonInvalidType
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	if len(game.BotIDs) >= maxBots {
+		return ReasonBotLimit
+	}
+	game.createBotLocked(p.Strategy, botMoney)
+	game.announce(EventPlayerJoined, game.Players[game.BotIDs[len(game.BotIDs)-1]].info())
+	return ""
+}
+
+// removeBot deletes a bot player and demolishes its zones, buildings and structures. Its roads stay,
+// since the rest of the city may depend on them.
+func (r *Room) removeBot(p RemoveBotPayload) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game := r.game
+	i := slices.Index(game.BotIDs, p.ID)
+	if i < 0 {
+		return ReasonNoPlayer
+	}
+	game.BotIDs = slices.Delete(game.BotIDs, i, i+1)
+	delete(game.Players, p.ID)
+	delete(game.Budgets, p.ID)
+	delete(game.Trade, p.ID)
+	tiles := []*Tile{}
+	for _, row := range game.Tiles {
+		for _, t := range row {
+			changed := false
+			before := game.layersAt(t.X, t.Y)
+			if t.Zone != nil && t.Zone.Owner == p.ID {
+				t.Zone, t.Building = nil, nil
+				changed = true
+			}
+			if t.Structure != nil && t.Structure.Owner == p.ID {
+				t.Structure = nil
+				changed = true
+			}
+			if changed {
+				game.markTile(t)
+				game.logLayers(t.X, t.Y, before, ActionRemoveBot, p.ID)
+				tiles = append(tiles, t)
+			}
+		}
+	}
+	game.announce(EventBotRemoved, struct {
+		ID    PlayerID `json:"id"`
+		Tiles []*Tile  `json:"tiles"`
+	}{p.ID, tiles})
+	return ""
+}
+
+func (game *GameState) aiTick() {
+	if game.Tick-game.AILastAction < game.Config.AIActionInterval {
 		return
 	}
-	p := game.Players[game.BotID]
-	if p == nil || p.Money < 200 {
+	for _, id := range game.BotIDs {
+		if p := game.Players[id]; p != nil {
+			game.aiAct(p, botProfiles[p.Strategy])
+		}
+	}
+}
+
+// aiAct runs one planning step for bot p.
+func (game *GameState) aiAct(p *Player, prof botProfile) {
+	if p.Money < 200 {
 		return
 	}
-	ensureSomeRoads(p)
+	game.ensureSomeRoads(p)
+	game.aiConnectEdge(p)
+	// Rejoining split networks comes first so commuters and trucks can route between them
+	roadDone := game.rng.Float64() < game.Config.AIBridgeChance && game.aiBridgeRoads(p)
 	// Decide whether to extend road first; higher frequency keeps corridors open
-	roadDone := false
-	if rand.Float64() < aiRoadExtendChance {
-		extendRoadIfNeeded(p)
+	if !roadDone && game.rng.Float64() < prof.RoadExtendChance {
+		game.extendRoadIfNeeded(p, prof)
 		roadDone = true
 	}
 	// Only zone if we did not build a road OR we allow a zone after road based on bias.
-	if !roadDone || rand.Float64() < aiZoneAfterRoadBias {
-		z := pickZoneTypeByDemand()
+	if !roadDone || game.rng.Float64() < prof.ZoneAfterRoadBias {
+		z := game.pickZoneTypeByDemand(prof)
 		placed := 0
-		for i := 0; i < aiZoneAttempts; i++ {
-			x, y, ok := findZoneSpotNearRoad()
+		for i := 0; i < prof.ZoneAttempts; i++ {
+			x, y, ok := game.findZoneSpotNearRoad(z == Residential)
 			if !ok {
 				break
 			}
@@ -1625,19 +7901,22 @@ func aiTick() {
 				}
 			}
 			// Skip spot if zoning here would fully encase a single-road corridor (leave at least one orthogonal empty neighbor)
-			if encasesRoad(x, y) {
+			if game.encasesRoad(x, y) {
 				continue
 			}
-			if aiPlaceZone(p, x, y, z) {
+			if game.aiPlaceZone(p, x, y, z) {
 				placed++
 			}
 		}
 	}
+	game.aiEnsureWater(p)
+	game.aiEnsurePower(p)
 	// AI tick done
 }
 
-// pickZoneTypeByDemand chooses the highest current demand; ties favor Residential -> Commercial -> Industrial
-func pickZoneTypeByDemand() ZoneType {
+// pickZoneTypeByDemand chooses the highest current demand plus the profile's bias; ties favor
+// Residential -> Commercial -> Industrial
+func (game *GameState) pickZoneTypeByDemand(prof botProfile) ZoneType {
 	d := game.Demand
 	unemployed := game.Population - game.Employed
 	if unemployed < 0 {
@@ -1658,25 +7937,26 @@ func pickZoneTypeByDemand() ZoneType {
 	openRes := resCap - resUsed
 
 	// Base scores from raw demand values
-	rScore := d.Residential
-	cScore := d.Commercial + 5 // +5% commercial bias
-	iScore := d.Industrial
+	cfg := game.Config
+	rScore := d.Residential + prof.ZoneBias[Residential]
+	cScore := d.Commercial + prof.ZoneBias[Commercial] + cfg.AICommercialBias
+	iScore := d.Industrial + prof.ZoneBias[Industrial] + cfg.AIIndustrialBias
 
 	// Penalize industrial if already high relative to unemployment (avoid overbuilding I when no workers idle)
 	if unemployed < 5 {
-		iScore -= 8 // strong penalty when virtually no idle workers
+		iScore -= cfg.AINoIdleWorkersPenalty // strong penalty when virtually no idle workers
 	} else if unemployed < 15 {
-		iScore -= 4
+		iScore -= cfg.AIFewIdleWorkersPenalty
 	}
 	// Encourage residential if housing is tight
 	if openRes <= 0 { // totally full
-		rScore += 10
+		rScore += cfg.AIFullHousingBonus
 	} else if openRes < 10 {
-		rScore += 5
+		rScore += cfg.AITightHousingBonus
 	}
 	// Mild encouragement for commercial if some unemployed exist but housing not critically tight
 	if unemployed > 10 && openRes > 5 {
-		cScore += 2
+		cScore += cfg.AIIdleWorkersCommercialBonus
 	}
 
 	// Pick highest score; ties favor Residential then Commercial then Industrial
@@ -1692,7 +7972,9 @@ func pickZoneTypeByDemand() ZoneType {
 	return best
 }
 
-func findZoneSpotNearRoad() (int, int, bool) {
+// findZoneSpotNearRoad picks a free tile beside a random served road. With preferWaterfront, a free
+// waterfront tile beside any served road is taken over the first one found.
+func (game *GameState) findZoneSpotNearRoad(preferWaterfront bool) (int, int, bool) {
 	roads := make([][2]int, 0)
 	for y := 0; y < game.Height; y++ {
 		for x := 0; x < game.Width; x++ {
@@ -1706,40 +7988,362 @@ func findZoneSpotNearRoad() (int, int, bool) {
 	}
 	// partial shuffle
 	for i := 0; i < len(roads) && i < 32; i++ {
-		j := rand.Intn(len(roads))
+		j := game.rng.Intn(len(roads))
 		roads[i], roads[j] = roads[j], roads[i]
 	}
+	served := game.servedRoads()
 	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	var spot [2]int
+	found := false
 	for _, r := range roads {
+		if !served[r] {
+			continue
+		}
 		for _, d := range dirs {
 			nx, ny := r[0]+d[0], r[1]+d[1]
-			if !inBounds(nx, ny) {
+			if !game.inBounds(nx, ny) {
 				continue
 			}
 			t := game.Tiles[ny][nx]
 			if t.Zone == nil && t.Road == nil && t.Structure == nil && t.Terrain != "water" {
-				return nx, ny, true
+				if !preferWaterfront || game.waterfront(nx, ny) {
+					return nx, ny, true
+				}
+				if !found {
+					spot, found = [2]int{nx, ny}, true
+				}
 			}
 		}
 	}
-	return 0, 0, false
+	return spot[0], spot[1], found
 }
 
-func aiPlaceZone(p *Player, x, y int, z ZoneType) bool {
+func (game *GameState) aiPlaceZone(p *Player, x, y int, z ZoneType) bool {
 	t := game.Tiles[y][x]
-	if t.Zone != nil || t.Road != nil || t.Structure != nil || t.Terrain == "water" {
+	if t.Zone != nil || t.Road != nil || t.Rail != nil || t.Structure != nil || t.Terrain == "water" || game.zoningConflict(x, y, z) != "" {
+		return false
+	}
+	if !game.hasServedRoad(x, y, game.servedRoads()) {
+		return false
+	}
+	cost, ok := game.placementCost(zoneCost, x, y)
+	if !ok || p.Money < cost {
+		return false
+	}
+	p.Money -= cost
+	t.Zone = &Zone{Type: z, Owner: p.ID, PlacedAt: game.unixNow()}
+	game.markTile(t)
+	game.logTile(x, y, "zone", "placed", "bot", p.ID)
+	game.announce(EventZonePlaced, ZonePlacedEvent{X: x, Y: y, Zone: t.Zone})
+	return true
+}
+
+// aiEnsureWater builds a water tower beside the first of the bot's unwatered buildings, on an empty
+// tile next to developed land so the pipes connect, keeping Config.AIWaterReserve in hand.
+func (game *GameState) aiEnsureWater(p *Player) {
+	game.aiPlaceUtility(p, "water_tower", "", game.Config.AIWaterReserve, func(b *Building) bool { return !b.Watered })
+}
+
+// aiPlant is the power plant type bots build: clean, so it doesn't spoil the land beside their homes.
+const aiPlant = "solar"
+
+// aiEnsurePower builds a power plant beside the first of the bot's browned-out finished buildings
+// while demand exceeds supply, keeping Config.AIPowerReserve in hand. Buildings finished since the
+// last updatePower are not yet powered but not yet short either, so the totals decide.
+func (game *GameState) aiEnsurePower(p *Player) {
+	if game.PowerDemand <= game.PowerSupply {
+		return
+	}
+	game.aiPlaceUtility(p, "power_plant", aiPlant, game.Config.AIPowerReserve, func(b *Building) bool {
+		return b.Final && b.AbandonPhase == 0 && !b.Powered
+	})
+}
+
+// aiPlaceUtility places a kind structure (of plant type plant for a power plant) on an empty tile
+// next to developed land near the first of the bot's buildings that needs it, keeping reserve in
+// hand. It makes one attempt per AI action.
+func (game *GameState) aiPlaceUtility(p *Player, kind, plant string, reserve int, needs func(b *Building) bool) {
+	spec := structureSpecs[kind]
+	if plant != "" {
+		spec = plantSpecs[plant]
+	}
+	if p.Money < spec.Cost+reserve {
+		return
+	}
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			t := game.Tiles[y][x]
+			if t.Building == nil || !needs(t.Building) || t.Zone == nil || t.Zone.Owner != p.ID {
+				continue
+			}
+			for r := 1; r <= 3; r++ {
+				for dy := -r; dy <= r; dy++ {
+					for dx := -r; dx <= r; dx++ {
+						nx, ny := x+dx, y+dy
+						if absInt(dx)+absInt(dy) != r || !game.inBounds(nx, ny) {
+							continue
+						}
+						n := game.Tiles[ny][nx]
+						if developed(n) || n.Terrain == "water" || !game.touchesDeveloped(nx, ny) {
+							continue
+						}
+						cost, ok := game.placementCost(spec.Cost, nx, ny)
+						if !ok || p.Money < cost+reserve {
+							continue
+						}
+						p.Money -= cost
+						n.Foliage = ""
+						n.Structure = &Structure{Type: kind, Plant: plant, Capacity: spec.Capacity, Owner: p.ID, PlacedAt: game.unixNow()}
+						game.markTile(n)
+						game.logTile(nx, ny, "structure", "placed", "bot", p.ID)
+						game.announce(EventStructurePlaced, struct {
+							X         int        `json:"x"`
+							Y         int        `json:"y"`
+							Structure *Structure `json:"structure"`
+						}{nx, ny, n.Structure})
+						return
+					}
+				}
+			}
+			return // one attempt per AI action
+		}
+	}
+}
+
+// servedRoads is the set of road tiles whose network reaches at least one building, so zones
+// beside them can trade with the rest of the city. Before anything is built, every network longer
+// than a single stub counts.
+func (game *GameState) servedRoads() map[[2]int]bool {
+	comps := game.roadComponents()
+	anyBuilding := false
+	served := make(map[[2]int]bool)
+	for _, comp := range comps {
+		for _, c := range comp {
+			if game.touchesBuilding(c[0], c[1]) {
+				anyBuilding = true
+				for _, r := range comp {
+					served[r] = true
+				}
+				break
+			}
+		}
+	}
+	if !anyBuilding {
+		for _, comp := range comps {
+			if len(comp) > 1 {
+				for _, r := range comp {
+					served[r] = true
+				}
+			}
+		}
+	}
+	return served
+}
+
+func (game *GameState) touchesBuilding(x, y int) bool {
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if nx, ny := x+d[0], y+d[1]; game.inBounds(nx, ny) && game.Tiles[ny][nx].Building != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasServedRoad reports whether (x,y) borders a road in served.
+func (game *GameState) hasServedRoad(x, y int, served map[[2]int]bool) bool {
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if served[[2]int{x + d[0], y + d[1]}] {
+			return true
+		}
+	}
+	return false
+}
+
+// roadNetwork labels each road tile with its connected component. It is cached and rebuilt only
+// after a road is placed, bulldozed or restored by undo.
+type roadNetwork struct {
+	comp map[[2]int]int // road tile -> component
+	size []int          // road tiles per component
+}
+
+// roadNetworkDebounce is how long the roads must stay unchanged before road_network_changed is
+// sent, so a burst of placements (a dragged road, a bot's street) is announced once.
+const roadNetworkDebounce = 500 * time.Millisecond
+
+// roadsChanged drops the cached road network after a road is added or removed and (re)starts the
+// road_network_changed debounce.
+func (game *GameState) roadsChanged() {
+	game.roadNet = nil
+	game.roadsDirty = true
+	game.roadsQuiet = 0
+}
+
+// announceRoadNetwork sends road_network_changed once the roads have been quiet for
+// roadNetworkDebounce of wall time. Called every traffic frame, paused or not.
+func (game *GameState) announceRoadNetwork(elapsed time.Duration) {
+	if !game.roadsDirty {
+		return
+	}
+	if game.roadsQuiet += elapsed; game.roadsQuiet < roadNetworkDebounce {
+		return
+	}
+	game.roadsDirty = false
+	net := game.roadNetwork()
+	game.announce(EventRoadNetworkChanged, struct {
+		Components int `json:"components"`
+		Roads      int `json:"roads"`
+	}{len(net.size), len(net.comp)})
+}
+
+func (game *GameState) roadNetwork() *roadNetwork {
+	if game.roadNet != nil {
+		return game.roadNet
+	}
+	comps := game.roadComponents()
+	net := &roadNetwork{comp: map[[2]int]int{}, size: make([]int, len(comps))}
+	for i, c := range comps {
+		for _, k := range c {
+			net.comp[k] = i
+		}
+		net.size[i] = len(c)
+	}
+	game.roadNet = net
+	return net
+}
+
+// strandedFraction is the share of finished buildings cut off from the other end of the commute:
+// homes whose roads reach no job, and jobs whose roads reach no home. 0 with no buildings.
+func (game *GameState) strandedFraction() float64 {
+	net := game.roadNetwork()
+	type lot struct {
+		home  bool
+		comps []int
+	}
+	lots := []lot{}
+	homes, jobs := map[int]bool{}, map[int]bool{}
+	for _, t := range game.tiles().buildings {
+		b := t.Building
+		if b == nil || !b.Final {
+			continue
+		}
+		l := lot{home: b.Type == Residential}
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			if c, ok := net.comp[[2]int{t.X + d[0], t.Y + d[1]}]; ok {
+				l.comps = append(l.comps, c)
+				if l.home {
+					homes[c] = true
+				} else {
+					jobs[c] = true
+				}
+			}
+		}
+		lots = append(lots, l)
+	}
+	if len(lots) == 0 {
+		return 0
+	}
+	stranded := 0
+	for _, l := range lots {
+		other := homes
+		if l.home {
+			other = jobs
+		}
+		if !slices.ContainsFunc(l.comps, func(c int) bool { return other[c] }) {
+			stranded++
+		}
+	}
+	return float64(stranded) / float64(len(lots))
+}
+
+// roadComponents groups road tiles into 4-connected networks, in scan order.
+func (game *GameState) roadComponents() [][][2]int {
+	seen := make(map[[2]int]bool)
+	var comps [][][2]int
+	for y := 0; y < game.Height; y++ {
+		for x := 0; x < game.Width; x++ {
+			if game.Tiles[y][x].Road == nil || seen[[2]int{x, y}] {
+				continue
+			}
+			seen[[2]int{x, y}] = true
+			comp := [][2]int{{x, y}}
+			for i := 0; i < len(comp); i++ {
+				for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					n := [2]int{comp[i][0] + d[0], comp[i][1] + d[1]}
+					if !game.inBounds(n[0], n[1]) || seen[n] || game.Tiles[n[1]][n[0]].Road == nil {
+						continue
+					}
+					seen[n] = true
+					comp = append(comp, n)
+				}
+			}
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
+
+// aiBridgeRoads joins the smallest road fragment to the nearest tile of any other network with a
+// route over open land, if it is short and affordable. It reports whether any road was built.
+func (game *GameState) aiBridgeRoads(p *Player) bool {
+	comps := game.roadComponents()
+	if len(comps) < 2 {
+		return false
+	}
+	small := 0
+	for i, c := range comps {
+		if len(c) < len(comps[small]) {
+			small = i
+		}
+	}
+	var from, to [2]int
+	best := -1
+	for i, c := range comps {
+		if i == small {
+			continue
+		}
+		for _, a := range comps[small] {
+			for _, b := range c {
+				if d := absInt(a[0]-b[0]) + absInt(a[1]-b[1]); best < 0 || d < best {
+					best, from, to = d, a, b
+				}
+			}
+		}
+	}
+	route := game.roadRoute(from[0], from[1], to[0], to[1])
+	if route == nil {
 		return false
 	}
-	if p.Money < 100 {
+	var build [][2]int
+	total := 0
+	for _, c := range route {
+		if game.Tiles[c[1]][c[0]].Road == nil {
+			cost, ok := game.placementCost(roadCosts[RoadLocal], c[0], c[1])
+			if !ok {
+				return false
+			}
+			build = append(build, c)
+			total += cost
+		}
+	}
+	if len(build) == 0 || len(build) > game.Config.AIMaxBridgeLen || p.Money < total+200 {
 		return false
 	}
-	p.Money -= 100
-	t.Zone = &Zone{Type: z, Owner: p.ID, PlacedAt: time.Now().Unix()}
-	announce(EventZonePlaced, ZonePlacedEvent{X: x, Y: y, Zone: t.Zone})
+	for _, c := range build {
+		game.aiPlaceRoad(p, c[0], c[1])
+	}
 	return true
 }
 
-func ensureSomeRoads(p *Player) {
+func (game *GameState) touchesDeveloped(x, y int) bool {
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if nx, ny := x+d[0], y+d[1]; game.inBounds(nx, ny) && developed(game.Tiles[ny][nx]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (game *GameState) ensureSomeRoads(p *Player) {
 	count := 0
 	for y := 0; y < game.Height; y++ {
 		for x := 0; x < game.Width; x++ {
@@ -1753,47 +8357,205 @@ func ensureSomeRoads(p *Player) {
 	}
 	cx, cy := game.Width/2, game.Height/2
 	for dx := -3; dx <= 3; dx++ {
-		aiPlaceRoad(p, cx+dx, cy)
+		game.aiPlaceRoad(p, cx+dx, cy)
 	}
 	for dy := -3; dy <= 3; dy++ {
-		aiPlaceRoad(p, cx, cy+dy)
+		game.aiPlaceRoad(p, cx, cy+dy)
+	}
+}
+
+// aiEdgeRoadSteps is how many road tiles a bot lays toward the map edge per action.
+const aiEdgeRoadSteps = 4
+
+// aiConnectEdge runs a straight road toward the nearest map edge from roads that cannot be reached
+// from the edge, since newcomers only arrive by road from there. Runs crossing water or zoned land
+// are skipped in favor of the next nearest.
+func (game *GameState) aiConnectEdge(p *Player) {
+	fromEdge := game.edgeRoutes()
+	type run struct {
+		from   *Tile
+		dx, dy int
+		length int
+	}
+	var runs []run
+	for _, t := range game.tiles().roads {
+		if fromEdge([2]int{t.X, t.Y}) != nil {
+			continue
+		}
+		runs = append(runs, run{t, -1, 0, t.X}, run{t, 1, 0, game.Width - 1 - t.X}, run{t, 0, -1, t.Y}, run{t, 0, 1, game.Height - 1 - t.Y})
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].length < runs[j].length })
+	clear := func(r run) bool {
+		for i := 1; i <= r.length; i++ {
+			t := game.Tiles[r.from.Y+r.dy*i][r.from.X+r.dx*i]
+			if t.Road == nil && (t.Zone != nil || t.Structure != nil || t.Rail != nil || t.Terrain == "water") {
+				return false
+			}
+		}
+		return true
+	}
+	for _, r := range runs {
+		if !clear(r) {
+			continue
+		}
+		laid := 0
+		for i := 1; i <= r.length && laid < aiEdgeRoadSteps; i++ {
+			x, y := r.from.X+r.dx*i, r.from.Y+r.dy*i
+			if game.Tiles[y][x].Road != nil {
+				continue
+			}
+			if !game.aiPlaceRoad(p, x, y) {
+				break
+			}
+			laid++
+		}
+		if laid > 0 {
+			return
+		}
 	}
 }
 
 // (Removed legacy BFS-based extendRoadIfNeeded; linear version defined earlier)
 
-func aiPlaceRoad(p *Player, x, y int) bool {
-	if !inBounds(x, y) {
+func (game *GameState) aiPlaceRoad(p *Player, x, y int) bool {
+	if game.placeRoadTile(p, x, y, DirNone, RoadLocal) != "" {
 		return false
 	}
+	game.logTile(x, y, "road", "placed", "bot", p.ID)
+	return true
+}
+
+// placeRoadTile places a road for p at (x,y), charging its cost, and returns a Reason code on failure.
+// Shared by players and the AI.
+func (game *GameState) placeRoadTile(p *Player, x, y int, dir RoadDirection, kind RoadKind) string {
+	if reason := game.buildRoadTile(p, x, y, dir, kind); reason != "" {
+		return reason
+	}
+	game.announce(EventRoadPlaced, RoadPlacedEvent{X: x, Y: y, Road: game.Tiles[y][x].Road})
+	return ""
+}
+
+// buildRoadTile charges for and lays a road tile without announcing it.
+func (game *GameState) buildRoadTile(p *Player, x, y int, dir RoadDirection, kind RoadKind) string {
+	if !game.inBounds(x, y) {
+		return ReasonOutOfBounds
+	}
 	t := game.Tiles[y][x]
-	if t.Road != nil || t.Zone != nil || t.Structure != nil || t.Terrain == "water" {
-		return false
+	if t.Road != nil || t.Zone != nil || t.Rail != nil || t.Structure != nil {
+		return ReasonOccupied
 	}
-	if p.Money < 20 {
-		return false
+	if t.Terrain == "water" {
+		return ReasonUnbuildable
+	}
+	cost, ok := game.placementCost(roadCosts[kind], x, y)
+	if !ok {
+		return ReasonTooSteep
+	}
+	if p.Money < cost {
+		return ReasonInsufficientFunds
 	}
-	p.Money -= 20
-	t.Road = &Road{Owner: p.ID, PlacedAt: time.Now().Unix()}
+	p.Money -= cost
+	t.Road = &Road{Owner: p.ID, PlacedAt: game.unixNow(), Direction: dir, Kind: kind}
+	game.roadsChanged()
+	game.markTile(t)
 	if game.JustRoadThisTick != nil {
 		game.JustRoadThisTick[[2]int{x, y}] = game.Tick + 2
 	}
-	announce(EventRoadPlaced, struct {
-		X    int   `json:"x"`
-		Y    int   `json:"y"`
-		Road *Road `json:"road"`
-	}{x, y, t.Road})
-	return true
+	return ""
+}
+
+// roadBuildable reports whether a road path may pass through (x,y): existing road or open, not too steep land.
+func (game *GameState) roadBuildable(x, y int) bool {
+	if !game.inBounds(x, y) {
+		return false
+	}
+	t := game.Tiles[y][x]
+	if t.Road != nil {
+		return true
+	}
+	if t.Zone != nil || t.Rail != nil || t.Structure != nil || t.Building != nil || t.Terrain == "water" {
+		return false
+	}
+	_, ok := game.placementCost(0, x, y) // not too steep
+	return ok
+}
+
+// roadRoute returns the tiles from (x0,y0) to (x1,y1) for a drag-built road: a straight or
+// L-shaped line when one is clear, otherwise the shortest path over buildable tiles.
+// Returns nil when the endpoints are not connected without bulldozing.
+func (game *GameState) roadRoute(x0, y0, x1, y1 int) [][2]int {
+	if !game.roadBuildable(x0, y0) || !game.roadBuildable(x1, y1) {
+		return nil
+	}
+	line := func(horizontalFirst bool) [][2]int {
+		route := [][2]int{{x0, y0}}
+		x, y := x0, y0
+		step := func(tx, ty int) {
+			for x != tx {
+				x += int(sign(float64(tx - x)))
+				route = append(route, [2]int{x, y})
+			}
+			for y != ty {
+				y += int(sign(float64(ty - y)))
+				route = append(route, [2]int{x, y})
+			}
+		}
+		if horizontalFirst {
+			step(x1, y0)
+		} else {
+			step(x0, y1)
+		}
+		step(x1, y1)
+		for _, c := range route {
+			if !game.roadBuildable(c[0], c[1]) {
+				return nil
+			}
+		}
+		return route
+	}
+	if route := line(true); route != nil {
+		return route
+	}
+	if route := line(false); route != nil {
+		return route
+	}
+	// BFS fallback; every step costs the same so this is shortest.
+	prev := map[[2]int][2]int{}
+	start, goal := [2]int{x0, y0}, [2]int{x1, y1}
+	prev[start] = start
+	queue := [][2]int{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == goal {
+			var route [][2]int
+			for c := goal; c != start; c = prev[c] {
+				route = append(route, c)
+			}
+			route = append(route, start)
+			slices.Reverse(route)
+			return route
+		}
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if _, seen := prev[n]; seen || !game.roadBuildable(n[0], n[1]) {
+				continue
+			}
+			prev[n] = cur
+			queue = append(queue, n)
+		}
+	}
+	return nil
 }
 
 // encasesRoad returns true if placing a zone at (x,y) would box in a road tile so that no further straight extension is possible.
 // Simple heuristic: if exactly one adjacent road exists AND all other empty orthogonal tiles are either out of bounds or already zoned/road/structure/water.
-func encasesRoad(x, y int) bool {
-	if !inBounds(x, y) {
+func (game *GameState) encasesRoad(x, y int) bool {
+	if !game.inBounds(x, y) {
 		return false
 	}
 	t := game.Tiles[y][x]
-	if t.Zone != nil || t.Road != nil || t.Structure != nil || t.Terrain == "water" {
+	if t.Zone != nil || t.Road != nil || t.Rail != nil || t.Structure != nil || t.Terrain == "water" {
 		return false
 	}
 	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
@@ -1801,7 +8563,7 @@ func encasesRoad(x, y int) bool {
 	openAlternatives := 0
 	for _, d := range dirs {
 		nx, ny := x+d[0], y+d[1]
-		if !inBounds(nx, ny) {
+		if !game.inBounds(nx, ny) {
 			continue
 		}
 		nt := game.Tiles[ny][nx]
@@ -1817,10 +8579,26 @@ func encasesRoad(x, y int) bool {
 	return false
 }
 
-// newGame initializes a default game state
-func newGame() *GameState {
-	w, h := 64, 64
-	g := &GameState{Width: w, Height: h, Demand: Demand{Residential: 10, Commercial: 5, Industrial: 5}, Players: map[PlayerID]*Player{}, Tiles: make([][]*Tile, h)}
+// newGame initializes a default game state. The same seed and inputs reproduce the same evolution.
+// Map dimensions: new rooms use defaultMapSide square maps; newGameSize clamps each side to
+// [1, maxMapSide].
+const (
+	defaultMapSide = 64
+	maxMapSide     = 512
+)
+
+func newGame(seed int64) *GameState {
+	return newGameSize(seed, defaultMapSide, defaultMapSide)
+}
+
+// newGameSize creates an empty w x h map. Sides out of range are clamped (and logged) rather than
+// rejected, so a bad setting still yields a playable, if odd, map.
+func newGameSize(seed int64, w, h int) *GameState {
+	if cw, ch := min(max(w, 1), maxMapSide), min(max(h, 1), maxMapSide); cw != w || ch != h {
+		log.Printf("map size %dx%d out of range; using %dx%d", w, h, cw, ch)
+		w, h = cw, ch
+	}
+	g := &GameState{Config: simConfig, Seed: seed, rng: rand.New(rand.NewSource(seed)), Speed: 1, Width: w, Height: h, Demand: Demand{Residential: 10, Commercial: 5, Industrial: 5}, Players: map[PlayerID]*Player{}, Tiles: make([][]*Tile, h)}
 	for y := 0; y < h; y++ {
 		row := make([]*Tile, w)
 		for x := 0; x < w; x++ {
@@ -1832,14 +8610,29 @@ func newGame() *GameState {
 }
 
 func main() {
-	game = newGame()
-	go hub.run()
-	go gameLoop()
-	go trafficLoop()
-	gameMu.Lock()
-	createBotLocked()
-	gameMu.Unlock()
+	getOrCreateRoom(defaultRoomCode)
 	http.HandleFunc("/ws", wsHandler)
-	log.Println("Server listening on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	http.HandleFunc("/state", stateHandler)
+	http.HandleFunc("/summary", summaryHandler)
+	http.HandleFunc("/history", historyHandler)
+	http.HandleFunc("/metrics", metricsHandler)
+	srv := &http.Server{Addr: ":8080"}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Println("Server listening on :8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+	log.Println("shutting down")
+	// Stop accepting connections first; WebSocket connections are hijacked, so rooms close them.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("http shutdown:", err)
+	}
+	shutdownRooms()
+	log.Println("shutdown complete")
 }
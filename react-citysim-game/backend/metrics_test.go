@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsPopulationGauge(t *testing.T) {
+	r := testRoom(t)
+	listRoom(t, r)
+	roadLine(r.game, 0, 10, 10, 10)
+	for x := 1; x <= 3; x++ {
+		build(r.game, x, 11, Residential).Residents = 4
+	}
+	r.stepGame()
+	if r.game.Population == 0 {
+		t.Fatal("no population to report")
+	}
+	srv := newTestServer(t)
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("content type %q", ct)
+	}
+	want := fmt.Sprintf("citysim_population{room=%q} %d\n", "test", r.game.Population)
+	if !strings.Contains(string(body), want) {
+		t.Fatalf("metrics lack %q:\n%s", want, body)
+	}
+	if !strings.Contains(string(body), "# TYPE citysim_population gauge\n") {
+		t.Fatal("population is not declared a gauge")
+	}
+}
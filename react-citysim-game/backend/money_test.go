@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestStartMoneyFromEnv(t *testing.T) {
+	for value, want := range map[string]int{"2500": 2500, "0": 0, "": 100000, "-5": 100000, "lots": 100000} {
+		t.Setenv("CITYSIM_START_MONEY", value)
+		if got := envMoney("CITYSIM_START_MONEY", 100000); got != want {
+			t.Errorf("CITYSIM_START_MONEY=%q: %d, want %d", value, got, want)
+		}
+	}
+
+	t.Setenv("CITYSIM_START_MONEY", "2500")
+	old := startMoney
+	startMoney = envMoney("CITYSIM_START_MONEY", 100000)
+	t.Cleanup(func() { startMoney = old })
+	srv := newTestServer(t)
+	wsWait(t, wsDial(t, srv, "room=money&name=Ann"), EventFullState)
+	r := findRoom("money")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	found := false
+	for _, p := range r.game.Players {
+		if p.Name == "Ann" {
+			found = true
+			if p.Money != 2500 {
+				t.Fatalf("new player has %d, want 2500", p.Money)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("player never joined")
+	}
+}
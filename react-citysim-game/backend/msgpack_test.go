@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// decodeMsgpack decodes the subset of msgpack msgpackFromJSON writes into the shapes
+// encoding/json produces (numbers as float64), returning the bytes after the first value.
+func decodeMsgpack(b []byte) (interface{}, []byte) {
+	c, b := b[0], b[1:]
+	length := func(n int) int {
+		v := 0
+		for _, x := range b[:n] {
+			v = v<<8 | int(x)
+		}
+		b = b[n:]
+		return v
+	}
+	str := func(n int) (interface{}, []byte) { return string(b[:n]), b[n:] }
+	list := func(n int) (interface{}, []byte) {
+		out := make([]interface{}, n)
+		for i := range out {
+			out[i], b = decodeMsgpack(b)
+		}
+		return out, b
+	}
+	object := func(n int) (interface{}, []byte) {
+		out := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			var k, v interface{}
+			k, b = decodeMsgpack(b)
+			v, b = decodeMsgpack(b)
+			out[k.(string)] = v
+		}
+		return out, b
+	}
+	switch {
+	case c < 0x80:
+		return float64(c), b
+	case c >= 0xe0:
+		return float64(int8(c)), b
+	case c&0xf0 == 0x80:
+		return object(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return list(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return str(int(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, b
+	case 0xc2:
+		return false, b
+	case 0xc3:
+		return true, b
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:]
+	case 0xd0:
+		return float64(int8(b[0])), b[1:]
+	case 0xd1:
+		return float64(int16(binary.BigEndian.Uint16(b))), b[2:]
+	case 0xd2:
+		return float64(int32(binary.BigEndian.Uint32(b))), b[4:]
+	case 0xd3:
+		return float64(int64(binary.BigEndian.Uint64(b))), b[8:]
+	case 0xd9:
+		return str(length(1))
+	case 0xda:
+		return str(length(2))
+	case 0xdb:
+		return str(length(4))
+	case 0xdc:
+		return list(length(2))
+	case 0xde:
+		return object(length(2))
+	}
+	panic("unexpected msgpack byte")
+}
+
+func TestTrafficUpdateRoundTripsThroughMsgpack(t *testing.T) {
+	g := newGame(1)
+	g.hub = newHub()
+	g.hub.binary.Add(1)
+	for i := 1; i <= 20; i++ {
+		g.Vehicles = append(g.Vehicles, &Vehicle{ID: int64(i), X: float64(i) + 0.25, Y: -float64(i), Kind: VehicleCar, GroupID: int64(i * 1000)})
+	}
+	g.CitizenGroups = []*CitizenGroup{{ID: 7, State: "working", DestX: 3, DestY: 4}}
+	g.trafficFrame = trafficKeyframeEvery - 1 // a full update, whatever the delta setting
+	g.broadcastTraffic()
+	msg := <-g.hub.broadcast
+	if msg.event != EventTrafficUpdate || msg.binary == nil {
+		t.Fatalf("broadcast %q has no msgpack encoding", msg.event)
+	}
+	got, rest := decodeMsgpack(msg.binary)
+	if len(rest) != 0 {
+		t.Fatalf("%d bytes after the message", len(rest))
+	}
+	var want interface{}
+	json.Unmarshal(msg.text, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("msgpack decodes to\n%v\nwant\n%v", got, want)
+	}
+	if len(msg.binary) >= len(msg.text) {
+		t.Errorf("msgpack %d bytes, JSON %d", len(msg.binary), len(msg.text))
+	}
+}
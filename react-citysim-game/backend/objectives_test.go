@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseObjectives(t *testing.T) {
+	got := parseObjectives("population:40, commercial:3,bogus:1,tax:-5,employed")
+	want := []Objective{{Metric: "population", Target: 40}, {Metric: "commercial", Target: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parsed %+v, want %+v", got, want)
+	}
+}
+
+func TestObjectiveCompletesAtTheThreshold(t *testing.T) {
+	r := newRoomFrom("goals", roomConfig{Seed: 1, Speed: 1, Objectives: parseObjectives("population:40,commercial:1"), EndOnGoals: true})
+	go r.hub.run()
+	t.Cleanup(r.hub.stop)
+	g := r.game
+	c := probe(r, "watcher")
+	pop := g.Objectives[0]
+
+	g.Population = 39
+	g.checkObjectives()
+	if pop.Done {
+		t.Fatal("population objective done one short of its target")
+	}
+	g.Tick, g.Population = 12, 40
+	g.checkObjectives()
+	if !pop.Done || pop.CompletedTick != 12 || g.Ended {
+		t.Fatalf("at the target: %+v, room ended %v", pop, g.Ended)
+	}
+	var ev struct {
+		Metric    string `json:"metric"`
+		Value     int    `json:"value"`
+		Remaining int    `json:"remaining"`
+		Ended     bool   `json:"ended"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventObjectiveComplete), &ev)
+	if ev.Metric != "population" || ev.Value != 40 || ev.Remaining != 1 || ev.Ended {
+		t.Fatalf("completion event %+v", ev)
+	}
+
+	build(g, 5, 5, Commercial)
+	g.checkObjectives()
+	json.Unmarshal(nextEvent(t, c, EventObjectiveComplete), &ev)
+	if !g.Ended || g.Speed != 0 || !ev.Ended || ev.Remaining != 0 {
+		t.Fatalf("all goals met: ended %v, speed %d, event %+v", g.Ended, g.Speed, ev)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import "testing"
+
+func TestOneWayBlocksPathAgainstIt(t *testing.T) {
+	g := newGame(1)
+	roadLine(g, 0, 5, 10, 5)
+	if p := g.roadPath([2]int{10, 5}, [2]int{0, 5}, 400); len(p) == 0 {
+		t.Fatal("no path west on a two-way road")
+	}
+	g.Tiles[5][5].Road.Direction = DirEast
+	if p := g.roadPath([2]int{0, 5}, [2]int{10, 5}, 400); len(p) != 11 {
+		t.Fatalf("path east along the one-way tile has %d tiles, want 11", len(p))
+	}
+	if p := g.roadPath([2]int{10, 5}, [2]int{0, 5}, 400); len(p) != 0 {
+		t.Fatalf("path west against the one-way tile: %v", p)
+	}
+}
+
+// oneWayLoop lays a clockwise ring of one-way road with corners at (2,2) and (8,6).
+func oneWayLoop(g *GameState) {
+	for x := 2; x <= 8; x++ {
+		g.Tiles[2][x].Road = &Road{Direction: DirEast}
+		g.Tiles[6][x].Road = &Road{Direction: DirWest}
+	}
+	for y := 3; y <= 5; y++ {
+		g.Tiles[y][8].Road = &Road{Direction: DirSouth}
+		g.Tiles[y][2].Road = &Road{Direction: DirNorth}
+	}
+	g.Tiles[2][8].Road.Direction, g.Tiles[6][2].Road.Direction = DirSouth, DirNorth
+	g.index = nil
+	g.roadsChanged()
+}
+
+// checkDirected fails t if any road-to-road step of path goes against a one-way tile.
+func checkDirected(t *testing.T, g *GameState, path [][2]int) {
+	t.Helper()
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		if g.Tiles[a[1]][a[0]].Road != nil && g.Tiles[b[1]][b[0]].Road != nil && !g.roadStepAllowed(a[0], a[1], b[0], b[1]) {
+			t.Fatalf("step %v -> %v goes against a one-way road", a, b)
+		}
+	}
+}
+
+func TestOneWayLoopRoundTrip(t *testing.T) {
+	g := newGame(1)
+	oneWayLoop(g)
+	home := build(g, 1, 4, Residential)
+	home.Residents = 1
+	build(g, 9, 4, Industrial).Employees = 1
+	out := g.roadPath([2]int{2, 4}, [2]int{8, 4}, 400)
+	back := g.roadPath([2]int{8, 4}, [2]int{2, 4}, 400)
+	if len(out) == 0 || len(back) == 0 {
+		t.Fatalf("ring routes: out %v back %v", out, back)
+	}
+	checkDirected(t, g, out)
+	checkDirected(t, g, back)
+	// the ring sends both trips clockwise: out by the top, back by the bottom
+	if out[1] != [2]int{2, 3} || back[1] != [2]int{8, 5} {
+		t.Fatalf("routes leave by %v and %v", out[1], back[1])
+	}
+
+	grp := &CitizenGroup{ID: 1, Count: 1, X: 1, Y: 4, Path: append(out, [2]int{9, 4}), State: "outbound", OriginX: 1, OriginY: 4, DestX: 9, DestY: 4}
+	g.CitizenGroups = []*CitizenGroup{grp}
+	for i := 0; i < 200 && grp.State == "outbound"; i++ {
+		g.updateCitizens(0.1)
+	}
+	if grp.State != "working" {
+		t.Fatalf("outbound group ended in state %q at (%v,%v)", grp.State, grp.X, grp.Y)
+	}
+	grp.Timer = 0
+	g.updateCitizens(0.1)
+	if grp.State != "return" {
+		t.Fatalf("group did not start home: state %q", grp.State)
+	}
+	checkDirected(t, g, grp.Path)
+	for i := 0; i < 200 && len(g.CitizenGroups) > 0; i++ {
+		g.updateCitizens(0.1)
+	}
+	if len(g.CitizenGroups) != 0 || g.Tiles[4][1].Citizens != 1 {
+		t.Fatalf("group did not get home: %d groups left, %d citizens home", len(g.CitizenGroups), g.Tiles[4][1].Citizens)
+	}
+}
+
+// detourBlock lays two-way roads round the block from (0,5) to (10,7), with a shop at (9,4) on the
+// top road, and returns the route along the top road from (2,5) to the shop's road tile.
+func detourBlock(g *GameState) [][2]int {
+	roadLine(g, 0, 5, 10, 5)
+	roadLine(g, 0, 7, 10, 7)
+	roadLine(g, 0, 5, 0, 7)
+	roadLine(g, 10, 5, 10, 7)
+	build(g, 9, 4, Commercial)
+	return g.roadPath([2]int{2, 5}, [2]int{9, 5}, 400)
+}
+
+func TestMadeOneWayUnderAShipment(t *testing.T) {
+	g := newGame(1)
+	p := detourBlock(g)
+	shop := g.Tiles[4][9].Building
+	s := &GoodShipment{ID: 1, X: 2, Y: 5, Path: p[1:], Kind: "IC", ToX: 9, ToY: 4, Units: 3}
+	g.GoodsIC = []*GoodShipment{s}
+	g.Tiles[5][5].Road.Direction = DirWest // against the shipment, which is not there yet
+	for i := 0; i < 400 && len(g.GoodsIC) > 0; i++ {
+		g.updateGoods(0.1)
+		if s.Y == 5 && s.X >= 4.5 && s.X <= 5.5 {
+			t.Fatalf("shipment at (%v,%v) on the one-way tile", s.X, s.Y)
+		}
+	}
+	if len(g.GoodsIC) != 0 || shop.Supplies != 3 {
+		t.Fatalf("%d shipments left, shop has %d supplies; want the goods delivered round the block", len(g.GoodsIC), shop.Supplies)
+	}
+
+	// with no way round, the shipment is dropped rather than driven against the flow
+	g = newGame(1)
+	roadLine(g, 0, 5, 10, 5)
+	build(g, 9, 4, Commercial)
+	s = &GoodShipment{ID: 1, X: 2, Y: 5, Path: g.roadPath([2]int{2, 5}, [2]int{9, 5}, 400)[1:], Kind: "IC", ToX: 9, ToY: 4, Units: 3}
+	g.GoodsIC = []*GoodShipment{s}
+	g.Tiles[5][5].Road.Direction = DirWest
+	for i := 0; i < 400 && len(g.GoodsIC) > 0; i++ {
+		g.updateGoods(0.1)
+		if s.X >= 4.5 {
+			t.Fatalf("shipment went on to (%v,%v) against the one-way tile", s.X, s.Y)
+		}
+	}
+	if len(g.GoodsIC) != 0 || g.Tiles[4][9].Building.Supplies != 0 {
+		t.Fatal("a shipment with no route was kept or delivered")
+	}
+}
+
+func TestMadeOneWayUnderCarsAndCommuters(t *testing.T) {
+	g := newGame(1)
+	p := detourBlock(g)
+	home := build(g, 2, 4, Residential)
+	home.Residents = 2
+	g.Tiles[4][9].Building.Supplies = 5 // stocked, so the commuters stay to work
+	v := &Vehicle{ID: 1, X: 2, Y: 5, Path: p[1:], Kind: VehicleCar}
+	g.Vehicles = []*Vehicle{v}
+	grp := &CitizenGroup{ID: 1, Count: 2, X: 2, Y: 4, Path: append(p, [2]int{9, 4}), State: "outbound", OriginX: 2, OriginY: 4, DestX: 9, DestY: 4}
+	g.CitizenGroups = []*CitizenGroup{grp}
+	g.Tiles[5][5].Road.Direction = DirWest
+	for i := 0; i < 400 && (len(g.Vehicles) > 0 || grp.State == "outbound"); i++ {
+		g.updateTraffic(0.1)
+		g.updateCitizens(0.1)
+		if len(g.Vehicles) > 0 && v.Y == 5 && v.X >= 4.5 && v.X <= 5.5 {
+			t.Fatalf("car at (%v,%v) on the one-way tile", v.X, v.Y)
+		}
+		if grp.Y == 5 && grp.X >= 4.5 && grp.X <= 5.5 {
+			t.Fatalf("commuters at (%v,%v) on the one-way tile", grp.X, grp.Y)
+		}
+	}
+	if len(g.Vehicles) != 0 || v.X != 9 || v.Y != 5 {
+		t.Fatalf("car stopped at (%v,%v), want it round the block to (9,5)", v.X, v.Y)
+	}
+	if grp.State != "working" || g.Tiles[4][9].Citizens != 2 {
+		t.Fatalf("commuters in state %q, %d at the shop; want them at work", grp.State, g.Tiles[4][9].Citizens)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOwnershipOverlay(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	join(r, "a", 100000)
+	join(r, "b", 100000)
+	g.flushOwnership()
+	if reason := act(t, r, "a", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 2, Y0: 2, X1: 4, Y1: 3, Zone: Residential}); reason != "" {
+		t.Fatalf("a zoning: %s", reason)
+	}
+	if reason := act(t, r, "b", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 5, Y0: 2, X1: 6, Y1: 2, Zone: Industrial}); reason != "" {
+		t.Fatalf("b zoning: %s", reason)
+	}
+
+	c := probe(r, "a")
+	msg, _ := json.Marshal(Envelope{Type: ActionRequestOwnership})
+	c.handleMessage(msg)
+	var overlay struct {
+		Runs []OwnerRun `json:"runs"`
+	}
+	json.Unmarshal(nextEvent(t, c, EventOwnership), &overlay)
+	want := []OwnerRun{{2, 2, 3, "a"}, {5, 2, 2, "b"}, {2, 3, 3, "a"}}
+	if !reflect.DeepEqual(overlay.Runs, want) {
+		t.Fatalf("overlay %+v, want %+v", overlay.Runs, want)
+	}
+
+	var update struct {
+		Tiles []OwnerChange `json:"tiles"`
+	}
+	g.flushOwnership()
+	json.Unmarshal(nextEvent(t, c, EventOwnershipUpdate), &update)
+	if len(update.Tiles) != 8 {
+		t.Fatalf("update after zoning %+v, want the 8 zoned tiles", update.Tiles)
+	}
+	if reason := act(t, r, "a", ActionBulldoze, BulldozePayload{X: 3, Y: 2}); reason != "" {
+		t.Fatalf("bulldoze: %s", reason)
+	}
+	g.flushOwnership()
+	json.Unmarshal(nextEvent(t, c, EventOwnershipUpdate), &update)
+	if !reflect.DeepEqual(update.Tiles, []OwnerChange{{3, 2, ""}}) {
+		t.Fatalf("update after bulldozing %+v, want only (3,2) cleared", update.Tiles)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPanicInAHandlerKeepsServing(t *testing.T) {
+	srv := newTestServer(t)
+	closeRoom(t, "panic")
+	a := wsDial(t, srv, "room=panic&name=A")
+	wsWait(t, a, EventFullState)
+	b := wsDial(t, srv, "room=panic&name=B")
+	wsWait(t, b, EventFullState)
+	r := findRoom("panic")
+	r.mu.Lock()
+	row := r.game.Tiles[2]
+	r.game.Tiles[2] = row[:1] // a short row: zoning on it indexes past the end
+	r.mu.Unlock()
+
+	wsSend(t, a, ActionPlaceZone, PlaceZonePayload{X: 5, Y: 2, Zone: Residential})
+	var ae ActionError
+	if err := json.Unmarshal(wsWait(t, a, EventActionError), &ae); err != nil || ae.Reason != ReasonInternalError {
+		t.Fatalf("panicking action: %+v, want reason %q", ae, ReasonInternalError)
+	}
+	r.mu.Lock() // the room lock was released by the panic
+	r.game.Tiles[2] = row
+	r.mu.Unlock()
+
+	wsSend(t, b, ActionPlaceZone, PlaceZonePayload{X: 5, Y: 6, Zone: Residential})
+	wsWait(t, b, EventZonePlaced)
+	wsSend(t, a, ActionPlaceZone, PlaceZonePayload{X: 7, Y: 6, Zone: Residential})
+	wsWait(t, a, EventZonePlaced)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 0)
+	other := probe(r, "other")
+
+	if reason := act(t, r, "p", ActionSetName, SetNamePayload{Name: "  Mayor Ana "}); reason != "" {
+		t.Fatalf("rename rejected: %s", reason)
+	}
+	if got := r.game.Players["p"].Name; got != "Mayor Ana" {
+		t.Fatalf("stored name %q, want %q", got, "Mayor Ana")
+	}
+	var info PlayerInfo
+	if err := json.Unmarshal(nextEvent(t, other, EventPlayerUpdate), &info); err != nil || info.ID != "p" || info.Name != "Mayor Ana" {
+		t.Fatalf("player_update %+v, %v", info, err)
+	}
+
+	for _, name := range []string{"", "   ", strings.Repeat("x", maxNameLen+1), "<script>", "a\nb"} {
+		if reason := act(t, r, "p", ActionSetName, SetNamePayload{Name: name}); reason != ReasonInvalidName {
+			t.Errorf("name %q: reason %q, want %q", name, reason, ReasonInvalidName)
+		}
+	}
+	if got := r.game.Players["p"].Name; got != "Mayor Ana" {
+		t.Fatalf("stored name %q after rejected renames", got)
+	}
+	if reason := act(t, r, "p", ActionSetName, SetNamePayload{Name: strings.Repeat("é", maxNameLen)}); reason != "" {
+		t.Errorf("name of maxNameLen runes rejected: %s", reason)
+	}
+}
+
+func TestSetColor(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 0)
+	if reason := act(t, r, "p", ActionSetColor, SetColorPayload{Color: "#FF8800"}); reason != "" {
+		t.Fatalf("color rejected: %s", reason)
+	}
+	for _, color := range []string{"", "red", "#ff880", "#ff88001", "#gg8800"} {
+		if reason := act(t, r, "p", ActionSetColor, SetColorPayload{Color: color}); reason != ReasonInvalidColor {
+			t.Errorf("color %q: reason %q, want %q", color, reason, ReasonInvalidColor)
+		}
+	}
+	if got := r.game.Players["p"].Color; got != "#ff8800" {
+		t.Fatalf("stored color %q, want #ff8800", got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestPlantTypes(t *testing.T) {
+	for _, kind := range []string{"coal", "solar", "nuclear"} {
+		r := testRoom(t)
+		g := r.game
+		join(r, "p", 100000)
+		if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "power_plant", Plant: kind}); reason != "" {
+			t.Fatalf("%s plant: %s", kind, reason)
+		}
+		spec := plantSpecs[kind]
+		if spent := 100000 - g.Players["p"].Money; spent != spec.Cost {
+			t.Errorf("%s plant cost %d, want %d", kind, spent, spec.Cost)
+		}
+		g.updatePower(newBuildingChangeSet())
+		if g.PowerSupply != spec.Capacity {
+			t.Errorf("%s plant supplies %d, want %d", kind, g.PowerSupply, spec.Capacity)
+		}
+		g.updatePollution()
+		at, edge, beyond := g.Tiles[10][10].Pollution, g.Tiles[10][10+spec.Radius].Pollution, g.Tiles[10][15].Pollution
+		if kind == "coal" {
+			if at != spec.Pollution || edge == 0 || beyond != 0 {
+				t.Errorf("coal pollution %d at the plant, %d at its radius, %d beyond", at, edge, beyond)
+			}
+		} else if at != 0 || beyond != 0 {
+			t.Errorf("%s plant pollutes %d", kind, at)
+		}
+	}
+
+	r := testRoom(t)
+	join(r, "p", 100000)
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "power_plant", Plant: "wind"}); reason != ReasonInvalidType {
+		t.Fatalf("wind plant: %q, want %q", reason, ReasonInvalidType)
+	}
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "park", Plant: "coal"}); reason != ReasonInvalidType {
+		t.Fatalf("coal park: %q, want %q", reason, ReasonInvalidType)
+	}
+}
+
+func TestBrownoutAtTheFringe(t *testing.T) {
+	g := newGame(1)
+	g.Tiles[10][0].Structure = &Structure{Type: "power_plant", Plant: "solar", Capacity: 3}
+	var row []*Building
+	for x := 2; x <= 6; x++ {
+		row = append(row, build(g, x, 11, Residential)) // one power unit each
+	}
+	g.updatePower(newBuildingChangeSet())
+	for i, b := range row {
+		if b.Powered != (i < 3) {
+			t.Fatalf("building %d from the plant powered %v", i, b.Powered)
+		}
+	}
+	if s := g.gameSummary(); s.PowerSupply != 3 || s.PowerDemand != 5 {
+		t.Fatalf("summary supply %d, demand %d; want 3 and 5", s.PowerSupply, s.PowerDemand)
+	}
+
+	g.Tiles[10][0].Structure = nil
+	g.updatePower(newBuildingChangeSet())
+	for i, b := range row {
+		if b.Powered || !g.blackedOut(b) {
+			t.Fatalf("building %d powered without a plant", i)
+		}
+	}
+}
+
+func TestBotBuildsPowerWhenShort(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	g.createBotLocked("balanced", 50000)
+	var bot *Player
+	for _, p := range g.Players {
+		bot = p
+	}
+	roadLine(g, 0, 10, 20, 10)
+	build(g, 5, 11, Residential).Powered = false
+	g.Tiles[11][5].Zone.Owner = bot.ID
+	g.PowerSupply, g.PowerDemand = 0, 1
+	g.aiEnsurePower(bot)
+	for _, row := range g.Tiles {
+		for _, tile := range row {
+			if st := tile.Structure; st != nil && st.Type == "power_plant" && st.Owner == bot.ID && st.Plant == aiPlant {
+				return
+			}
+		}
+	}
+	t.Fatal("bot built no power plant for its browned-out home")
+}
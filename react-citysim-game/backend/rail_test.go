@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+// shipment runs one industrial shipment 30 tiles east along a road, and a rail line too if rail
+// is set, returning it and the frames it took to deliver.
+func shipment(rail bool) (*GoodShipment, int) {
+	g := newGame(5)
+	for x := 10; x <= 40; x++ {
+		g.Tiles[10][x].Road = &Road{}
+		if rail {
+			g.Tiles[8][x].Rail = &Rail{}
+		}
+	}
+	build(g, 10, 9, Industrial).Stock = trainCapacity
+	build(g, 40, 9, Commercial)
+	g.roadsChanged()
+	for i := 0; i < 100 && len(g.GoodsIC) == 0; i++ {
+		g.spawnGoodsShipments()
+	}
+	if len(g.GoodsIC) == 0 {
+		return nil, 0
+	}
+	s := g.GoodsIC[0]
+	frames := 0
+	for ; len(g.GoodsIC) > 0 && frames < 1000; frames++ {
+		g.updateGoods(0.1)
+	}
+	return s, frames
+}
+
+func TestRailShipsFaster(t *testing.T) {
+	train, byRail := shipment(true)
+	truck, byRoad := shipment(false)
+	if train == nil || truck == nil {
+		t.Fatal("no shipment left the factory")
+	}
+	if !train.Train || train.Units != trainCapacity || truck.Train || truck.Units >= train.Units {
+		t.Fatalf("rail shipment %+v, road shipment %+v", train, truck)
+	}
+	if byRail >= byRoad {
+		t.Fatalf("delivered in %d frames by rail, %d by road", byRail, byRoad)
+	}
+}
+
+func TestTrainsStayOnRails(t *testing.T) {
+	g := newGame(5)
+	for x := 10; x <= 40; x++ {
+		g.Tiles[10][x].Road = &Road{}
+		g.Tiles[8][x].Rail = &Rail{}
+	}
+	path := g.railPath([2]int{10, 8}, [2]int{40, 8}, 4000)
+	if len(path) != 31 {
+		t.Fatalf("rail path of %d tiles along a straight line of 31", len(path))
+	}
+	for _, p := range path {
+		if g.Tiles[p[1]][p[0]].Rail == nil {
+			t.Fatalf("rail path leaves the rails at %v", p)
+		}
+	}
+	g.Tiles[8][25].Rail = nil // the road beside the gap is no way round it
+	if path := g.railPath([2]int{10, 8}, [2]int{40, 8}, 4000); len(path) != 0 {
+		t.Fatalf("rail path across a gap: %v", path)
+	}
+	if !developed(g.Tiles[8][10]) {
+		t.Fatal("a rail tile doesn't count as developed")
+	}
+}
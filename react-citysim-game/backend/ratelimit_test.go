@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(20, 40)
+	now := b.last
+	allowed := 0
+	for i := 0; i < 1000; i++ {
+		if b.allow(now) {
+			allowed++
+		}
+	}
+	if allowed != 40 {
+		t.Fatalf("burst allowed %d, want 40", allowed)
+	}
+	now = now.Add(500 * time.Millisecond)
+	allowed = 0
+	for i := 0; i < 1000; i++ {
+		if b.allow(now) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Fatalf("after half a second allowed %d, want 10", allowed)
+	}
+}
+
+func TestActionFloodIsRateLimited(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 1000000)
+	c := probe(r, "p")
+	c.limiter = newTokenBucket(actionRate, actionBurst)
+	c.bulldoze = newTokenBucket(bulldozeRate, bulldozeBurst)
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		p, _ := json.Marshal(PlaceZonePayload{X: i % 64, Y: 10 + i/64, Zone: Residential})
+		msg, _ := json.Marshal(Envelope{Type: ActionPlaceZone, Payload: p})
+		c.handleMessage(msg)
+	}
+	elapsed := time.Since(start).Seconds()
+
+	zoned := 0
+	for _, row := range r.game.Tiles {
+		for _, tile := range row {
+			if tile.Zone != nil {
+				zoned++
+			}
+		}
+	}
+	if limit := int(actionBurst + actionRate*elapsed); zoned < int(actionBurst) || zoned > limit {
+		t.Fatalf("%d of 1000 zones placed in %.2fs, want %d..%d", zoned, elapsed, int(actionBurst), limit)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestBulldozeRefund(t *testing.T) {
+	r := testRoom(t)
+	join(r, "owner", 100000)
+	join(r, "other", 100000)
+	g := r.game
+
+	if reason := act(t, r, "owner", ActionPlaceStructure, PlaceStructurePayload{X: 20, Y: 20, Kind: "power_plant", Plant: "coal"}); reason != "" {
+		t.Fatalf("plant rejected: %s", reason)
+	}
+	if m := g.Players["owner"].Money; m != 95000 {
+		t.Fatalf("money after placing %d, want 95000", m)
+	}
+	act(t, r, "owner", ActionBulldoze, BulldozePayload{X: 20, Y: 20})
+	if g.Tiles[20][20].Structure != nil {
+		t.Fatal("plant still standing")
+	}
+	if m := g.Players["owner"].Money; m != 96250 {
+		t.Fatalf("money after demolishing %d, want the 1250 refund", m)
+	}
+
+	act(t, r, "other", ActionPlaceZone, PlaceZonePayload{X: 30, Y: 30, Zone: Residential})
+	for _, c := range [][2]int{{30, 30}, {40, 40}} { // someone else's zone, then empty grass
+		act(t, r, "owner", ActionBulldoze, BulldozePayload{X: c[0], Y: c[1]})
+	}
+	if m := g.Players["owner"].Money; m != 96250 {
+		t.Fatalf("money %d after demolishing unowned and empty tiles, want no refund", m)
+	}
+	if m := g.Players["other"].Money; m != 100000-zoneCost {
+		t.Fatalf("zone owner money %d, want no refund for someone else's demolition", m)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+// carAcross is a room with a car driving east along row 10 from (3,10) to (17,10), and a parallel
+// road on row 12 joined to it at both ends if detour is set.
+func carAcross(t *testing.T, detour bool) (*Room, *Vehicle) {
+	r := testRoom(t)
+	join(r, "p", 0)
+	g := r.game
+	roadLine(g, 0, 10, 20, 10)
+	if detour {
+		roadLine(g, 0, 12, 20, 12)
+		roadLine(g, 2, 11, 2, 11)
+		roadLine(g, 18, 11, 18, 11)
+	}
+	path := g.roadPath([2]int{3, 10}, [2]int{17, 10}, 400)
+	v := &Vehicle{ID: 1, X: 3, Y: 10, Path: path[1:], Kind: VehicleCar}
+	g.Vehicles = []*Vehicle{v}
+	return r, v
+}
+
+func TestCarReroutesAroundABulldozedRoad(t *testing.T) {
+	r, v := carAcross(t, true)
+	g := r.game
+	g.updateTraffic(0.5)
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 10, Y: 10}); reason != "" {
+		t.Fatalf("bulldoze: %s", reason)
+	}
+	if len(g.Vehicles) != 1 {
+		t.Fatal("car removed although a detour exists")
+	}
+	for i := 0; i < 400 && len(g.Vehicles) > 0; i++ {
+		g.updateTraffic(0.1)
+		if x, y := int(v.X+0.5), int(v.Y+0.5); g.Tiles[y][x].Road == nil {
+			t.Fatalf("car off the road at (%.2f,%.2f)", v.X, v.Y)
+		}
+	}
+	if v.X != 17 || v.Y != 10 {
+		t.Fatalf("car stopped at (%.2f,%.2f), want (17,10)", v.X, v.Y)
+	}
+}
+
+func TestCarWithNoWayRoundIsRemoved(t *testing.T) {
+	r, _ := carAcross(t, false)
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 10, Y: 10}); reason != "" {
+		t.Fatalf("bulldoze: %s", reason)
+	}
+	if n := len(r.game.Vehicles); n != 0 {
+		t.Fatalf("%d cars kept with their road cut", n)
+	}
+}
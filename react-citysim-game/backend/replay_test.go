@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayReproducesTheFinalState(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CITYSIM_RECORD_DIR", dir)
+	cfg := roomConfig{Seed: 7, Speed: 1, Bots: []string{"balanced"}, BotMoney: botMoney}
+	r := newRoomFrom("rec", cfg)
+	if err := r.startJournal(cfg); err != nil {
+		t.Fatal(err)
+	}
+	go r.hub.run()
+	join(r, "p", startMoney)
+	pay := func(v interface{}) json.RawMessage { b, _ := json.Marshal(v); return b }
+	for i := 0; i < 200; i++ {
+		if i == 5 {
+			r.input(journalEntry{Kind: journalAction, Player: "p", Action: ActionBuildRoadPath, Payload: pay(BuildRoadPathPayload{X0: 5, Y0: 5, X1: 20, Y1: 5, Kind: RoadLocal})})
+			for x := 5; x < 20; x++ {
+				r.input(journalEntry{Kind: journalAction, Player: "p", Action: ActionPlaceZone, Payload: pay(PlaceZonePayload{X: x, Y: 6, Zone: Residential})})
+				r.input(journalEntry{Kind: journalAction, Player: "p", Action: ActionPlaceZone, Payload: pay(PlaceZonePayload{X: x, Y: 4, Zone: Commercial})})
+			}
+			r.input(journalEntry{Kind: journalAction, Player: "p", Action: ActionPlaceStructure, Payload: pay(PlaceStructurePayload{X: 12, Y: 7, Kind: "water_tower"})})
+		}
+		r.input(journalEntry{Kind: journalStep})
+		for k := 0; k < 10; k++ {
+			r.input(journalEntry{Kind: journalFrame, Elapsed: time.Duration(95+k) * time.Millisecond})
+		}
+	}
+	want, _ := json.Marshal(r.game)
+	r.hub.stop()
+	r.stopJournal()
+	if r.game.Population == 0 {
+		t.Fatal("the recorded city never grew")
+	}
+
+	g, err := ReplayFromFile(filepath.Join(dir, "rec.replay.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(g)
+	if !bytes.Equal(got, want) {
+		for i := range want {
+			if i >= len(got) || got[i] != want[i] {
+				t.Fatalf("replayed state differs at byte %d:\n got %.200s\nwant %.200s", i, got[i:], want[i:])
+			}
+		}
+		t.Fatalf("replayed state has %d extra bytes", len(got)-len(want))
+	}
+}
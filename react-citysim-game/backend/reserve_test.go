@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// reserveNeighbor returns the land value beside (10,10), with a reserve zoned there if reserve
+// is set, and the room.
+func reserveNeighbor(t *testing.T, reserve bool) (int, *Room) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	if reserve {
+		if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 10, Y: 10, Zone: Reserve}); reason != "" {
+			t.Fatalf("reserve: %s", reason)
+		}
+		if spent := 100000 - r.game.Players["p"].Money; spent != reserveZoneCost || spent >= zoneCost {
+			t.Fatalf("reserve cost %d, want %d", spent, reserveZoneCost)
+		}
+	}
+	r.game.updateLandValue()
+	return r.game.Tiles[10][11].LandValue, r
+}
+
+func TestReserveNeverDevelops(t *testing.T) {
+	without, _ := reserveNeighbor(t, false)
+	with, r := reserveNeighbor(t, true)
+	if with <= without {
+		t.Fatalf("land value beside a reserve %d, %d without", with, without)
+	}
+	roadLine(r.game, 0, 9, 20, 9)
+	for i := 0; i < 30; i++ {
+		r.stepGame()
+	}
+	if tile := r.game.Tiles[10][10]; tile.Building != nil || !reserved(tile) {
+		t.Fatalf("reserve became %+v", tile.Building)
+	}
+	if rep, _ := r.game.capacityReport(QueryCapacityPayload{X0: 10, Y0: 10, X1: 10, Y1: 10}); rep.Residential.Zoned+rep.Commercial.Zoned+rep.Industrial.Zoned != 0 {
+		t.Fatalf("capacity counts the reserve: %+v", rep)
+	}
+	for _, strategy := range []string{"balanced", "residential-focused"} {
+		if n := zonePicks(strategy)[Reserve]; n != 0 {
+			t.Fatalf("%s bot zoned %d reserves", strategy, n)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// getJSON fetches url, checks the status and decodes a 200 body into v.
+func getJSON(t *testing.T, url string, status int, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: status %d, want %d", url, resp.StatusCode, status)
+	}
+	if status == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+}
+
+func TestStateEndpoint(t *testing.T) {
+	r := testRoom(t)
+	listRoom(t, r)
+	join(r, "p", 100000)
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 4, Y: 6, Zone: Commercial})
+	srv := newTestServer(t)
+
+	var state GameState
+	getJSON(t, srv.URL+"/state?room=test", http.StatusOK, &state)
+	if state.Width != r.game.Width || state.Tiles[6][4].Zone == nil || state.Tiles[6][4].Zone.Type != Commercial {
+		t.Fatalf("state %dx%d without the placed zone", state.Width, state.Height)
+	}
+	if state.Players["p"] == nil {
+		t.Fatal("state lacks the player")
+	}
+	var bare map[string]json.RawMessage
+	getJSON(t, srv.URL+"/state?room=test&players=false", http.StatusOK, &bare)
+	if _, ok := bare["players"]; ok {
+		t.Fatal("players=false still lists players")
+	}
+	getJSON(t, srv.URL+"/state?room=nosuchroom", http.StatusNotFound, nil)
+	resp, err := http.Post(srv.URL+"/state?room=test", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /state: status %d", resp.StatusCode)
+	}
+}
+
+func TestSummaryEndpoint(t *testing.T) {
+	r := testRoom(t)
+	listRoom(t, r)
+	join(r, "p", 100000)
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 4, Y: 6, Zone: Commercial})
+	act(t, r, "p", ActionPlaceRoad, PlaceRoadPayload{X: 4, Y: 5})
+	srv := newTestServer(t)
+
+	var s TickSummary
+	getJSON(t, srv.URL+"/summary?room=test", http.StatusOK, &s)
+	if s.Zones[Commercial] != 1 || s.Roads != 1 || s.Money["p"] != r.game.Players["p"].Money {
+		t.Fatalf("summary %+v", s)
+	}
+	getJSON(t, srv.URL+"/summary?room=nosuchroom", http.StatusNotFound, nil)
+}
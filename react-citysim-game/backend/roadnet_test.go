@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSplitRoadNetwork(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	roadLine(g, 0, 10, 10, 10)
+	roadLine(g, 20, 10, 30, 10)
+	build(g, 2, 11, Residential)
+	build(g, 5, 11, Industrial)
+	build(g, 22, 11, Residential) // no job on this side
+	build(g, 24, 11, Residential)
+	c := probe(r, "watcher")
+
+	r.stepGame()
+	var tick TickSummary
+	json.Unmarshal(nextEvent(t, c, EventTick), &tick)
+	if tick.RoadComponents != 2 || tick.Stranded != 0.5 {
+		t.Fatalf("split network: %d components, %.2f stranded; want 2 and 0.50", tick.RoadComponents, tick.Stranded)
+	}
+	if s := g.gameSummary(); s.RoadComponents != 2 || s.Stranded != 0.5 {
+		t.Fatalf("summary between ticks: %d components, %.2f stranded", s.RoadComponents, s.Stranded)
+	}
+	net := g.roadNetwork()
+	r.stepGame()
+	if g.roadNetwork() != net {
+		t.Fatal("road network recomputed without a road change")
+	}
+
+	roadLine(g, 11, 10, 19, 10)
+	r.stepGame()
+	nextEvent(t, c, EventTick) // the unchanged tick above
+	json.Unmarshal(nextEvent(t, c, EventTick), &tick)
+	if tick.RoadComponents != 1 || tick.Stranded != 0 {
+		t.Fatalf("joined network: %d components, %.2f stranded; want 1 and 0", tick.RoadComponents, tick.Stranded)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRoadNetworkChangedIsDebounced(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	c := probe(r, "p")
+	g := r.game
+	settle := func(comps, roads int) {
+		t.Helper()
+		g.announceRoadNetwork(roadNetworkDebounce)
+		var ev struct{ Components, Roads int }
+		if err := json.Unmarshal(nextEvent(t, c, EventRoadNetworkChanged), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Components != comps || ev.Roads != roads {
+			t.Fatalf("road network %d components of %d roads, want %d of %d", ev.Components, ev.Roads, comps, roads)
+		}
+	}
+
+	for _, x := range []int{10, 11, 13, 14} {
+		if reason := act(t, r, "p", ActionPlaceRoad, PlaceRoadPayload{X: x, Y: 20}); reason != "" {
+			t.Fatalf("road at %d: %s", x, reason)
+		}
+		g.announceRoadNetwork(roadNetworkDebounce / 2) // still placing: the burst is not over
+	}
+	if slices.Contains(drain(t, r, c), EventRoadNetworkChanged) {
+		t.Fatal("road_network_changed sent during the burst")
+	}
+	settle(2, 4)
+	g.announceRoadNetwork(time.Second)
+	if slices.Contains(drain(t, r, c), EventRoadNetworkChanged) {
+		t.Fatal("road_network_changed resent with no change")
+	}
+
+	act(t, r, "p", ActionPlaceRoad, PlaceRoadPayload{X: 12, Y: 20})
+	settle(1, 5)
+	act(t, r, "p", ActionBulldoze, BulldozePayload{X: 11, Y: 20})
+	settle(2, 4)
+	act(t, r, "p", ActionUndo, nil)
+	settle(1, 5)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// roadTiles lists the road tiles in rows y0..y1 of g.
+func roadTiles(g *GameState, y0, y1 int) map[[2]int]bool {
+	roads := map[[2]int]bool{}
+	for y := y0; y <= y1; y++ {
+		for x, t := range g.Tiles[y] {
+			if t.Road != nil {
+				roads[[2]int{x, y}] = true
+			}
+		}
+	}
+	return roads
+}
+
+func TestRoadPathLShape(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	if reason := act(t, r, "p", ActionBuildRoadPath, BuildRoadPathPayload{X0: 5, Y0: 5, X1: 10, Y1: 9, Kind: RoadLocal}); reason != "" {
+		t.Fatalf("path rejected: %s", reason)
+	}
+	want := map[[2]int]bool{}
+	for x := 5; x <= 10; x++ {
+		want[[2]int{x, 5}] = true
+	}
+	for y := 6; y <= 9; y++ {
+		want[[2]int{10, y}] = true
+	}
+	if got := roadTiles(g, 0, 20); len(got) != len(want) {
+		t.Fatalf("%d road tiles, want the %d of the L", len(got), len(want))
+	} else {
+		for c := range want {
+			if !got[c] {
+				t.Errorf("no road at %v", c)
+			}
+		}
+	}
+	if m := g.Players["p"].Money; m != 100000-10*roadCosts[RoadLocal] {
+		t.Fatalf("money %d, want 10 tiles charged", m)
+	}
+
+	// a building on the top leg turns the L the other way round
+	build(g, 8, 30, Commercial)
+	act(t, r, "p", ActionBuildRoadPath, BuildRoadPathPayload{X0: 5, Y0: 30, X1: 10, Y1: 34, Kind: RoadLocal})
+	for _, c := range [][2]int{{5, 31}, {5, 34}, {10, 34}} {
+		if g.Tiles[c[1]][c[0]].Road == nil {
+			t.Errorf("no road at %v on the vertical-first L", c)
+		}
+	}
+	if g.Tiles[30][8].Road != nil || g.Tiles[30][8].Building == nil {
+		t.Error("path bulldozed the building")
+	}
+}
+
+func TestRoadPathStopsWhenFundsRunOut(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 4*roadCosts[RoadLocal]+10)
+	other := probe(r, "other")
+	if reason := act(t, r, "p", ActionBuildRoadPath, BuildRoadPathPayload{X0: 5, Y0: 5, X1: 15, Y1: 5, Kind: RoadLocal}); reason != ReasonInsufficientFunds {
+		t.Fatalf("reason %q, want %q", reason, ReasonInsufficientFunds)
+	}
+	if got := roadTiles(r.game, 5, 5); len(got) != 4 || !got[[2]int{8, 5}] {
+		t.Fatalf("road tiles %v, want x 5..8", got)
+	}
+	if m := r.game.Players["p"].Money; m != 10 {
+		t.Fatalf("money %d, want 10", m)
+	}
+	var ev RoadsPlacedEvent
+	json.Unmarshal(nextEvent(t, other, EventRoadsPlaced), &ev)
+	if ev.Complete || len(ev.Roads) != 4 || ev.StoppedAt == nil || *ev.StoppedAt != [2]int{8, 5} {
+		t.Fatalf("roads_placed %+v, want 4 roads stopped at (8,5)", ev)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRoomsAreIndependent(t *testing.T) {
+	srv := newTestServer(t)
+	a := wsDial(t, srv, "room=indep-a&name=A")
+	b := wsDial(t, srv, "room=indep-b&name=B")
+	wsWait(t, a, EventFullState)
+	wsWait(t, b, EventFullState)
+	wsSend(t, a, ActionPlaceZone, PlaceZonePayload{X: 3, Y: 3, Zone: Residential})
+	wsWait(t, a, EventZonePlaced)
+
+	ra, rb := findRoom("indep-a"), findRoom("indep-b")
+	if ra == nil || rb == nil || ra == rb {
+		t.Fatal("rooms were not created separately")
+	}
+	ra.mu.RLock()
+	zonedA := ra.game.Tiles[3][3].Zone != nil
+	ra.mu.RUnlock()
+	rb.mu.RLock()
+	zonedB := rb.game.Tiles[3][3].Zone != nil
+	rb.mu.RUnlock()
+	if !zonedA || zonedB {
+		t.Fatalf("zone in room a: %v, in room b: %v", zonedA, zonedB)
+	}
+}
+
+func TestRoomsStepIndependently(t *testing.T) {
+	a := newRoomFrom("a", roomConfig{Seed: 1, Speed: 1})
+	b := newRoomFrom("b", roomConfig{Seed: 1, Speed: 1})
+	for _, r := range []*Room{a, b} {
+		go r.hub.run()
+		t.Cleanup(r.hub.stop)
+	}
+	for i := 0; i < 5; i++ {
+		a.stepGame()
+	}
+	if a.game.Tick != 5 || b.game.Tick != 0 {
+		t.Fatalf("ticks a %d b %d, want 5 and 0", a.game.Tick, b.game.Tick)
+	}
+}
+
+func TestFailedUpgradeCreatesNoRoom(t *testing.T) {
+	srv := newTestServer(t)
+	resp, err := http.Get(srv.URL + "/ws?room=no-upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusSwitchingProtocols || findRoom("no-upgrade") != nil {
+		t.Fatalf("plain GET: status %d, room created %v", resp.StatusCode, findRoom("no-upgrade") != nil)
+	}
+}
+
+func TestRoomCap(t *testing.T) {
+	srv := newTestServer(t)
+	defer func(n int) { maxRooms = n }(maxRooms)
+	maxRooms = roomCount()
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?room=over-cap", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("joining a room over the cap: err %v, response %v", err, resp)
+	}
+	if findRoom("over-cap") != nil {
+		t.Fatal("room created over the cap")
+	}
+}
+
+func TestEmptyRoomIsClosed(t *testing.T) {
+	roomsMu.Lock() // new rooms read it under roomsMu
+	defer func(d time.Duration) {
+		roomsMu.Lock()
+		roomIdleTimeout = d
+		roomsMu.Unlock()
+	}(roomIdleTimeout)
+	roomIdleTimeout = 2 * speedPollInterval
+	roomsMu.Unlock()
+	srv := newTestServer(t)
+	c := wsDial(t, srv, "room=reaped&name=A")
+	wsWait(t, c, EventFullState)
+	r := findRoom("reaped")
+	c.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for findRoom("reaped") != nil && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if findRoom("reaped") != nil {
+		t.Fatal("empty room still open")
+	}
+	select {
+	case <-r.hub.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closed room's hub still running")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// humans drops the bots from a roster.
+func humans(roster []PlayerInfo) []string {
+	names := []string{}
+	for _, p := range roster {
+		if !p.Bot {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+func TestRosterJoinAndLeave(t *testing.T) {
+	srv := newTestServer(t)
+	ann := wsDial(t, srv, "room=roster&name=Ann")
+	wsWait(t, ann, EventFullState)
+	bob := wsDial(t, srv, "room=roster&name=Bob")
+	wsWait(t, bob, EventFullState)
+
+	var joined PlayerInfo
+	json.Unmarshal(wsWait(t, ann, EventPlayerJoined), &joined)
+	if joined.Name != "Bob" || joined.ID == "" {
+		t.Fatalf("player_joined %+v, want Bob", joined)
+	}
+
+	wsSend(t, bob, ActionRequestRoster, struct{}{})
+	var roster struct {
+		Players []PlayerInfo `json:"players"`
+	}
+	json.Unmarshal(wsWait(t, bob, EventRoster), &roster)
+	if got := humans(roster.Players); len(got) != 2 || got[0] != "Ann" || got[1] != "Bob" {
+		t.Fatalf("roster %v, want Ann and Bob", got)
+	}
+
+	bob.Close()
+	var left PlayerInfo
+	json.Unmarshal(wsWait(t, ann, EventPlayerLeft), &left)
+	if left != joined {
+		t.Fatalf("player_left %+v, want %+v", left, joined)
+	}
+	wsSend(t, ann, ActionRequestRoster, struct{}{})
+	json.Unmarshal(wsWait(t, ann, EventRoster), &roster)
+	if got := humans(roster.Players); len(got) != 1 || got[0] != "Ann" {
+		t.Fatalf("roster after leave %v, want only Ann", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seededRun runs a bot-built room with the given seed and no client input for 100 ticks and
+// returns its demand and a text map of zones, buildings and roads.
+func seededRun(t *testing.T, seed int64) (Demand, string) {
+	r := newRoomFrom("seed", roomConfig{Seed: seed, Speed: 1, Bots: []string{"balanced"}, BotMoney: botMoney})
+	go r.hub.run()
+	defer r.hub.stop()
+	for i := 0; i < 100; i++ {
+		r.stepGame()
+		r.trafficFrame(100 * time.Millisecond)
+	}
+	var layout strings.Builder
+	for _, row := range r.game.Tiles {
+		for _, tl := range row {
+			switch {
+			case tl.Building != nil:
+				fmt.Fprintf(&layout, "%s%d", tl.Building.Type, tl.Building.Stage)
+			case tl.Zone != nil:
+				layout.WriteString(strings.ToLower(string(tl.Zone.Type)))
+			case tl.Road != nil:
+				layout.WriteByte('#')
+			default:
+				layout.WriteByte('.')
+			}
+		}
+		layout.WriteByte('\n')
+	}
+	return r.game.Demand, layout.String()
+}
+
+func TestSameSeedSameCity(t *testing.T) {
+	d1, l1 := seededRun(t, 42)
+	d2, l2 := seededRun(t, 42)
+	if d1 != d2 {
+		t.Fatalf("demand %+v and %+v from the same seed", d1, d2)
+	}
+	if l1 != l2 {
+		t.Fatalf("layouts differ from the same seed:\n%s\n%s", l1, l2)
+	}
+	if !strings.Contains(l1, "#") {
+		t.Fatal("the bot built nothing, so the comparison proves little")
+	}
+	if _, l3 := seededRun(t, 43); l3 == l1 {
+		t.Fatal("a different seed built the same city")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdownStopsEverything(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CITYSIM_SAVE_DIR", dir)
+	before := runtime.NumGoroutine()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", wsHandler)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	c, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws?room=shutdown&name=A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	wsWait(t, c, EventFullState)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := findRoom("shutdown")
+	roomsMu.Lock()
+	delete(rooms, "shutdown") // shutdownRooms would stop the other tests' rooms too
+	roomsMu.Unlock()
+	r.shutdown()
+	r.shutdown() // a second shutdown, as from the idle reaper, is harmless
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("connection still open after shutdown")
+			}
+			break
+		}
+	}
+	c.Close()
+	if _, err := os.Stat(filepath.Join(dir, "shutdown.json")); err != nil {
+		t.Fatalf("state not saved: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines left running, %d before:\n%s", n, before, buf[:runtime.Stack(buf, true)])
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// factoryStaff is how many of 20 nearby residents one factory employs under cfg.
+func factoryStaff(cfg SimConfig) int {
+	r := newRoomFrom("cfg", roomConfig{Seed: 1, Speed: 1, Sim: &cfg})
+	g := r.game
+	roadLine(g, 0, 10, 20, 10)
+	build(g, 1, 11, Residential).Residents = 20
+	factory := build(g, 4, 11, Industrial)
+	laborTicks(g, 3)
+	return factory.Employees
+}
+
+func TestSimConfigOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.json")
+	os.WriteFile(path, []byte(`{"industrialCapacity": 8}`), 0o644)
+	cfg := loadSimConfig(path)
+	want := defaultSimConfig()
+	want.IndustrialCapacity = 8
+	if cfg != want {
+		t.Fatalf("loaded %+v, want the defaults with industrialCapacity 8", cfg)
+	}
+	if n, m := factoryStaff(defaultSimConfig()), factoryStaff(cfg); n != 4 || m != 8 {
+		t.Fatalf("factory staff %d by default, %d overridden; want 4 and 8", n, m)
+	}
+
+	os.WriteFile(path, []byte(`{"industrialCapacity": 0}`), 0o644)
+	if cfg := loadSimConfig(path); cfg != defaultSimConfig() {
+		t.Fatalf("invalid config loaded as %+v, want the defaults", cfg)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSpectatorWatchesButCannotBuild(t *testing.T) {
+	srv := newTestServer(t)
+	player := wsDial(t, srv, "room=spectate&name=Ann")
+	wsWait(t, player, EventFullState)
+	watcher := wsDial(t, srv, "room=spectate&spectate=true")
+	wsWait(t, watcher, EventFullState)
+
+	wsSend(t, player, ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Residential})
+	var placed ZonePlacedEvent
+	json.Unmarshal(wsWait(t, watcher, EventZonePlaced), &placed)
+	if placed.X != 5 || placed.Y != 5 {
+		t.Fatalf("spectator saw %+v, want the zone at (5,5)", placed)
+	}
+
+	wsSend(t, watcher, ActionPlaceZone, PlaceZonePayload{X: 6, Y: 5, Zone: Residential})
+	var e ActionError
+	json.Unmarshal(wsWait(t, watcher, EventActionError), &e)
+	if e.Reason != ReasonSpectator {
+		t.Fatalf("spectator action error %+v, want %s", e, ReasonSpectator)
+	}
+
+	r := findRoom("spectate")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.game.Tiles[5][6].Zone != nil {
+		t.Fatal("spectator placed a zone")
+	}
+	humans := 0
+	for _, p := range r.game.Players {
+		if !p.Bot {
+			humans++
+		}
+	}
+	if humans != 1 {
+		t.Fatalf("%d human players, want only Ann", humans)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// tickAt reads the room's tick under its read lock.
+func tickAt(r *Room) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.game.Tick
+}
+
+func TestPauseFreezesTicks(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 0)
+	probe(r, "p") // a connected client, so the room doesn't idle
+	r.loops.Add(1)
+	go func() { defer r.loops.Done(); r.gameLoop() }()
+	t.Cleanup(func() { close(r.quit); r.loops.Wait() })
+
+	if reason := act(t, r, "p", ActionSetSpeed, SetSpeedPayload{Speed: 4}); reason != "" {
+		t.Fatalf("speed 4 rejected: %s", reason)
+	}
+	time.Sleep(600 * time.Millisecond)
+	if tickAt(r) == 0 {
+		t.Fatal("no ticks at speed 4")
+	}
+	act(t, r, "p", ActionSetSpeed, SetSpeedPayload{Speed: 0})
+	time.Sleep(2 * speedPollInterval) // let a poll in flight finish
+	paused := tickAt(r)
+	time.Sleep(600 * time.Millisecond)
+	if now := tickAt(r); now != paused {
+		t.Fatalf("tick moved from %d to %d while paused", paused, now)
+	}
+	act(t, r, "p", ActionSetSpeed, SetSpeedPayload{Speed: 4})
+	time.Sleep(600 * time.Millisecond)
+	if now := tickAt(r); now <= paused {
+		t.Fatalf("tick still %d after resuming", now)
+	}
+	if reason := act(t, r, "p", ActionSetSpeed, SetSpeedPayload{Speed: 3}); reason != ReasonInvalidType {
+		t.Fatalf("speed 3: reason %q, want %q", reason, ReasonInvalidType)
+	}
+}
+
+func TestSetSpeedNeedsTheAdminToken(t *testing.T) {
+	setAdminToken(t, "secret")
+	r := testRoom(t)
+	join(r, "p", 0)
+	c := probe(r, "p")
+	for _, token := range []string{"", "wrong"} {
+		if reason := request(t, c, ActionSetSpeed, SetSpeedPayload{Token: token, Speed: 0}); reason != ReasonUnauthorized {
+			t.Fatalf("set_speed with token %q: %q, want %q", token, reason, ReasonUnauthorized)
+		}
+	}
+	if r.game.Speed != 1 {
+		t.Fatalf("an unauthorized set_speed left speed %d", r.game.Speed)
+	}
+	if reason := request(t, c, ActionSetSpeed, SetSpeedPayload{Token: "secret", Speed: 0}); reason != "" {
+		t.Fatalf("set_speed with the token: %s", reason)
+	}
+	if r.game.Speed != 0 {
+		t.Fatalf("speed %d after pausing with the token", r.game.Speed)
+	}
+}
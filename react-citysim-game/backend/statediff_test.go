@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStateDiffHoldsOnlyTheChangedTile(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	r.game.Tick = 3 // nothing changed at tick 3 before the sync, so only the zone is new
+	c := probe(r, "p")
+	c.sendFullState()
+	nextEvent(t, c, EventFullState)
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 7, Y: 9, Zone: Residential}); reason != "" {
+		t.Fatalf("zone rejected: %s", reason)
+	}
+	c.sendStateDiff(nil)
+	var diff StateDiffEvent
+	if err := json.Unmarshal(nextEvent(t, c, EventStateDiff), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Tiles) != 1 || diff.Tiles[0].X != 7 || diff.Tiles[0].Y != 9 || diff.Tiles[0].Zone == nil {
+		t.Fatalf("diff tiles %+v, want only the zoned (7,9)", diff.Tiles)
+	}
+	if diff.Since != 3 || diff.Tick != 3 {
+		t.Fatalf("diff since %d at tick %d, want 3 and 3", diff.Since, diff.Tick)
+	}
+}
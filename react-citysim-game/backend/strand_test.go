@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestBulldozedDestinationMidTrip(t *testing.T) {
+	for _, state := range []string{"outbound", "working"} {
+		r := testRoom(t)
+		g := r.game
+		join(r, "p", 100000)
+		roadLine(g, 0, 10, 40, 10)
+		build(g, 2, 9, Residential).Residents = 10
+		g.Tiles[9][2].Citizens = 9 // one is out on the trip
+		shop := build(g, 30, 11, Commercial)
+		shop.Supplies, shop.Employees = 5, 1
+		g.Tiles[11][30].Zone.Owner = "p"
+		grp := &CitizenGroup{ID: 1, Count: 1, X: 2, Y: 9, State: "outbound", OriginX: 2, OriginY: 9, DestX: 30, DestY: 11}
+		g.reroute(grp, 30, 11)
+		g.CitizenGroups = []*CitizenGroup{grp}
+		for i := 0; i < 400 && (state == "working" && grp.State != "working" || state == "outbound" && grp.PathIndex < 10); i++ {
+			g.updateCitizens(0.1)
+		}
+		if grp.State != state {
+			t.Fatalf("group %s, want %s before the bulldozer", grp.State, state)
+		}
+
+		if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 30, Y: 11}); reason != "" {
+			t.Fatalf("bulldoze: %s", reason)
+		}
+		for i := 0; i < 2000 && len(g.CitizenGroups) > 0; i++ {
+			g.updateCitizens(0.1)
+			if len(g.CitizenGroups) > 1 {
+				t.Fatalf("%s group duplicated", state)
+			}
+			if n := g.Tiles[11][30].Citizens; n != 0 {
+				t.Fatalf("%d citizens on the bulldozed tile", n)
+			}
+		}
+		if len(g.CitizenGroups) != 0 || g.Tiles[9][2].Citizens != 10 {
+			t.Fatalf("%s group: %d groups left, %d citizens home; want 0 and 10", state, len(g.CitizenGroups), g.Tiles[9][2].Citizens)
+		}
+	}
+}
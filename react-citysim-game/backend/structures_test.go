@@ -0,0 +1,123 @@
+package main
+
+import "testing"
+
+func TestPlaceParkAndPlaza(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 10000)
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 5, Y: 5, Kind: "park"}); reason != "" {
+		t.Fatalf("park rejected: %s", reason)
+	}
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 20, Y: 5, Kind: "plaza"}); reason != "" {
+		t.Fatalf("plaza rejected: %s", reason)
+	}
+	g := r.game
+	if st := g.Tiles[5][5].Structure; st == nil || st.Type != "park" || st.Owner != "p" {
+		t.Fatalf("park tile holds %+v", st)
+	}
+	if st := g.Tiles[5][20].Structure; st == nil || st.Type != "plaza" {
+		t.Fatalf("plaza tile holds %+v", st)
+	}
+	if want := 10000 - structureSpecs["park"].Cost - structureSpecs["plaza"].Cost; g.Players["p"].Money != want {
+		t.Fatalf("money %d, want %d", g.Players["p"].Money, want)
+	}
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 30, Y: 30, Kind: "castle"}); reason != ReasonInvalidType {
+		t.Fatalf("unknown kind: reason %q, want %q", reason, ReasonInvalidType)
+	}
+	if g.Tiles[30][30].Structure != nil {
+		t.Fatal("unknown kind was placed")
+	}
+}
+
+func TestStructureRadius(t *testing.T) {
+	for _, kind := range []string{"park", "plaza"} {
+		g := newGame(1)
+		g.updateLandValue()
+		base := g.Tiles[30][30].LandValue
+		g.Tiles[30][30].Structure = &Structure{Type: kind}
+		g.updateLandValue()
+		radius := structureSpecs[kind].Radius
+		if v := g.Tiles[30][30+radius].LandValue; v <= base {
+			t.Errorf("%s: land value at its radius %d, base %d", kind, v, base)
+		}
+		if v := g.Tiles[30][30+radius+1].LandValue; v != base {
+			t.Errorf("%s: land value beyond its radius %d, want %d", kind, v, base)
+		}
+		if near, edge := g.Tiles[30][31].LandValue, g.Tiles[30][30+radius].LandValue; near <= edge {
+			t.Errorf("%s: bonus does not fade: %d beside it, %d at the edge", kind, near, edge)
+		}
+	}
+}
+
+func TestParkCutsPollution(t *testing.T) {
+	g := newGame(1)
+	g.Tiles[10][10].Zone = &Zone{Type: Industrial}
+	g.Tiles[10][10].Building = &Building{Type: Industrial, Final: true, Employees: 4}
+	g.updateLandValue()
+	before := g.Tiles[10][12].Pollution
+	g.Tiles[10][13].Structure = &Structure{Type: "park"}
+	g.updateLandValue()
+	if after := g.Tiles[10][12].Pollution; before == 0 || after >= before {
+		t.Fatalf("pollution beside a park %d, was %d", after, before)
+	}
+}
+
+func TestStadiumCoversTwoByTwo(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "stadium"}); reason != "" {
+		t.Fatalf("stadium rejected: %s", reason)
+	}
+	if root := g.Tiles[10][10].Structure; root == nil || root.Type != "stadium" || root.Size != 2 {
+		t.Fatalf("root tile holds %+v", root)
+	}
+	for _, c := range [][2]int{{11, 10}, {10, 11}, {11, 11}} {
+		if m := g.Tiles[c[1]][c[0]].Structure; m == nil || m.Type != structureMember || *m.Root != [2]int{10, 10} {
+			t.Fatalf("member tile %v holds %+v", c, m)
+		}
+	}
+	if g.Tiles[12][12].Structure != nil || g.Tiles[10][12].Structure != nil {
+		t.Fatal("stadium spilled past its footprint")
+	}
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: g.Width - 1, Y: 0, Kind: "stadium"}); reason != ReasonOutOfBounds {
+		t.Fatalf("stadium over the map edge: reason %q, want %q", reason, ReasonOutOfBounds)
+	}
+}
+
+func TestStadiumNeedsEveryTileFree(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	g.Tiles[21][21].Road = &Road{}
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 20, Y: 20, Kind: "stadium"}); reason != ReasonOccupied {
+		t.Fatalf("member tile on a road: reason %q, want %q", reason, ReasonOccupied)
+	}
+	for _, c := range [][2]int{{20, 20}, {21, 20}, {20, 21}, {21, 21}} {
+		if g.Tiles[c[1]][c[0]].Structure != nil {
+			t.Fatalf("rejected stadium left a structure on %v", c)
+		}
+	}
+	if g.Players["p"].Money != 100000 {
+		t.Fatalf("rejected stadium charged: %d left", g.Players["p"].Money)
+	}
+}
+
+func TestBulldozingAMemberClearsTheStructure(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "stadium"})
+	money := g.Players["p"].Money
+	if reason := act(t, r, "p", ActionBulldoze, BulldozePayload{X: 11, Y: 11}); reason != "" {
+		t.Fatalf("bulldoze rejected: %s", reason)
+	}
+	for _, c := range [][2]int{{10, 10}, {11, 10}, {10, 11}, {11, 11}} {
+		if st := g.Tiles[c[1]][c[0]].Structure; st != nil {
+			t.Fatalf("bulldozing a member left %+v on %v", st, c)
+		}
+	}
+	if refund, want := g.Players["p"].Money-money, structureSpecs["stadium"].Cost*bulldozeRefundPct/100; refund != want {
+		t.Fatalf("refund %d, want %d for the whole stadium", refund, want)
+	}
+}
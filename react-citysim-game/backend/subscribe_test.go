@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// queuedTypes returns the types of the messages already queued for c.
+func queuedTypes(c *Client) []string {
+	var types []string
+	for {
+		select {
+		case b := <-c.send:
+			var env Envelope
+			json.Unmarshal(b, &env)
+			types = append(types, env.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestUnsubscribedClientGetsTicksButNoTraffic(t *testing.T) {
+	r := testRoom(t)
+	all := probe(r, "all")
+	stats := probe(r, "stats")
+	if reason := request(t, stats, ActionSubscribe, SubscribePayload{Events: []string{EventTick}}); reason != "" {
+		t.Fatalf("subscribe rejected: %s", reason)
+	}
+	roadLine(r.game, 0, 1, 30, 1)
+	r.game.Vehicles = []*Vehicle{{ID: 1, X: 0, Y: 1, Path: r.game.roadPath([2]int{1, 1}, [2]int{30, 1}, 100), Kind: VehicleCar}}
+	for i := 0; i < 5; i++ {
+		r.trafficFrame(100 * time.Millisecond)
+	}
+	r.stepGame()
+
+	// the hub delivers each broadcast to every client before the next, so once all has the
+	// marker, stats has everything it is going to get
+	if got := drain(t, r, all); !slices.Contains(got, EventTrafficUpdate) || !slices.Contains(got, EventTick) {
+		t.Fatalf("a client on every event got %v", got)
+	}
+	got := queuedTypes(stats)
+	if !slices.Contains(got, EventTick) {
+		t.Fatalf("subscribed to ticks but got %v", got)
+	}
+	for _, typ := range got {
+		if typ != EventTick {
+			t.Fatalf("subscribed to ticks only but got %v", got)
+		}
+	}
+
+	request(t, stats, ActionSubscribe, SubscribePayload{}) // back to everything
+	r.game.trafficFrame = trafficKeyframeEvery - 1
+	r.trafficFrame(100 * time.Millisecond)
+	if got := drain(t, r, stats); !slices.Contains(got, EventTrafficUpdate) {
+		t.Fatalf("resubscribed to everything but got %v", got)
+	}
+}
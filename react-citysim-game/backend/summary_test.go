@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummaryCountsMatchTheMap(t *testing.T) {
+	g := newGame(1)
+	g.Players["p"] = &Player{ID: "p", Money: 1234}
+	roadLine(g, 0, 10, 9, 10)
+	build(g, 1, 11, Residential)
+	build(g, 2, 11, Residential)
+	build(g, 3, 11, Commercial)
+	build(g, 4, 11, Industrial).AbandonPhase = 2
+	g.Tiles[11][5].Zone = &Zone{Type: Residential} // zoned, nothing built yet
+	g.Tiles[11][6].Zone = &Zone{Type: Commercial}
+	g.Tiles[11][6].Building = &Building{Type: Commercial} // under construction
+	g.Vehicles = []*Vehicle{{}, {}}
+	g.CitizenGroups = []*CitizenGroup{{}}
+	g.GoodsIC, g.GoodsCC = []*GoodShipment{{}}, []*GoodShipment{{}, {}}
+
+	s := g.gameSummary()
+	if s.Roads != 10 || s.Abandoning != 1 || s.Vehicles != 2 || s.CitizenGroups != 1 || s.Goods != 3 {
+		t.Fatalf("roads %d abandoning %d vehicles %d groups %d goods %d", s.Roads, s.Abandoning, s.Vehicles, s.CitizenGroups, s.Goods)
+	}
+	if want := map[ZoneType]int{Residential: 3, Commercial: 2, Industrial: 1}; !reflect.DeepEqual(s.Zones, want) {
+		t.Fatalf("zones %v, want %v", s.Zones, want)
+	}
+	if want := map[ZoneType]int{Residential: 2, Commercial: 1}; !reflect.DeepEqual(s.Buildings, want) {
+		t.Fatalf("buildings %v, want %v", s.Buildings, want)
+	}
+	if want := map[PlayerID]int{"p": 1234}; !reflect.DeepEqual(s.Money, want) {
+		t.Fatalf("money %v, want %v", s.Money, want)
+	}
+}
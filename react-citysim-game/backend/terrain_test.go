@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// hill raises a 3x3 patch centred on (x,y) to elevation e as hill terrain.
+func hill(g *GameState, x, y, e int) {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			g.Tiles[y+dy][x+dx].Elevation = e
+			g.Tiles[y+dy][x+dx].Terrain = "hill"
+		}
+	}
+}
+
+func TestHillsCostMore(t *testing.T) {
+	r := testRoom(t)
+	g := r.game
+	hill(g, 20, 20, 2)
+	flat, _ := g.placementCost(zoneCost, 5, 5)
+	high, ok := g.placementCost(zoneCost, 20, 20)
+	if !ok || flat != zoneCost || high <= flat {
+		t.Fatalf("flat %d, hill %d (ok %v)", flat, high, ok)
+	}
+
+	join(r, "p", zoneCost)
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 20, Y: 20, Zone: Residential}); reason != ReasonInsufficientFunds {
+		t.Fatalf("zoning a hill with %d: %q, want %q", zoneCost, reason, ReasonInsufficientFunds)
+	}
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Residential}); reason != "" {
+		t.Fatalf("zoning flat grass with %d: %q", zoneCost, reason)
+	}
+	if m := g.Players["p"].Money; m != 0 {
+		t.Fatalf("money %d after flat zone, want 0", m)
+	}
+}
+
+func TestSteepTilesAreRefused(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	r.game.Tiles[10][10].Elevation = maxBuildSlope + 1
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 10, Y: 10, Zone: Residential}); reason != ReasonTooSteep {
+		t.Fatalf("zoning a cliff: %q, want %q", reason, ReasonTooSteep)
+	}
+}
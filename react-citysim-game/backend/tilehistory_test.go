@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestTileHistoryRecordsZoneBuildAbandon(t *testing.T) {
+	old := tileHistoryLen
+	tileHistoryLen = 10
+	t.Cleanup(func() { tileHistoryLen = old })
+	setAdminToken(t, "secret")
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	roadLine(g, 0, 10, 20, 10)
+	if reason := act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 4, Y: 11, Zone: Residential}); reason != "" {
+		t.Fatalf("zone rejected: %s", reason)
+	}
+	// the home is built but nobody moves in, so it abandons
+	tl := g.Tiles[11][4]
+	built := false
+	for i := 0; i < 500 && !(built && tl.Building == nil); i++ {
+		built = built || tl.Building != nil
+		g.Tick++
+		if tl.Building != nil {
+			tl.Building.Watered = true
+		}
+		changes := newBuildingChangeSet()
+		g.progressBuildings(changes)
+		g.allocateLaborAndSupplies(changes)
+	}
+
+	c := probe(r, "p")
+	msg, _ := json.Marshal(Envelope{Type: ActionTileHistory, Payload: json.RawMessage(`{"token":"secret","x":4,"y":11}`)})
+	c.handleMessage(msg)
+	var reply struct {
+		X, Y   int
+		Events []TileEvent
+	}
+	if err := json.Unmarshal(nextEvent(t, c, EventTileHistory), &reply); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range reply.Events {
+		got = append(got, e.Layer+" "+e.Change+" "+e.Reason)
+	}
+	want := []string{"zone placed place_zone", "building started construction", "building completed construction", "building abandoning noResidents", "building removed abandoned"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("tile history\n%q\nwant\n%q", got, want)
+	}
+	for i := 1; i < len(reply.Events); i++ {
+		if reply.Events[i].Tick < reply.Events[i-1].Tick {
+			t.Fatalf("events out of tick order: %+v", reply.Events)
+		}
+	}
+}
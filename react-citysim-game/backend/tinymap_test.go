@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// tinyRoom is a room with a balanced bot on a w x h map, its hub running until t ends.
+func tinyRoom(t *testing.T, w, h int) *Room {
+	r := newRoomFrom("tiny", roomConfig{Seed: 1, Speed: 1, Bots: []string{"balanced"}, BotMoney: 100000})
+	g := newGameSize(1, w, h)
+	g.Players, g.BotIDs, g.hub = r.game.Players, r.game.BotIDs, r.hub
+	r.game = g
+	go r.hub.run()
+	t.Cleanup(r.hub.stop)
+	return r
+}
+
+func TestTinyMapsDoNotPanic(t *testing.T) {
+	for _, sz := range [][2]int{{0, 0}, {-3, 5}, {1, 1}, {1, 2}, {2, 1}, {2, 2}, {3, 3}, {8, 8}} {
+		t.Run(fmt.Sprint(sz), func(t *testing.T) {
+			r := tinyRoom(t, sz[0], sz[1])
+			g := r.game
+			if g.Width < 1 || g.Height < 1 || len(g.Tiles) != g.Height || len(g.Tiles[0]) != g.Width {
+				t.Fatalf("%dx%d map has a %dx%d grid", sz[0], sz[1], g.Width, g.Height)
+			}
+			join(r, "p", 100000)
+			rng := g.rng
+			actions := []string{ActionPlaceRoad, ActionPlaceZone, ActionBulldoze, ActionPlaceStructure, ActionUndo}
+			for i := 0; i < 300; i++ {
+				// aim at every tile and one beyond each edge
+				x, y := rng.Intn(g.Width+2)-1, rng.Intn(g.Height+2)-1
+				payload := fmt.Sprintf(`{"x":%d,"y":%d,"zone":"%s","kind":"%s"}`, x, y,
+					[]string{"R", "C", "I"}[rng.Intn(3)], []string{"water_tower", "power_plant", "park", "stadium"}[rng.Intn(4)])
+				r.input(journalEntry{Kind: journalAction, Player: "p", Action: actions[rng.Intn(len(actions))], Payload: []byte(payload)})
+				for _, row := range g.Tiles { // let homes fill so citizens and traffic have somewhere to go
+					for _, tl := range row {
+						if b := tl.Building; b != nil && b.Type == Residential {
+							b.Final, b.Watered, b.Residents = true, true, 5
+						}
+					}
+				}
+				r.stepGame()
+				for k := 0; k < 10; k++ {
+					r.trafficFrame(100 * time.Millisecond)
+				}
+			}
+		})
+	}
+}
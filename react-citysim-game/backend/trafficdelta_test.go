@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStationaryGroupIsNotResent(t *testing.T) {
+	sent := map[int64]TrafficEntity{}
+	working := TrafficEntity{ID: 1, X: 3.5, Y: 4.5}
+	walking := TrafficEntity{ID: 2, X: 1, Y: 1}
+
+	d := trafficDiff([]TrafficEntity{working, walking}, sent)
+	if len(d.Spawned) != 2 || len(d.Moved) != 0 {
+		t.Fatalf("first frame %+v, want both spawned", d)
+	}
+	for i := 0; i < 5; i++ {
+		walking.X += 0.2
+		d = trafficDiff([]TrafficEntity{working, walking}, sent)
+		if len(d.Spawned) != 0 || len(d.Moved) != 1 || d.Moved[0].ID != 2 {
+			t.Fatalf("frame %d %+v, want only the walking group moved", i, d)
+		}
+	}
+	d = trafficDiff([]TrafficEntity{working}, sent)
+	if !slices.Equal(d.Despawned, []int64{2}) || len(d.Moved) != 0 {
+		t.Fatalf("after the walking group left %+v, want it despawned", d)
+	}
+	if d = trafficDiff([]TrafficEntity{working}, sent); !d.empty() {
+		t.Fatalf("unchanged frame %+v, want empty", d)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestUndoZone(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 1000)
+	other := probe(r, "other")
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Residential})
+	if reason := act(t, r, "p", ActionUndo, struct{}{}); reason != "" {
+		t.Fatalf("undo rejected: %s", reason)
+	}
+	if r.game.Tiles[5][5].Zone != nil {
+		t.Fatal("zone still placed after undo")
+	}
+	if m := r.game.Players["p"].Money; m != 1000 {
+		t.Fatalf("money %d after undo, want 1000", m)
+	}
+	nextEvent(t, other, EventUndone)
+	if reason := act(t, r, "p", ActionUndo, struct{}{}); reason != ReasonNothingToUndo {
+		t.Fatalf("second undo: reason %q, want %q", reason, ReasonNothingToUndo)
+	}
+}
+
+func TestUndoRefusedAfterOverwrite(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 1000)
+	join(r, "q", 1000)
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Residential})
+	act(t, r, "q", ActionBulldoze, BulldozePayload{X: 5, Y: 5})
+	act(t, r, "q", ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Commercial})
+
+	if reason := act(t, r, "p", ActionUndo, struct{}{}); reason != ReasonTileChanged {
+		t.Fatalf("undo over q's zone: reason %q, want %q", reason, ReasonTileChanged)
+	}
+	if z := r.game.Tiles[5][5].Zone; z == nil || z.Owner != "q" {
+		t.Fatalf("q's zone was undone: %+v", z)
+	}
+	if m := r.game.Players["p"].Money; m != 1000-zoneCost {
+		t.Fatalf("p money %d, want no refund from the refused undo", m)
+	}
+	if reason := act(t, r, "p", ActionUndo, struct{}{}); reason != ReasonNothingToUndo {
+		t.Fatalf("refused entry still on the stack: %q", reason)
+	}
+}
+
+func TestUndoIgnoresGrowth(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 1000)
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: Residential})
+	tile := r.game.Tiles[5][5]
+	tile.Building = &Building{Type: Residential, Residents: 3} // the simulation grew a house on the zone
+	tile.Foliage = "tree"
+
+	if reason := act(t, r, "p", ActionUndo, struct{}{}); reason != "" {
+		t.Fatalf("undo after growth rejected: %s", reason)
+	}
+	if tile.Zone != nil || tile.Building != nil || tile.Foliage != "" {
+		t.Fatalf("tile after undo %+v, want the bare grass from before the zone", tile)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestInvalidTypesChangeNothing(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	cases := []struct {
+		action  string
+		payload interface{}
+	}{
+		{ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: "X"}},
+		{ActionPlaceZone, PlaceZonePayload{X: 5, Y: 5, Zone: ""}},
+		{ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 4, Y0: 4, X1: 6, Y1: 6, Zone: "residential"}},
+		{ActionPlaceStructure, PlaceStructurePayload{X: 5, Y: 5, Kind: "castle"}},
+		{ActionPlaceStructure, PlaceStructurePayload{X: 5, Y: 5, Kind: "power_plant", Plant: "fusion"}},
+		{ActionPlaceStructure, PlaceStructurePayload{X: 5, Y: 5, Kind: "park", Plant: "coal"}},
+	}
+	for _, c := range cases {
+		before := g.layersAt(5, 5)
+		if reason := act(t, r, "p", c.action, c.payload); reason != ReasonInvalidType {
+			t.Errorf("%s %+v: reason %q, want %q", c.action, c.payload, reason, ReasonInvalidType)
+		}
+		if g.layersAt(5, 5) != before || g.Players["p"].Money != 100000 {
+			t.Errorf("%s %+v changed the map or the money", c.action, c.payload)
+		}
+	}
+}
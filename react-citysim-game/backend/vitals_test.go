@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+// vitals runs 200 ticks of births and deaths alone in a fully housed city of 200 homes at the
+// given happiness, and returns the population it started with, births and deaths.
+func vitals(happiness int) (start, births, deaths int) {
+	g := newGame(1)
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 30; x++ {
+			build(g, x, y, Residential).Residents = maxResidents
+		}
+	}
+	g.simulateCitizens()
+	start = g.Population
+	for i := 0; i < 200; i++ {
+		g.Happiness = happiness
+		g.naturalChange(newBuildingChangeSet())
+		g.simulateCitizens()
+	}
+	return start, len(g.PendingResidents), start - g.Population
+}
+
+func TestBirthsAndDeathsAreSlow(t *testing.T) {
+	start, births, deaths := vitals(50)
+	if births == 0 || deaths == 0 {
+		t.Fatalf("%d births and %d deaths in 200 ticks", births, deaths)
+	}
+	if net := births - deaths; net*10 > start || -net*10 > start {
+		t.Fatalf("net change %d from a population of %d in 200 ticks", net, start)
+	}
+	if _, b, d := vitals(90); b <= d {
+		t.Fatalf("happy city: %d births, %d deaths", b, d)
+	}
+	if _, b, d := vitals(10); b >= d {
+		t.Fatalf("unhappy city: %d births, %d deaths", b, d)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestWaterCoverageRadius(t *testing.T) {
+	g := newGame(1)
+	g.Tiles[10][10].Structure = &Structure{Type: "water_tower"}
+	roadLine(g, 11, 10, 30, 10)
+	edge := build(g, 17, 11, Residential)   // 8 steps: 7 along the road and one off it
+	beyond := build(g, 18, 11, Residential) // 9 steps
+	apart := build(g, 10, 13, Residential)  // 3 tiles away but nothing connects it
+	g.updateWater(newBuildingChangeSet())
+	if !edge.Watered || beyond.Watered || apart.Watered {
+		t.Fatalf("watered: at the radius %v, beyond it %v, unconnected %v", edge.Watered, beyond.Watered, apart.Watered)
+	}
+}
+
+func TestResidentsLeaveWhenTheTowerGoes(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	if reason := act(t, r, "p", ActionPlaceStructure, PlaceStructurePayload{X: 10, Y: 10, Kind: "water_tower"}); reason != "" {
+		t.Fatalf("tower rejected: %s", reason)
+	}
+	roadLine(g, 11, 10, 14, 10)
+	home := build(g, 12, 11, Residential)
+	home.Residents = 10
+	for i := 0; i < 2*waterLossTicks; i++ {
+		g.Tick++
+		g.updateWater(newBuildingChangeSet())
+	}
+	if !home.Watered || home.Residents != 10 {
+		t.Fatalf("with the tower: watered %v, %d residents", home.Watered, home.Residents)
+	}
+	act(t, r, "p", ActionBulldoze, BulldozePayload{X: 10, Y: 10})
+	for i := 0; i < 3*waterLossTicks; i++ {
+		g.Tick++
+		g.updateWater(newBuildingChangeSet())
+	}
+	if home.Watered || home.Residents != 7 {
+		t.Fatalf("without the tower: watered %v, %d residents, want 7", home.Watered, home.Residents)
+	}
+}
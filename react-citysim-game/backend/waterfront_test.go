@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestWaterfrontHomesAreFavored(t *testing.T) {
+	g, _ := newcomerTown(true)
+	build(g, 14, 11, Residential)
+	for _, x := range []int{10, 14} {
+		for y := 11; y <= 13; y++ {
+			g.Tiles[y][x].Terrain = ""
+		}
+	}
+	g.Tiles[12][14].Terrain = "water"
+	if a, b := g.landValueAt(10, 11), g.landValueAt(14, 11); b <= a {
+		t.Fatalf("land value %d inland, %d on the waterfront", a, b)
+	}
+
+	// equal land value otherwise: the inland home comes first on a tie
+	g.Tiles[11][10].LandValue, g.Tiles[11][14].LandValue = 50, 50
+	g.growthTick(newBuildingChangeSet())
+	if len(g.CitizenGroups) != 1 || g.CitizenGroups[0].DestX != 14 {
+		t.Fatalf("newcomers %+v, want one group bound for the waterfront home", g.CitizenGroups)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestZoneRectStopsWhenFundsRunOut(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 5*zoneCost+50)
+	other := probe(r, "other")
+
+	if reason := act(t, r, "p", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 9, Y0: 5, X1: 0, Y1: 5, Zone: Residential}); reason != "" {
+		t.Fatalf("partial rect rejected: %s", reason)
+	}
+	for x := 0; x < 10; x++ {
+		if zoned := r.game.Tiles[5][x].Zone != nil; zoned != (x < 5) {
+			t.Errorf("tile (%d,5) zoned=%v", x, zoned)
+		}
+	}
+	if m := r.game.Players["p"].Money; m != 50 {
+		t.Fatalf("money %d, want 50", m)
+	}
+	var ev ZonesPlacedEvent
+	json.Unmarshal(nextEvent(t, other, EventZonesPlaced), &ev)
+	if len(ev.Zones) != 5 {
+		t.Fatalf("zones_placed lists %d tiles, want 5", len(ev.Zones))
+	}
+	if reason := act(t, r, "p", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 0, Y0: 6, X1: 9, Y1: 6, Zone: Residential}); reason != ReasonInsufficientFunds {
+		t.Fatalf("broke rect: reason %q, want %q", reason, ReasonInsufficientFunds)
+	}
+}
+
+func TestZoneRectSkipsObstacles(t *testing.T) {
+	r := testRoom(t)
+	join(r, "p", 100000)
+	g := r.game
+	g.Tiles[20][11].Road = &Road{}
+	g.Tiles[20][12].Terrain = "water"
+	build(g, 13, 20, Commercial)
+
+	if reason := act(t, r, "p", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 10, Y0: 20, X1: 14, Y1: 20, Zone: Residential}); reason != "" {
+		t.Fatalf("rect rejected: %s", reason)
+	}
+	for x, want := range map[int]ZoneType{10: Residential, 13: Commercial, 14: Residential} {
+		if z := g.Tiles[20][x].Zone; z == nil || z.Type != want {
+			t.Errorf("tile (%d,20) zone %+v, want %s", x, z, want)
+		}
+	}
+	if g.Tiles[20][11].Zone != nil || g.Tiles[20][12].Zone != nil || g.Tiles[20][11].Road == nil {
+		t.Error("rect zoned over the road or the water")
+	}
+	if m := g.Players["p"].Money; m != 100000-2*zoneCost {
+		t.Fatalf("money %d, want two tiles charged", m)
+	}
+	if reason := act(t, r, "p", ActionPlaceZoneRect, PlaceZoneRectPayload{X0: 11, Y0: 20, X1: 13, Y1: 20, Zone: Residential}); reason != ReasonOccupied {
+		t.Fatalf("all-obstacle rect: reason %q, want %q", reason, ReasonOccupied)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestZoningBuffer(t *testing.T) {
+	setAdminToken(t, "secret")
+	r := testRoom(t)
+	join(r, "p", 100000)
+	c := probe(r, "p")
+	act(t, r, "p", ActionPlaceZone, PlaceZonePayload{X: 10, Y: 10, Zone: Industrial})
+
+	if reason := request(t, c, ActionPlaceZone, PlaceZonePayload{X: 11, Y: 10, Zone: Residential}); reason != "" {
+		t.Fatalf("housing beside industry without the buffer: %q", reason)
+	}
+	act(t, r, "p", ActionBulldoze, BulldozePayload{X: 11, Y: 10})
+
+	for _, token := range []string{"", "wrong"} {
+		if reason := request(t, c, ActionSetZoningBuffer, SetZoningBufferPayload{Enabled: true, Token: token}); reason != ReasonUnauthorized {
+			t.Fatalf("set_zoning_buffer with token %q: reason %q, want %q", token, reason, ReasonUnauthorized)
+		}
+	}
+	if r.game.ZoningBuffer {
+		t.Fatal("unauthorized request turned the buffer on")
+	}
+	if reason := request(t, c, ActionSetZoningBuffer, SetZoningBufferPayload{Enabled: true, Token: "secret"}); reason != "" || !r.game.ZoningBuffer {
+		t.Fatalf("set_zoning_buffer with the token: %q, buffer %v", reason, r.game.ZoningBuffer)
+	}
+
+	if reason := request(t, c, ActionPlaceZone, PlaceZonePayload{X: 10, Y: 11, Zone: Residential}); reason != ReasonIncompatibleNeighbor {
+		t.Fatalf("housing beside industry with the buffer: reason %q, want %q", reason, ReasonIncompatibleNeighbor)
+	}
+	if r.game.Tiles[11][10].Zone != nil {
+		t.Fatal("blocked zone was placed")
+	}
+	if reason := request(t, c, ActionPlaceZone, PlaceZonePayload{X: 10, Y: 12, Zone: Residential}); reason != "" {
+		t.Fatalf("housing two tiles from industry: %q", reason)
+	}
+	if reason := request(t, c, ActionPlaceZone, PlaceZonePayload{X: 9, Y: 10, Zone: Commercial}); reason != "" {
+		t.Fatalf("commerce beside industry: %q", reason)
+	}
+}